@@ -6,10 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strconv"
-	"time"
 
-	"github.com/google/uuid"
 	"github.com/vilterp/treesql/pkg"
 )
 
@@ -29,30 +26,29 @@ func main() {
 	fmt.Println("connected to", *mothershipUrl, "for app", *appID)
 	defer clientConn.Close()
 
-	// insert new version
-	newVersionID := uuid.New()
-	fmt.Println("new version:", newVersionID)
-
-	newVersionStmt := fmt.Sprintf("insert into versions values ('%s', '%s', '%v')", newVersionID, *appID, time.Now())
-	_, newVersionErr := clientConn.Exec(newVersionStmt)
+	// insert new version; its id and timestamp are generated
+	newVersionKeys, newVersionErr := clientConn.Insert(
+		"insert into versions (app_id) values ($1)",
+		*appID,
+	)
 	if newVersionErr != nil {
 		fmt.Println("failed to write new version:", newVersionErr)
 		return
 	}
+	newVersionID := newVersionKeys[0]
+	fmt.Println("new version:", newVersionID)
 
 	filepath.Walk(*dir, func(path string, info os.FileInfo, err error) error {
 		if !info.IsDir() {
 			fmt.Println("inserting", path)
-			newFileID := uuid.New()
 			contents, readErr := ioutil.ReadFile(path)
 			if readErr != nil {
 				fmt.Println("couldn't read file", path, ":", readErr)
 			}
-			newFileStmt := fmt.Sprintf(
-				"insert into files values ('%s', '%s', '%s', %s)",
-				newFileID, path, newVersionID, strconv.Quote(string(contents)),
+			_, newFileErr := clientConn.Exec(
+				"insert into files (path, version_id, contents) values ($1, $2, $3)",
+				path, newVersionID, contents, // sent in base64
 			)
-			_, newFileErr := clientConn.Exec(newFileStmt)
 			if newFileErr != nil {
 				fmt.Println("failed to write file:", newFileErr)
 				// lol, we don't have transactions :P
@@ -39,11 +39,11 @@ func main() {
 	}()
 
 	// open files LQ
-	res, channel, err := clientConn.LiveQuery(getFilesQuery(*appID))
-	log.Println("initial files:", res.Data)
+	res, channel, err := clientConn.LiveQuery(filesQuery, *appID)
 	if err != nil {
 		log.Fatal(err)
 	}
+	log.Println("initial files:", res.Data)
 	for {
 		update := <-channel.Updates
 		parsed, _ := json.MarshalIndent(update, "", "  ")
@@ -51,20 +51,18 @@ func main() {
 	}
 }
 
-func getFilesQuery(appID string) string {
-	return fmt.Sprintf(`
-		one apps where id = "%s" {
+const filesQuery = `
+	one apps where id = $1 {
+		id,
+		versions: many versions {
 			id,
-			versions: many versions {
+			timestamp,
+			files: many files {
 				id,
-				timestamp,
-				files: many files {
-					id,
-					path,
-					contents
-				}
+				path,
+				contents
 			}
 		}
-		live
-	`, appID)
-}
+	}
+	live
+`
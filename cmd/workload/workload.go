@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"log"
@@ -20,19 +21,19 @@ var posts = flag.Int("numPosts", 10000000000, "number of posts to insert")
 var commentsPerPost = flag.Int("numCommentsPerPost", 10, "number of comments per post")
 
 var schemaStmts = []string{
-	`createtable authors (
-		id string primarykey,
+	`create table authors (
+		id string primary key,
 		name string
 	)`,
-	`createtable blog_posts (
-		id string primarykey,
-		author_id string referencestable authors,
+	`create table blog_posts (
+		id string primary key,
+		author_id string references authors,
 		title string
 	)`,
-	`createtable comments (
-		id string primarykey,
-		author_id string referencestable authors,
-		post_id string referencestable blog_posts,
+	`create table comments (
+		id string primary key,
+		author_id string references authors,
+		post_id string references blog_posts,
 		body string
 	)`,
 }
@@ -112,15 +113,26 @@ func main() {
 }
 
 func insertComments(client *treesql.Client, postID int) {
-	// Insert comments on post.
+	// Insert comments on post, in one statement.
+	values := &bytes.Buffer{}
+	var args []interface{}
 	for commentID := 0; commentID < *commentsPerPost; commentID++ {
 		commentAuthorID := rand.Intn(*numAuthors)
-		insertStmt := fmt.Sprintf(
-			`INSERT INTO comments VALUES ("%d-%d", "%d", "%d", "Bla bla bla bla bla")`,
-			postID, commentID, commentAuthorID, postID,
-		)
-		if _, err := client.Exec(insertStmt); err != nil {
-			log.Fatal(errors.Wrap(err, "inserting comment"))
+		if commentID > 0 {
+			values.WriteString(", ")
 		}
+		placeholder := len(args)
+		fmt.Fprintf(values, "($%d, $%d, $%d, $%d)", placeholder+1, placeholder+2, placeholder+3, placeholder+4)
+		args = append(
+			args,
+			fmt.Sprintf("%d-%d", postID, commentID), fmt.Sprint(commentAuthorID), fmt.Sprint(postID), "Bla bla bla bla bla",
+		)
+	}
+	if len(args) == 0 {
+		return
+	}
+	insertStmt := fmt.Sprintf(`INSERT INTO comments (id, author_id, post_id, body) VALUES %s`, values)
+	if _, err := client.Exec(insertStmt, args...); err != nil {
+		log.Fatal(errors.Wrap(err, "inserting comments"))
 	}
 }
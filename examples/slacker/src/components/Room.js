@@ -13,10 +13,10 @@ class Room extends React.Component {
   }
 
   sendMessage() {
-    // createtable messages (
-    //   id string primarykey,
-    //   room_id string referencestable rooms,
-    //   user_id string referencestable users,
+    // create table messages (
+    //   id string primary key,
+    //   room_id string references rooms,
+    //   user_id string references users,
     //   timestamp string,
     //   body string
     // )
@@ -13,11 +13,34 @@ export const tableUpdate = (queryPath, selection) => ({
 });
 
 export const RECORD_UPDATE = 'RECORD_UPDATE';
-export const recordUpdate = (queryPath, oldRecord, newRecord) => ({
+export const recordUpdate = (queryPath, oldRecord, newRecord, fields) => ({
   type: RECORD_UPDATE,
   queryPath,
   oldRecord,
-  newRecord
+  newRecord,
+  fields
+});
+
+export const RECORD_DELETE = 'RECORD_DELETE';
+export const recordDelete = (queryPath) => ({
+  type: RECORD_DELETE,
+  queryPath
+});
+
+export const WINDOW_UPDATE = 'WINDOW_UPDATE';
+export const windowUpdate = (queryPath, removed, added, order) => ({
+  type: WINDOW_UPDATE,
+  queryPath,
+  removed,
+  added,
+  order
+});
+
+export const AGGREGATE_UPDATE = 'AGGREGATE_UPDATE';
+export const aggregateUpdate = (queryPath, value) => ({
+  type: AGGREGATE_UPDATE,
+  queryPath,
+  value
 });
 
 // idk, maybe this should be in TreeSQLClient.js
@@ -30,7 +53,8 @@ export function updateToAction(update) {
       return recordUpdate(
         update.record_update.QueryPath,
         update.record_update.TableEvent.OldRecord,
-        update.record_update.TableEvent.NewRecord
+        update.record_update.TableEvent.NewRecord,
+        update.record_update.Fields
       );
 
     case 'table_update':
@@ -39,6 +63,20 @@ export function updateToAction(update) {
         update.table_update.QueryPath || [],
         update.table_update.Selection
       );
+
+    case 'record_delete':
+      return recordDelete(update.record_delete.QueryPath);
+
+    case 'window_update':
+      return windowUpdate(
+        update.window_update.QueryPath || [],
+        update.window_update.Removed || [],
+        update.window_update.Added || [],
+        update.window_update.Order || []
+      );
+
+    case 'aggregate_update':
+      return aggregateUpdate(update.aggregate_update.QueryPath, update.aggregate_update.Value);
     
     default:
       console.warn('unhandled message from live query:', update);
@@ -1,7 +1,10 @@
 import {
   INITIAL_RESULT,
   RECORD_UPDATE,
-  TABLE_UPDATE
+  TABLE_UPDATE,
+  RECORD_DELETE,
+  WINDOW_UPDATE,
+  AGGREGATE_UPDATE
 } from './liveQueryActions';
 
 const initialState = {
@@ -22,9 +25,42 @@ export default function update(state = initialState, action) {
 
     case RECORD_UPDATE:
       return {
-        tree: updateAtSelection(state.tree, action.queryPath, action.newRecord)
+        tree: updateAtSelection(state.tree, action.queryPath, action.fields)
       }
 
+    case RECORD_DELETE: {
+      // the path leads to the record, in the list it's in
+      const path = action.queryPath;
+      const id = path[path.length - 1].id;
+      return {
+        tree: updateList(state.tree, path.slice(0, -1), (records) => (
+          records.filter((record) => String(record.id) !== id)
+        ))
+      };
+    }
+
+    case WINDOW_UPDATE:
+      // ordered or limited selections say which records left and entered
+      // them, and the order of the records now in them
+      return {
+        tree: updateList(state.tree, action.queryPath, (records) => {
+          const byID = {};
+          records.forEach((record) => { byID[String(record.id)] = record; });
+          action.removed.forEach((id) => { delete byID[id]; });
+          action.added.forEach((record) => { byID[String(record.id)] = record; });
+          return action.order.map((id) => byID[id]).filter((record) => record);
+        })
+      };
+
+    case AGGREGATE_UPDATE: {
+      // the path leads to the aggregate selection, on the record it's in
+      const path = action.queryPath;
+      const fieldName = path[path.length - 1].selection;
+      return {
+        tree: updateAtSelection(state.tree, path.slice(0, -1), { [fieldName]: action.value })
+      };
+    }
+
     default:
       return state;
   }
@@ -53,8 +89,7 @@ function updateAtSelection(records, path, selection) {
 
 function updateAtRecord(record, path, selection) {
   if (path.length === 0) {
-    // replacing with NewRecord, which may have fields that weren't in the original selction...
-    // would be good just to send the keys in your selection
+    // fields are just the keys in the selection, including computed ones
     return {
       ...record,
       ...selection
@@ -67,3 +102,21 @@ function updateAtRecord(record, path, selection) {
     }
   }
 }
+
+// updateList replaces the list of records the path leads to
+// with the result of calling fn on it.
+function updateList(records, path, fn) {
+  if (path.length === 0) {
+    return fn(records);
+  }
+  const id = path[0].id;
+  const fieldName = path[1].selection;
+  return records.map((record) => (
+    String(record.id) === id
+    ? {
+      ...record,
+      [fieldName]: updateList(record[fieldName], path.slice(2), fn)
+    }
+    : record
+  ));
+}
@@ -3,7 +3,6 @@ module github.com/vilterp/treesql
 go 1.12
 
 require (
-	github.com/alecthomas/participle v0.0.0-20170321041916-8e70e19ffcc2
 	github.com/beorn7/perks v0.0.0-20160804104726-4c0e84591b9a // indirect
 	github.com/boltdb/bolt v0.0.0-20161028193645-4b1ebc1869ad
 	github.com/chzyer/logex v1.1.10 // indirect
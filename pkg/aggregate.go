@@ -0,0 +1,198 @@
+package treesql
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// asSelect returns the MANY selection this aggregate is computed over,
+// so that it can be validated and joined like one.
+func (aggregate *Aggregate) asSelect() *Select {
+	return &Select{
+		Pos:   aggregate.Pos,
+		Many:  true,
+		Table: aggregate.Table,
+		Via:   aggregate.Via,
+		Where: aggregate.Where,
+	}
+}
+
+func (db *Database) validateAggregate(aggregate *Aggregate, tableAbove string) error {
+	// table exists, references the table above, and where clause is valid
+	if err := db.validateSelect(aggregate.asSelect(), &tableAbove); err != nil {
+		return err
+	}
+	if aggregate.ColumnName == nil {
+		if aggregate.Function != "COUNT" {
+			return errorAt(aggregate.Pos, "", &AggregateNeedsColumn{Function: aggregate.Function})
+		}
+		return nil
+	}
+	// column exists
+	table := db.Schema.Tables[aggregate.Table]
+	column := table.getColumn(*aggregate.ColumnName)
+	if column == nil {
+		return errorAt(aggregate.Pos, *aggregate.ColumnName, &NoSuchColumn{
+			TableName:  aggregate.Table,
+			ColumnName: *aggregate.ColumnName,
+		})
+	}
+	// column is numeric, if need be
+	numeric := column.Type == TypeInt || column.Type == TypeFloat
+	if (aggregate.Function == "SUM" || aggregate.Function == "AVG") && !numeric {
+		return errorAt(aggregate.Pos, "", &AggregateWrongType{Function: aggregate.Function, Type: column.Type})
+	}
+	return nil
+}
+
+func (ex *SelectExecution) executeAggregate(
+	selection *Selection,
+	outerTable *TableDescriptor,
+	outerRecord *Record,
+	outerScope *Scope,
+) (interface{}, error) {
+	aggregate := selection.Aggregate
+	table := ex.Channel.Connection.Database.Schema.Tables[aggregate.Table]
+	// join to the record above the same way a MANY would
+	outerKey := outerRecord.primaryKey()
+	scope := &Scope{
+		table:         outerTable,
+		document:      outerRecord,
+		selectionName: selection.Name,
+		pathSoFar: &QueryPath{
+			Selection: &selection.Name,
+			PreviousSegment: &QueryPath{
+				ID:              &outerKey,
+				PreviousSegment: ex.pathSoFar(outerScope),
+			},
+		},
+	}
+	query := aggregate.asSelect()
+	filterCondition := getFilterCondition(query, table, scope)
+
+	outerValue := outerRecord.GetField(filterCondition.OuterColumnName)
+	where := NewEqualsExpr(filterCondition.InnerColumnName, outerValue).And(aggregate.Where)
+	value := ex.computeAggregate(aggregate, table, where)
+
+	// Listen for writes to the records being aggregated.
+	if ex.Query.Live {
+		table.LiveQueryInfo.TableSubscriptionEvents <- &TableSubscriptionEvent{
+			ColumnName:     &filterCondition.InnerColumnName,
+			Value:          outerRecord.GetField(filterCondition.OuterColumnName),
+			SubQuery:       query,
+			QueryExecution: ex,
+			QueryPath:      scope.pathSoFar,
+			Aggregate:      aggregate,
+			AggregateValue: value,
+		}
+	}
+	return value, nil
+}
+
+// computeAggregate scans the table, aggregating the records that
+// satisfy the condition. Sums of float columns are floats.
+func (ex *SelectExecution) computeAggregate(
+	aggregate *Aggregate,
+	table *TableDescriptor,
+	where *Expr,
+) interface{} {
+	iterator, _ := ex.getScanIterator(table, where)
+	defer iterator.Close()
+
+	count := 0
+	sum := 0
+	floatSum := 0.0
+	var min *Value
+	var max *Value
+	for record := iterator.Next(); record != nil; record = iterator.Next() {
+		if where != nil && !where.Evaluate(record) {
+			continue
+		}
+		if aggregate.ColumnName == nil {
+			count++
+			continue
+		}
+		value := record.GetField(*aggregate.ColumnName)
+		if value.Null {
+			continue
+		}
+		count++
+		sum += value.IntVal
+		floatSum += value.FloatVal
+		if min == nil || value.Compare(min) < 0 {
+			min = value
+		}
+		if max == nil || value.Compare(max) > 0 {
+			max = value
+		}
+	}
+
+	isFloat := aggregate.ColumnName != nil && table.getColumn(*aggregate.ColumnName).Type == TypeFloat
+	switch aggregate.Function {
+	case "COUNT":
+		return count
+	case "SUM":
+		if isFloat {
+			return floatSum
+		}
+		return sum
+	case "AVG":
+		if count == 0 {
+			return nil
+		}
+		if isFloat {
+			return floatSum / float64(count)
+		}
+		return float64(sum) / float64(count)
+	case "MIN":
+		return min.jsonValue()
+	case "MAX":
+		return max.jsonValue()
+	}
+	panic(fmt.Sprintf("unknown aggregate function %s", aggregate.Function))
+}
+
+// liveAggregate is the state of an aggregate listener:
+// the value the client has.
+type liveAggregate struct {
+	mu        sync.Mutex
+	aggregate *Aggregate
+	value     interface{}
+}
+
+// refresh recomputes an aggregate listener's value after a
+// write to its table, and sends it to the client if it changed.
+func (state *liveAggregate) refresh(listener *Listener) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	ex := listener.QueryExecution
+	database := ex.Channel.Connection.Database
+	table := database.Schema.Tables[state.aggregate.Table]
+	where := listener.Filter.And(state.aggregate.Where)
+
+	tx, err := database.BoltDB.Begin(false)
+	if err != nil {
+		log.Println("failed to refresh aggregate for statement id", ex.ID, ":", err)
+		return
+	}
+	defer tx.Rollback()
+	refreshExecution := &SelectExecution{
+		ID:          ex.ID,
+		Channel:     ex.Channel,
+		Query:       listener.Query,
+		Transaction: tx,
+		Context:     ex.Context,
+	}
+	value := refreshExecution.computeAggregate(state.aggregate, table, where)
+
+	if fmt.Sprint(value) == fmt.Sprint(state.value) {
+		return
+	}
+	state.value = value
+	ex.Channel.WriteAggregateUpdate(&AggregateUpdate{
+		QueryPath: listener.QueryPath.Flatten(),
+		Value:     value,
+	})
+}
@@ -0,0 +1,453 @@
+package treesql
+
+import (
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	clog "github.com/vilterp/treesql/pkg/log"
+)
+
+func (db *Database) validateAlterTable(alter *AlterTable) error {
+	// table exists, and isn't a builtin
+	table, ok := db.Schema.Tables[alter.Name]
+	if !ok {
+		return errorAt(alter.Pos, alter.Name, &NoSuchTable{TableName: alter.Name})
+	}
+	if isBuiltinTable(alter.Name) {
+		return errorAt(alter.Pos, alter.Name, &BuiltinWriteAttempt{TableName: alter.Name})
+	}
+	switch {
+	case alter.AddColumn != nil:
+		column := alter.AddColumn
+		if table.getColumn(column.Name) != nil {
+			return errorAt(column.Pos, column.Name, &ColumnAlreadyExists{TableName: alter.Name, ColumnName: column.Name})
+		}
+		if _, knownType := columnTypeOf(column.TypeName); !knownType {
+			return errorAt(column.Pos, column.TypeName, &NonexistentType{TypeName: column.TypeName})
+		}
+		if err := validateDefault(alter.Name, column); err != nil {
+			return err
+		}
+		if column.PrimaryKey {
+			return errorAt(column.Pos, "PRIMARY", &WrongNoPrimaryKey{Count: 2})
+		}
+		columnType, _ := columnTypeOf(column.TypeName)
+		withColumn := &TableDescriptor{
+			Name:    alter.Name,
+			Columns: append(append([]*ColumnDescriptor{}, table.Columns...), &ColumnDescriptor{Name: column.Name, Type: columnType}),
+		}
+		if err := db.validateConstraints(withColumn, []*CreateTableColumn{column}, nil); err != nil {
+			return err
+		}
+		if column.References != nil {
+			return db.validateReference(alter, column.Pos, column.Name, columnType, *column.References)
+		}
+
+	case alter.DropColumn != nil:
+		column := alter.DropColumn
+		if table.getColumn(column.Name) == nil {
+			return errorAt(column.Pos, column.Name, &NoSuchColumn{TableName: alter.Name, ColumnName: column.Name})
+		}
+		if column.Name == table.PrimaryKey {
+			return errorAt(column.Pos, column.Name, &DropPrimaryKey{TableName: alter.Name, ColumnName: column.Name})
+		}
+
+	case alter.RenameColumn != nil:
+		rename := alter.RenameColumn
+		if table.getColumn(rename.Name) == nil {
+			return errorAt(rename.Pos, rename.Name, &NoSuchColumn{TableName: alter.Name, ColumnName: rename.Name})
+		}
+		if table.getColumn(rename.NewName) != nil {
+			return errorAt(rename.Pos, rename.NewName, &ColumnAlreadyExists{TableName: alter.Name, ColumnName: rename.NewName})
+		}
+
+	case alter.AddReference != nil:
+		reference := alter.AddReference
+		column := table.getColumn(reference.Name)
+		if column == nil {
+			return errorAt(reference.Pos, reference.Name, &NoSuchColumn{TableName: alter.Name, ColumnName: reference.Name})
+		}
+		if column.ReferencesColumn != nil {
+			return errorAt(reference.Pos, reference.Name, &ColumnAlreadyReferences{
+				TableName:  alter.Name,
+				ColumnName: reference.Name,
+				References: column.ReferencesColumn.TableName,
+			})
+		}
+		return db.validateReference(alter, reference.Pos, column.Name, column.Type, reference.References)
+
+	case alter.DropReference != nil:
+		reference := alter.DropReference
+		column := table.getColumn(reference.Name)
+		if column == nil {
+			return errorAt(reference.Pos, reference.Name, &NoSuchColumn{TableName: alter.Name, ColumnName: reference.Name})
+		}
+		if column.ReferencesColumn == nil {
+			return errorAt(reference.Pos, reference.Name, &NoReference{TableName: alter.Name, ColumnName: reference.Name})
+		}
+	}
+	return nil
+}
+
+// validateReference checks that the referenced table exists (or is the
+// altered one, e.g. for trees of comments) and isn't a builtin, and that
+// its primary key is the same type as the referencing column.
+func (db *Database) validateReference(
+	alter *AlterTable, pos Position, columnName string, columnType ColumnType, references string,
+) error {
+	referenced, tableExists := db.Schema.Tables[references]
+	if !tableExists {
+		return errorAt(pos, references, &NoSuchTable{TableName: references})
+	}
+	if isBuiltinTable(references) {
+		return errorAt(pos, references, &ReferenceToBuiltinTable{
+			TableName:  alter.Name,
+			ColumnName: columnName,
+			References: references,
+		})
+	}
+	if primaryKeyType := referenced.getColumn(referenced.PrimaryKey).Type; columnType != primaryKeyType {
+		return errorAt(pos, references, &ReferenceTypeMismatch{
+			TableName:      alter.Name,
+			ColumnName:     columnName,
+			ColumnType:     columnType,
+			References:     references,
+			PrimaryKeyType: primaryKeyType,
+		})
+	}
+	return nil
+}
+
+func (conn *Connection) ExecuteAlterTable(alter *AlterTable, channel *Channel) error {
+	db := conn.Database
+	table := db.Schema.Tables[alter.Name]
+
+	// Work out the table's new columns. oldColumn and newColumn are the
+	// altered column before and after; nil if it's being added or dropped.
+	columns := make([]*ColumnDescriptor, 0, len(table.Columns)+1)
+	var oldColumn, newColumn *ColumnDescriptor
+	primaryKey := table.PrimaryKey
+	if alter.AddColumn != nil {
+		newColumn = db.newColumn(alter.AddColumn)
+		columns = append(columns, table.Columns...)
+		columns = append(columns, newColumn)
+	} else {
+		oldColumn = table.getColumn(alter.columnName())
+		altered := *oldColumn
+		switch {
+		case alter.RenameColumn != nil:
+			altered.Name = alter.RenameColumn.NewName
+			if oldColumn.Name == table.PrimaryKey {
+				primaryKey = altered.Name
+			}
+		case alter.AddReference != nil:
+			reference := alter.AddReference
+			altered.ReferencesColumn = newColumnReference(reference.References, reference.OnDelete, reference.OnUpdate)
+			altered.Indexed = true
+		case alter.DropReference != nil:
+			altered.ReferencesColumn = nil
+		}
+		if alter.DropColumn == nil {
+			newColumn = &altered
+		}
+		for _, column := range table.Columns {
+			if column != oldColumn {
+				columns = append(columns, column)
+			} else if newColumn != nil {
+				columns = append(columns, newColumn)
+			}
+		}
+	}
+
+	// Work out the table's new constraints: ones declared on an added
+	// column are added, and ones on a dropped or renamed column are dropped
+	// or changed. oldConstraints and newConstraints are the changed ones
+	// before and after, pairwise; nil where one is being added or dropped.
+	constraints := table.Constraints
+	var oldConstraints, newConstraints []*Constraint
+	switch {
+	case alter.AddColumn != nil:
+		added := db.newConstraints(table.Name, []*CreateTableColumn{alter.AddColumn}, nil)
+		newColumn.Indexed = newColumn.Indexed || leadsUnique(added, newColumn.Name)
+		constraints = append(append([]*Constraint{}, table.Constraints...), added...)
+		oldConstraints = make([]*Constraint, len(added))
+		newConstraints = added
+	case alter.DropColumn != nil || alter.RenameColumn != nil:
+		constraints = nil
+		for _, constraint := range table.Constraints {
+			if !constraint.involves(alter.columnName()) {
+				constraints = append(constraints, constraint)
+				continue
+			}
+			var altered *Constraint
+			if alter.RenameColumn != nil {
+				altered = constraint.withColumnRenamed(alter.RenameColumn.Name, alter.RenameColumn.NewName)
+				constraints = append(constraints, altered)
+			}
+			oldConstraints = append(oldConstraints, constraint)
+			newConstraints = append(newConstraints, altered)
+		}
+	}
+
+	// The altered table gets a new descriptor, which replaces the old one
+	// once the change is committed; records and listeners which still
+	// point to the old one keep seeing it as it was.
+	alteredTable := &TableDescriptor{
+		Name:          table.Name,
+		Columns:       columns,
+		PrimaryKey:    primaryKey,
+		Constraints:   constraints,
+		LiveQueryInfo: table.LiveQueryInfo,
+	}
+
+	var oldColumnRecord, newColumnRecord *Record
+	var oldIndexRecord, newIndexRecord *Record
+	if oldColumn != nil {
+		oldColumnRecord = oldColumn.ToRecord(table.Name, db)
+		if oldColumn.Indexed {
+			oldIndexRecord = oldColumn.IndexToRecord(table.Name, db)
+		}
+	}
+	if newColumn != nil {
+		newColumnRecord = newColumn.ToRecord(table.Name, db)
+		if newColumn.Indexed {
+			newIndexRecord = newColumn.IndexToRecord(table.Name, db)
+		}
+	}
+	// an added reference or UNIQUE column is indexed, unless the column
+	// already was
+	indexAdded := oldIndexRecord == nil && newIndexRecord != nil
+	oldConstraintRecords := make([]*Record, len(oldConstraints))
+	newConstraintRecords := make([]*Record, len(newConstraints))
+	for idx := range oldConstraints {
+		if oldConstraints[idx] != nil {
+			oldConstraintRecords[idx] = oldConstraints[idx].ToRecord(table.Name, db)
+		}
+		if newConstraints[idx] != nil {
+			newConstraintRecords[idx] = newConstraints[idx].ToRecord(table.Name, db)
+		}
+	}
+	renamedPrimaryKey := primaryKey != table.PrimaryKey
+	oldTableRecord := table.ToRecord(db)
+	newTableRecord := alteredTable.ToRecord(db)
+	updateErr := db.BoltDB.Update(func(tx *bolt.Tx) error {
+		// write the column's row in __columns__
+		columnsBucket := tx.Bucket([]byte("__columns__"))
+		if newColumn != nil {
+			key := []byte(fmt.Sprintf("%d", newColumn.ID))
+			if err := columnsBucket.Put(key, newColumnRecord.ToBytes()); err != nil {
+				return err
+			}
+		} else {
+			if err := columnsBucket.Delete([]byte(fmt.Sprintf("%d", oldColumn.ID))); err != nil {
+				return err
+			}
+			if err := dropSerial(tx, oldColumn); err != nil {
+				return err
+			}
+			if err := dropIndex(tx, oldColumn); err != nil {
+				return err
+			}
+		}
+		// and the changed constraints' rows in __constraints__
+		constraintsBucket := tx.Bucket([]byte("__constraints__"))
+		for idx, newConstraint := range newConstraints {
+			if newConstraint == nil {
+				if err := constraintsBucket.Delete([]byte(oldConstraints[idx].Name)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := constraintsBucket.Put([]byte(newConstraint.Name), newConstraintRecords[idx].ToBytes()); err != nil {
+				return err
+			}
+		}
+		if renamedPrimaryKey {
+			if err := tx.Bucket([]byte("__tables__")).Put([]byte(table.Name), newTableRecord.ToBytes()); err != nil {
+				return err
+			}
+		}
+		if alter.AddColumn != nil {
+			db.Schema.NextColumnID++
+			if err := db.saveNextColumnID(tx); err != nil {
+				return err
+			}
+		}
+		// records are encoded by position, so adding or dropping a
+		// column means rewriting all of them
+		if len(columns) != len(table.Columns) {
+			if err := migrateRecords(tx, table, alteredTable); err != nil {
+				return err
+			}
+		}
+		if indexAdded {
+			if err := buildIndex(tx, alteredTable, newColumn); err != nil {
+				return err
+			}
+		}
+		// existing records have to satisfy an added column's constraints,
+		// and an added reference
+		if alter.AddColumn != nil {
+			if err := checkTableConstraints(tx, alteredTable, newConstraints); err != nil {
+				return err
+			}
+		}
+		if alter.AddColumn != nil || alter.AddReference != nil {
+			return checkColumnReferences(tx, alteredTable, newColumn)
+		}
+		return nil
+	})
+	if updateErr != nil {
+		return errors.Wrap(updateErr, "altering table")
+	}
+
+	// update in-memory schema
+	db.Schema.tablesMu.Lock()
+	db.Schema.Tables[table.Name] = alteredTable
+	db.Schema.tablesMu.Unlock()
+	// push live query messages
+	db.PushTableEvent(channel, "__columns__", oldColumnRecord, newColumnRecord)
+	if oldIndexRecord != nil || newIndexRecord != nil {
+		db.PushTableEvent(channel, "__indexes__", oldIndexRecord, newIndexRecord)
+	}
+	for idx := range oldConstraintRecords {
+		db.PushTableEvent(channel, "__constraints__", oldConstraintRecords[idx], newConstraintRecords[idx])
+	}
+	if renamedPrimaryKey {
+		db.PushTableEvent(channel, "__tables__", oldTableRecord, newTableRecord)
+	}
+	db.rerunLiveQueries(table.Name)
+
+	clog.Println(channel, "altered table", alter.Name)
+	channel.WriteAckMessage("ALTER TABLE")
+	return nil
+}
+
+// columnName returns the name of the existing column being altered.
+func (alter *AlterTable) columnName() string {
+	switch {
+	case alter.DropColumn != nil:
+		return alter.DropColumn.Name
+	case alter.RenameColumn != nil:
+		return alter.RenameColumn.Name
+	case alter.AddReference != nil:
+		return alter.AddReference.Name
+	case alter.DropReference != nil:
+		return alter.DropReference.Name
+	}
+	return ""
+}
+
+// migrateRecords rewrites the table's records from its column layout to
+// altered's. Columns are matched up by ID; added columns get their default,
+// or start out null, so a NOT NULL column without a default can only be
+// added to an empty table.
+func migrateRecords(tx *bolt.Tx, table *TableDescriptor, altered *TableDescriptor) error {
+	bucket := tx.Bucket([]byte(table.Name))
+	// Bolt doesn't allow writes while iterating
+	migrated := map[string][]byte{}
+	iterErr := bucket.ForEach(func(key []byte, value []byte) error {
+		oldRecord := table.RecordFromBytes(value)
+		newRecord := altered.NewRecord()
+		for newIdx, column := range altered.Columns {
+			added := true
+			for oldIdx, oldColumn := range table.Columns {
+				if oldColumn.ID == column.ID {
+					newRecord.Values[newIdx] = oldRecord.Values[oldIdx]
+					added = false
+					break
+				}
+			}
+			if !added {
+				continue
+			}
+			if column.generated() {
+				value, err := generateValue(tx, altered, column)
+				if err != nil {
+					return err
+				}
+				newRecord.SetValue(column.Name, value)
+			} else if column.NotNull {
+				return &NullViolation{TableName: table.Name, ColumnName: column.Name}
+			}
+		}
+		migrated[string(key)] = newRecord.ToBytes()
+		return nil
+	})
+	if iterErr != nil {
+		return iterErr
+	}
+	for key, value := range migrated {
+		if err := bucket.Put([]byte(key), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rerunLiveQueries re-runs the live queries which read from the given
+// table after its columns have changed, from scratch: their listeners
+// and records may refer to columns which no longer exist. Each gets a
+// new initial result, or the validation error which now stops it from
+// running, which ends it. They're re-run by the connections they're on,
+// between statements.
+func (db *Database) rerunLiveQueries(tableName string) {
+	db.connectionsMu.Lock()
+	defer db.connectionsMu.Unlock()
+	for _, conn := range db.Connections {
+		conn.queueRerun(tableName)
+	}
+}
+
+// queueRerun queues re-running the connection's live queries which read
+// from the given table, on its goroutine.
+func (conn *Connection) queueRerun(tableName string) {
+	conn.reruns.Lock()
+	conn.reruns.tableNames = append(conn.reruns.tableNames, tableName)
+	conn.reruns.Unlock()
+	select {
+	case conn.rerunsQueued <- struct{}{}:
+	default: // it'll already see the queue
+	}
+}
+
+// handleReruns re-runs the live queries queued by queueRerun.
+func (conn *Connection) handleReruns() {
+	conn.reruns.Lock()
+	tableNames := conn.reruns.tableNames
+	conn.reruns.tableNames = nil
+	conn.reruns.Unlock()
+	for _, tableName := range tableNames {
+		for _, channel := range conn.Channels {
+			statement, err := Parse(channel.RawStatement)
+			if err != nil || statement.Select == nil || !statement.Select.Live {
+				continue
+			}
+			if !statement.Select.readsFrom(tableName) {
+				continue
+			}
+			for _, table := range conn.Database.Schema.Tables {
+				table.removeListenersForChannel(channel)
+			}
+			channel.HandleStatement()
+		}
+	}
+}
+
+// readsFrom returns whether the selection, or any nested in it,
+// reads from the given table.
+func (query *Select) readsFrom(tableName string) bool {
+	if query.Table == tableName {
+		return true
+	}
+	for _, selection := range query.Selections {
+		if selection.SubSelect != nil && selection.SubSelect.readsFrom(tableName) {
+			return true
+		}
+		if selection.Aggregate != nil && selection.Aggregate.Table == tableName {
+			return true
+		}
+	}
+	return false
+}
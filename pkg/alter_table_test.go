@@ -0,0 +1,238 @@
+package treesql
+
+import (
+	"testing"
+)
+
+func TestAlterTable(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE users (id string PRIMARY KEY, name string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, title string, body string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "hello world", "first post")`,
+			ack:  "INSERT 1",
+		},
+		// Verify that the table and columns are checked.
+		{
+			stmt:  `ALTER TABLE posts DROP COLUMN body`,
+			error: "validation error: no such table: posts",
+		},
+		{
+			stmt:  `ALTER TABLE __tables__ DROP COLUMN primary_key`,
+			error: "validation error: attemtped to write to __tables__, but builtin tables are read-only",
+		},
+		{
+			stmt:  `ALTER TABLE blog_posts ADD COLUMN title string`,
+			error: "validation error: column already exists in table blog_posts: title",
+		},
+		{
+			stmt:  `ALTER TABLE blog_posts ADD COLUMN views number`,
+			error: "validation error: nonexistent type: number",
+		},
+		{
+			stmt:  `ALTER TABLE blog_posts ADD COLUMN slug string PRIMARY KEY`,
+			error: "validation error: tables should have exactly one column marked \"primary key\"; given 2",
+		},
+		{
+			stmt:  `ALTER TABLE blog_posts DROP COLUMN id`,
+			error: "validation error: can't drop column blog_posts.id, since it's the primary key",
+		},
+		{
+			stmt:  `ALTER TABLE blog_posts RENAME COLUMN body TO title`,
+			error: "validation error: column already exists in table blog_posts: title",
+		},
+		{
+			stmt:  `ALTER TABLE blog_posts ADD REFERENCE author_id REFERENCES users`,
+			error: "validation error: no such column in table blog_posts: author_id",
+		},
+		{
+			stmt:  `ALTER TABLE blog_posts ADD COLUMN index_id string REFERENCES __indexes__`,
+			error: "validation error: column blog_posts.index_id can't reference builtin table __indexes__",
+		},
+		{
+			stmt:  `ALTER TABLE blog_posts ADD REFERENCE title REFERENCES __tables__`,
+			error: "validation error: column blog_posts.title can't reference builtin table __tables__",
+		},
+		{
+			stmt:  `ALTER TABLE blog_posts DROP REFERENCE title`,
+			error: "validation error: column blog_posts.title doesn't reference a table",
+		},
+		// Happy path: existing records are migrated.
+		{
+			stmt: `ALTER TABLE blog_posts ADD COLUMN author_id string`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			stmt: `ALTER TABLE blog_posts DROP COLUMN title`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			stmt: `ALTER TABLE blog_posts RENAME COLUMN body TO text`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			query: `MANY blog_posts { * }`,
+			initialResult: `[
+  {
+    "author_id": null,
+    "id": "0",
+    "text": "first post"
+  }
+]`,
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("1", "second post", "0")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO users VALUES ("0", "pete")`,
+			ack:  "INSERT 1",
+		},
+		{
+			query: `MANY blog_posts { id, author: ONE users { name } }`,
+			error: "validation error: query requires a column in table `blog_posts` referencing table `users`; none found",
+		},
+		{
+			stmt: `ALTER TABLE blog_posts ADD REFERENCE author_id REFERENCES users`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			stmt:  `ALTER TABLE blog_posts ADD REFERENCE author_id REFERENCES blog_posts`,
+			error: "validation error: column blog_posts.author_id already references table users",
+		},
+		{
+			query: `MANY users { name, posts: MANY blog_posts { text } }`,
+			initialResult: `[
+  {
+    "name": "pete",
+    "posts": [
+      {
+        "text": "second post"
+      }
+    ]
+  }
+]`,
+		},
+		{
+			stmt: `ALTER TABLE blog_posts DROP REFERENCE author_id`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			query: `MANY __columns__ WHERE table_name = "blog_posts" { name, references }`,
+			initialResult: `[
+  {
+    "name": "id",
+    "references": null
+  },
+  {
+    "name": "text",
+    "references": null
+  },
+  {
+    "name": "author_id",
+    "references": null
+  }
+]`,
+		},
+		// Renaming the primary key renames it in __tables__ too.
+		{
+			stmt: `ALTER TABLE users RENAME COLUMN id TO user_id`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			query: `MANY __tables__ WHERE name = "users" { primary_key }`,
+			initialResult: `[
+  {
+    "primary_key": "user_id"
+  }
+]`,
+		},
+	})
+}
+
+func TestLiveAlterTable(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+		`INSERT INTO blog_posts VALUES ("0", "hello world")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, starChan, err := client.LiveQuery(`LIVE MANY blog_posts { * }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	starUpdates := bufferUpdates(starChan)
+	_, titleChan, err := client.LiveQuery(`LIVE MANY blog_posts { title }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titleUpdates := bufferUpdates(titleChan)
+	_, columnsChan, err := client.LiveQuery(`LIVE MANY __columns__ { name }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	columnsUpdates := bufferUpdates(columnsChan)
+
+	if _, err := client.Exec(`ALTER TABLE blog_posts RENAME COLUMN title TO headline`); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rename shows up in __columns__.
+	msg := <-columnsUpdates
+	if msg.Type != RecordUpdateMessage {
+		t.Fatalf("expected %v but got %v", RecordUpdateMessage, msg.Type)
+	}
+	if name := msg.RecordUpdateMessage.Fields["name"]; name != "headline" {
+		t.Fatalf("expected name headline; got %v", name)
+	}
+	// Queries which are still valid are re-run...
+	msg = <-starUpdates
+	if msg.Type != InitialResultMessage {
+		t.Fatalf("expected %v but got %v", InitialResultMessage, msg.Type)
+	}
+	if headline := msg.InitialResultMessage.Data[0]["headline"]; headline != "hello world" {
+		t.Fatalf("expected headline to be hello world; got %v", headline)
+	}
+	// ...and those which aren't are ended.
+	msg = <-titleUpdates
+	if msg.Type != ErrorMessage {
+		t.Fatalf("expected %v but got %v", ErrorMessage, msg.Type)
+	}
+	if msg.ErrorDetail.Code != "no_such_column" {
+		t.Fatalf("expected code no_such_column; got %s", msg.ErrorDetail.Code)
+	}
+
+	// The re-run query is live under the new name.
+	if _, err := client.Exec(`UPDATE blog_posts SET headline = "hello again world" WHERE id = "0"`); err != nil {
+		t.Fatal(err)
+	}
+	msg = <-starUpdates
+	if msg.Type != RecordUpdateMessage {
+		t.Fatalf("expected %v but got %v", RecordUpdateMessage, msg.Type)
+	}
+	if headline := msg.RecordUpdateMessage.Fields["headline"]; headline != "hello again world" {
+		t.Fatalf("expected headline to be hello again world; got %v", headline)
+	}
+	select {
+	case msg := <-titleUpdates:
+		t.Fatalf("expected no more updates; got %v", msg.Type)
+	default:
+	}
+}
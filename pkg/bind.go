@@ -0,0 +1,245 @@
+package treesql
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Bind replaces the statement's placeholders (`$1`, `$2`, ...) with
+// the given arguments, which may be strings, numbers, bools or nil (NULL).
+// It should be called after parsing and before validation.
+func (statement *Statement) Bind(args []interface{}) error {
+	binder := &binder{args: args}
+	switch {
+	case statement.Select != nil:
+		binder.bindSelect(statement.Select)
+	case statement.Insert != nil:
+		for _, row := range statement.Insert.Rows {
+			for _, value := range row.Values {
+				binder.bindLiteral(value)
+			}
+		}
+		if statement.Insert.OnConflict != nil {
+			for _, assignment := range statement.Insert.OnConflict.Assignments {
+				binder.bindValueExpr(assignment.Value)
+			}
+		}
+		if statement.Insert.Returning != nil {
+			binder.bindSelect(statement.Insert.Returning)
+		}
+	case statement.Update != nil:
+		for _, assignment := range statement.Update.Assignments {
+			binder.bindValueExpr(assignment.Value)
+		}
+		binder.bindExpr(statement.Update.Where)
+		if statement.Update.Returning != nil {
+			binder.bindSelect(statement.Update.Returning)
+		}
+	case statement.Delete != nil:
+		binder.bindExpr(statement.Delete.Where)
+		if statement.Delete.Returning != nil {
+			binder.bindSelect(statement.Delete.Returning)
+		}
+	case statement.CreateTable != nil:
+		for _, column := range statement.CreateTable.Columns {
+			binder.bindColumn(column)
+		}
+		for _, constraint := range statement.CreateTable.Constraints {
+			if constraint.Check != nil {
+				binder.bindExpr(constraint.Check)
+			}
+		}
+	case statement.AlterTable != nil && statement.AlterTable.AddColumn != nil:
+		binder.bindColumn(statement.AlterTable.AddColumn)
+	}
+	if binder.err != nil {
+		return binder.err
+	}
+	if binder.maxIndex != len(args) {
+		return &WrongNumArguments{Wanted: binder.maxIndex, Got: len(args)}
+	}
+	return nil
+}
+
+type binder struct {
+	args     []interface{}
+	maxIndex int // highest placeholder index seen
+	err      error
+}
+
+// arg returns the argument for a placeholder like `$1`,
+// or false if there aren't enough arguments.
+func (b *binder) arg(placeholder string) (interface{}, bool) {
+	index, _ := strconv.Atoi(placeholder[1:])
+	if index > b.maxIndex {
+		b.maxIndex = index
+	}
+	if index > len(b.args) {
+		return nil, false
+	}
+	return b.args[index-1], true
+}
+
+func (b *binder) bindSelect(query *Select) {
+	if query.Where != nil {
+		b.bindExpr(query.Where)
+	}
+	for _, selection := range query.Selections {
+		if selection.SubSelect != nil {
+			b.bindSelect(selection.SubSelect)
+		}
+		if selection.Aggregate != nil && selection.Aggregate.Where != nil {
+			b.bindExpr(selection.Aggregate.Where)
+		}
+		if selection.Expr != nil {
+			b.bindValueExpr(selection.Expr)
+		}
+	}
+}
+
+func (b *binder) bindColumn(column *CreateTableColumn) {
+	if column.Default != nil {
+		b.bindValueExpr(column.Default)
+	}
+	if column.Check != nil {
+		b.bindExpr(column.Check)
+	}
+}
+
+func (b *binder) bindExpr(expr *Expr) {
+	for _, and := range expr.Or {
+		for _, not := range and.And {
+			if not.Predicate.Parens != nil {
+				b.bindExpr(not.Predicate.Parens)
+				continue
+			}
+			comparison := not.Predicate.Comparison
+			b.bindTerm(comparison.Left)
+			if comparison.Right != nil {
+				b.bindTerm(comparison.Right)
+			}
+			if comparison.Between != nil {
+				b.bindTerm(comparison.Between.Low)
+				b.bindTerm(comparison.Between.High)
+			}
+		}
+	}
+}
+
+func (b *binder) bindValueExpr(expr *ValueExpr) {
+	b.bindSum(expr.Left)
+	for _, op := range expr.Rest {
+		b.bindSum(op.Right)
+	}
+}
+
+func (b *binder) bindSum(sum *Sum) {
+	b.bindProduct(sum.Left)
+	for _, op := range sum.Rest {
+		b.bindProduct(op.Right)
+	}
+}
+
+func (b *binder) bindProduct(product *Product) {
+	b.bindFactor(product.Left)
+	for _, op := range product.Rest {
+		b.bindFactor(op.Right)
+	}
+}
+
+func (b *binder) bindFactor(factor *Factor) {
+	if factor.Parens != nil {
+		b.bindValueExpr(factor.Parens)
+		return
+	}
+	b.bindTerm(factor.Term)
+	if factor.Call != nil {
+		for _, arg := range factor.Call.Args {
+			b.bindValueExpr(arg)
+		}
+	}
+}
+
+func (b *binder) bindTerm(term *Term) {
+	if term.Placeholder == nil {
+		return
+	}
+	arg, ok := b.arg(*term.Placeholder)
+	if !ok {
+		return
+	}
+	if arg == nil {
+		term.Null = true
+		term.Placeholder = nil
+		return
+	}
+	if number, ok := argNumber(arg); ok {
+		term.Number = &number
+		term.Placeholder = nil
+		return
+	}
+	if boolVal, ok := arg.(bool); ok {
+		term.Bool = &boolVal
+		term.Placeholder = nil
+		return
+	}
+	if str, ok := arg.(string); ok {
+		term.String = &str
+		term.Placeholder = nil
+		return
+	}
+	b.fail(term.Pos, *term.Placeholder, arg)
+}
+
+func (b *binder) bindLiteral(literal *Literal) {
+	if literal.Placeholder == nil {
+		return
+	}
+	arg, ok := b.arg(*literal.Placeholder)
+	if !ok {
+		return
+	}
+	if arg == nil {
+		literal.Null = true
+		literal.Placeholder = nil
+		return
+	}
+	if number, ok := argNumber(arg); ok {
+		literal.Number = &number
+		literal.Placeholder = nil
+		return
+	}
+	if boolVal, ok := arg.(bool); ok {
+		literal.Bool = &boolVal
+		literal.Placeholder = nil
+		return
+	}
+	if str, ok := arg.(string); ok {
+		literal.String = &str
+		literal.Placeholder = nil
+		return
+	}
+	b.fail(literal.Pos, *literal.Placeholder, arg)
+}
+
+func (b *binder) fail(pos Position, placeholder string, arg interface{}) {
+	if b.err == nil {
+		b.err = errorAt(pos, "", &UnsupportedArgument{Placeholder: placeholder, Value: arg})
+	}
+}
+
+// argNumber returns the argument as it'd be written as a number literal,
+// if it's a number. Arguments which came over the wire are json.Numbers.
+func argNumber(arg interface{}) (string, bool) {
+	switch number := arg.(type) {
+	case int:
+		return strconv.Itoa(number), true
+	case int64:
+		return strconv.FormatInt(number, 10), true
+	case float64:
+		return formatFloat(number), true
+	case json.Number:
+		return number.String(), true
+	}
+	return "", false
+}
@@ -0,0 +1,100 @@
+package treesql
+
+import "testing"
+
+func TestPlaceholders(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, title string, views int)`,
+			ack:  "CREATE TABLE",
+		},
+		// Arguments can contain anything, including quotes.
+		{
+			stmt: `INSERT INTO blog_posts VALUES ($1, $2, $3)`,
+			args: []interface{}{"0", `say "hello" to 'world'`, 5},
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ($1, $1, 10)`,
+			args: []interface{}{"1"},
+			ack:  "INSERT 1",
+		},
+		// Verify that arguments are checked.
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ($1, $2, 0)`,
+			args:  []interface{}{"2"},
+			error: "validation error: statement has 2 placeholders, but 1 arguments were given",
+		},
+		{
+			query: `MANY blog_posts WHERE id = "0" { id }`,
+			args:  []interface{}{"2"},
+			error: "validation error: statement has 0 placeholders, but 1 arguments were given",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ($1, $2, 0) ON CONFLICT DO UPDATE SET title = $3`,
+			args:  []interface{}{"0", "hello"},
+			error: "validation error: statement has 3 placeholders, but 2 arguments were given",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ($1, "hello", 0)`,
+			args:  []interface{}{[]string{"0"}},
+			error: "validation error: can't bind []interface {}{\"0\"} to $1; arguments must be strings, numbers, bools or null",
+		},
+		{
+			query: `MANY blog_posts WHERE views = $1 { id }`,
+			args:  []interface{}{"5"},
+			error: "validation error: can't compare int to string",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("2", "hello", $1)`,
+			args:  []interface{}{"5"},
+			error: "validation error: can't assign string value to int column views",
+		},
+		// Happy path.
+		{
+			stmt: `UPDATE blog_posts SET title = $1 WHERE id = $2`,
+			args: []interface{}{"hello world", "1"},
+			ack:  "UPDATE 1",
+		},
+		{
+			query: `MANY blog_posts WHERE title = $1 OR id = $2 { id, title }`,
+			args:  []interface{}{`say "hello" to 'world'`, nil},
+			initialResult: `[
+  {
+    "id": "0",
+    "title": "say \"hello\" to 'world'"
+  }
+]`,
+		},
+		{
+			query: `MANY blog_posts WHERE views = $1 { id, views }`,
+			args:  []interface{}{5},
+			initialResult: `[
+  {
+    "id": "0",
+    "views": 5
+  }
+]`,
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ($1, $2, 0) ON CONFLICT DO UPDATE SET title = $3, views = views + $4`,
+			args: []interface{}{"0", "ignored", "hello again", 2},
+			ack:  "INSERT 0 UPDATE 1",
+		},
+		{
+			stmt: `DELETE FROM blog_posts WHERE id = $1`,
+			args: []interface{}{"1"},
+			ack:  "DELETE 1",
+		},
+		{
+			query: `MANY blog_posts { id, title, views }`,
+			initialResult: `[
+  {
+    "id": "0",
+    "title": "hello again",
+    "views": 7
+  }
+]`,
+		},
+	})
+}
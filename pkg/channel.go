@@ -12,7 +12,8 @@ type ChannelID int
 type Channel struct {
 	Connection   *Connection
 	RawStatement string
-	ID           int // unique with containing connection
+	Args         []interface{} // bound to the statement's placeholders
+	ID           int           // unique with containing connection
 
 	Context context.Context
 }
@@ -21,11 +22,12 @@ func (channel *Channel) Ctx() context.Context {
 	return channel.Context
 }
 
-func NewChannel(rawStatement string, ID int, conn *Connection) *Channel {
+func NewChannel(rawStatement string, args []interface{}, ID int, conn *Connection) *Channel {
 	ctx := context.WithValue(conn.Ctx(), clog.ChannelIDKey, ID)
 	channel := &Channel{
 		Connection:   conn,
 		RawStatement: rawStatement,
+		Args:         args,
 		ID:           ID,
 		Context:      ctx,
 	}
@@ -54,6 +56,11 @@ func (channel *Channel) validateAndRun() (error, bool) {
 		return &ParseError{error: err}, true
 	}
 
+	// Fill in placeholders.
+	if err := statement.Bind(channel.Args); err != nil {
+		return &ValidationError{error: err}, true
+	}
+
 	// Validate statement.
 	queryErr := channel.Connection.Database.ValidateStatement(statement)
 	if queryErr != nil {
@@ -77,12 +84,31 @@ func (channel *Channel) run(statement *Statement) (error, bool) {
 	if statement.CreateTable != nil {
 		return conn.ExecuteCreateTable(statement.CreateTable, channel), true
 	}
+	if statement.CreateIndex != nil {
+		return conn.ExecuteCreateIndex(statement.CreateIndex, channel), true
+	}
 	if statement.Update != nil {
 		return conn.ExecuteUpdate(statement.Update, channel), true
 	}
+	if statement.Delete != nil {
+		return conn.ExecuteDelete(statement.Delete, channel), true
+	}
+	if statement.DropTable != nil {
+		return conn.ExecuteDropTable(statement.DropTable, channel), true
+	}
+	if statement.AlterTable != nil {
+		return conn.ExecuteAlterTable(statement.AlterTable, channel), true
+	}
 	panic(fmt.Sprintf("unknown statement type %v", statement))
 }
 
+// StatementMessage is what clients send to run a statement with arguments.
+// Statements without arguments can also be sent as plain text.
+type StatementMessage struct {
+	Statement string
+	Args      []interface{}
+}
+
 type ChannelMessage struct {
 	// TODO: change this to ChannelID, as well as usages in JS
 	StatementID int
@@ -98,6 +124,9 @@ const (
 	InitialResultMessage
 	RecordUpdateMessage
 	TableUpdateMessage
+	RecordDeleteMessage
+	WindowUpdateMessage
+	AggregateUpdateMessage
 )
 
 func (m *MessageToClientType) MarshalJSON() ([]byte, error) {
@@ -112,6 +141,12 @@ func (m *MessageToClientType) MarshalJSON() ([]byte, error) {
 		return []byte("\"record_update\""), nil
 	case TableUpdateMessage:
 		return []byte("\"table_update\""), nil
+	case RecordDeleteMessage:
+		return []byte("\"record_delete\""), nil
+	case WindowUpdateMessage:
+		return []byte("\"window_update\""), nil
+	case AggregateUpdateMessage:
+		return []byte("\"aggregate_update\""), nil
 	}
 	return nil, fmt.Errorf("unknown error type %d", *m)
 }
@@ -129,6 +164,12 @@ func (m *MessageToClientType) UnmarshalText(text []byte) error {
 		*m = RecordUpdateMessage
 	case "table_update":
 		*m = TableUpdateMessage
+	case "record_delete":
+		*m = RecordDeleteMessage
+	case "window_update":
+		*m = WindowUpdateMessage
+	case "aggregate_update":
+		*m = AggregateUpdateMessage
 	}
 	return nil
 }
@@ -136,11 +177,17 @@ func (m *MessageToClientType) UnmarshalText(text []byte) error {
 type MessageToClient struct {
 	Type         MessageToClientType `json:"type"`
 	ErrorMessage *string             `json:"error,omitempty"`
+	ErrorDetail  *ErrorDetail        `json:"error_detail,omitempty"`
 	AckMessage   *string             `json:"ack,omitempty"`
+	// the primary keys of inserted records, if they were generated
+	InsertedKeys []interface{} `json:"inserted_keys,omitempty"`
 	// data
-	InitialResultMessage *InitialResult `json:"initial_result,omitempty"`
-	RecordUpdateMessage  *RecordUpdate  `json:"record_update,omitempty"`
-	TableUpdateMessage   *TableUpdate   `json:"table_update,omitempty"`
+	InitialResultMessage   *InitialResult   `json:"initial_result,omitempty"`
+	RecordUpdateMessage    *RecordUpdate    `json:"record_update,omitempty"`
+	TableUpdateMessage     *TableUpdate     `json:"table_update,omitempty"`
+	RecordDeleteMessage    *RecordDelete    `json:"record_delete,omitempty"`
+	WindowUpdateMessage    *WindowUpdate    `json:"window_update,omitempty"`
+	AggregateUpdateMessage *AggregateUpdate `json:"aggregate_update,omitempty"`
 }
 
 type InitialResult struct {
@@ -156,6 +203,32 @@ type TableUpdate struct {
 type RecordUpdate struct {
 	TableEvent *TableEvent
 	QueryPath  FlattenedQueryPath
+	// the record's selected columns and expressions, computed from the new record
+	Fields map[string]interface{}
+}
+
+// RecordDelete tells the client that the record at QueryPath
+// is no longer in the result set.
+type RecordDelete struct {
+	TableEvent *TableEvent
+	QueryPath  FlattenedQueryPath
+}
+
+// WindowUpdate describes a change to an ordered or limited selection:
+// the primary keys of records which left the window, the records which
+// entered it, and the primary keys of the records now in it, in order.
+type WindowUpdate struct {
+	QueryPath FlattenedQueryPath
+	Removed   []string
+	Added     SelectResult
+	Order     []string
+}
+
+// AggregateUpdate carries the new value of the aggregate
+// selection at QueryPath.
+type AggregateUpdate struct {
+	QueryPath FlattenedQueryPath
+	Value     interface{}
 }
 
 func (channel *Channel) WriteErrorMessage(err error) {
@@ -163,6 +236,7 @@ func (channel *Channel) WriteErrorMessage(err error) {
 	channel.writeMessage(&MessageToClient{
 		Type:         ErrorMessage,
 		ErrorMessage: &errStr,
+		ErrorDetail:  newErrorDetail(err, channel.RawStatement),
 	})
 }
 
@@ -173,6 +247,16 @@ func (channel *Channel) WriteAckMessage(message string) {
 	})
 }
 
+// WriteInsertAckMessage acks an insert, with the primary keys of the
+// records it inserted if they were generated, e.g. by a serial column.
+func (channel *Channel) WriteInsertAckMessage(message string, insertedKeys []interface{}) {
+	channel.writeMessage(&MessageToClient{
+		Type:         AckMessage,
+		AckMessage:   &message,
+		InsertedKeys: insertedKeys,
+	})
+}
+
 func (channel *Channel) WriteInitialResult(result *InitialResult) {
 	channel.writeMessage(&MessageToClient{
 		Type:                 InitialResultMessage,
@@ -187,16 +271,43 @@ func (channel *Channel) WriteTableUpdate(update *TableUpdate) {
 	})
 }
 
-func (channel *Channel) WriteRecordUpdate(update *TableEvent, queryPath *QueryPath) {
+func (channel *Channel) WriteRecordUpdate(
+	update *TableEvent, queryPath *QueryPath, fields map[string]interface{},
+) {
 	channel.writeMessage(&MessageToClient{
 		Type: RecordUpdateMessage,
 		RecordUpdateMessage: &RecordUpdate{
 			QueryPath:  queryPath.Flatten(),
 			TableEvent: update,
+			Fields:     fields,
+		},
+	})
+}
+
+func (channel *Channel) WriteRecordDelete(update *TableEvent, queryPath *QueryPath) {
+	channel.writeMessage(&MessageToClient{
+		Type: RecordDeleteMessage,
+		RecordDeleteMessage: &RecordDelete{
+			QueryPath:  queryPath.Flatten(),
+			TableEvent: update,
 		},
 	})
 }
 
+func (channel *Channel) WriteWindowUpdate(update *WindowUpdate) {
+	channel.writeMessage(&MessageToClient{
+		Type:                WindowUpdateMessage,
+		WindowUpdateMessage: update,
+	})
+}
+
+func (channel *Channel) WriteAggregateUpdate(update *AggregateUpdate) {
+	channel.writeMessage(&MessageToClient{
+		Type:                   AggregateUpdateMessage,
+		AggregateUpdateMessage: update,
+	})
+}
+
 func (channel *Channel) writeMessage(message *MessageToClient) {
 	channel.Connection.Messages <- &ChannelMessage{
 		StatementID: channel.ID,
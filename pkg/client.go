@@ -22,6 +22,7 @@ type Client struct {
 
 type StatementRequest struct {
 	Statement  string
+	Args       []interface{}
 	ResultChan chan *ClientChannel
 }
 
@@ -61,7 +62,10 @@ func (conn *Client) handleStatements() {
 			conn.NextStatementID++
 			conn.Channels[channel.StatementID] = channel
 			request.ResultChan <- channel
-			conn.WebSocketConn.WriteMessage(websocket.TextMessage, []byte(request.Statement))
+			conn.WebSocketConn.WriteJSON(&StatementMessage{
+				Statement: request.Statement,
+				Args:      request.Args,
+			})
 
 		case incomingMsg := <-conn.IncomingMessages:
 			channel := conn.Channels[incomingMsg.StatementID]
@@ -92,44 +96,70 @@ type ClientChannel struct {
 	Updates     chan *MessageToClient
 }
 
-func (conn *Client) Statement(statement string) *ClientChannel {
+// Statement sends a statement to the server. Placeholders in it (`$1`, `$2`, ...)
+// are replaced by args, which should be strings, numbers, bools or nil.
+// Timestamps and bytes are sent as strings, in RFC 3339 and base64.
+func (conn *Client) Statement(statement string, args ...interface{}) *ClientChannel {
 	resultChan := make(chan *ClientChannel)
 	conn.StatementsToSend <- &StatementRequest{
 		ResultChan: resultChan,
 		Statement:  statement,
+		Args:       args,
 	}
 	return <-resultChan
 }
 
-func (conn *Client) LiveQuery(query string) (*InitialResult, *ClientChannel, error) {
-	channel := conn.Statement(query)
+func (conn *Client) LiveQuery(query string, args ...interface{}) (*InitialResult, *ClientChannel, error) {
+	channel := conn.Statement(query, args...)
 	update := <-channel.Updates
 	if update.ErrorMessage != nil {
-		return nil, nil, errors.New(*update.ErrorMessage)
+		return nil, nil, update.error()
 	} else if update.InitialResultMessage != nil {
 		return update.InitialResultMessage, channel, nil
 	}
 	return nil, nil, errors.New("query result neither error nor initial result")
 }
 
-func (conn *Client) Query(query string) (*InitialResult, error) {
-	resultChan := conn.Statement(query)
+func (conn *Client) Query(query string, args ...interface{}) (*InitialResult, error) {
+	resultChan := conn.Statement(query, args...)
 	update := <-resultChan.Updates
 	if update.ErrorMessage != nil {
-		return nil, errors.New(*update.ErrorMessage)
+		return nil, update.error()
 	} else if update.InitialResultMessage != nil {
 		return update.InitialResultMessage, nil
 	}
 	return nil, errors.New("query result neither error nor initial result")
 }
 
-func (conn *Client) Exec(statement string) (string, error) {
-	resultChan := conn.Statement(statement)
+func (conn *Client) Exec(statement string, args ...interface{}) (string, error) {
+	resultChan := conn.Statement(statement, args...)
 	update := <-resultChan.Updates
 	if update.ErrorMessage != nil {
-		return "", errors.New(*update.ErrorMessage)
+		return "", update.error()
 	} else if update.AckMessage != nil {
 		return *update.AckMessage, nil
 	}
 	return "", errors.New("exec result neither error nor ack")
 }
+
+// Insert runs an insert statement, returning the primary keys of the inserted
+// records if they were generated, e.g. by a serial or DEFAULT gen_uuid() column.
+func (conn *Client) Insert(statement string, args ...interface{}) ([]interface{}, error) {
+	resultChan := conn.Statement(statement, args...)
+	update := <-resultChan.Updates
+	if update.ErrorMessage != nil {
+		return nil, update.error()
+	} else if update.AckMessage != nil {
+		return update.InsertedKeys, nil
+	}
+	return nil, errors.New("insert result neither error nor ack")
+}
+
+// error returns the error the server sent, as a *StatementError
+// if it came with details.
+func (update *MessageToClient) error() error {
+	if update.ErrorDetail != nil {
+		return newStatementError(update.ErrorDetail)
+	}
+	return errors.New(*update.ErrorMessage)
+}
@@ -1,7 +1,10 @@
 package treesql
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"sync"
 
 	"github.com/gorilla/websocket"
 	clog "github.com/vilterp/treesql/pkg/log"
@@ -17,6 +20,14 @@ type Connection struct {
 	NextChannelID int
 	Messages      chan *ChannelMessage
 	Context       context.Context
+
+	// tables whose live queries on this connection have to be re-run,
+	// since their columns changed; see queueRerun
+	reruns struct {
+		sync.Mutex
+		tableNames []string
+	}
+	rerunsQueued chan struct{}
 }
 
 func NewConnection(wsConn *websocket.Conn, db *Database, ID int) *Connection {
@@ -29,6 +40,7 @@ func NewConnection(wsConn *websocket.Conn, db *Database, ID int) *Connection {
 		NextChannelID: 0,
 		Messages:      make(chan *ChannelMessage),
 		Context:       ctx,
+		rerunsQueued:  make(chan struct{}, 1),
 	}
 	go conn.writeMessagesToSocket()
 	return conn
@@ -46,22 +58,66 @@ func (conn *Connection) writeMessagesToSocket() {
 	}
 }
 
+// HandleStatements runs the statements the client sends, one at a time,
+// along with the re-runs of live queries other connections queue, until
+// the client goes away.
 func (conn *Connection) HandleStatements() {
 	clog.Println(conn, "initiated from", conn.clientConn.RemoteAddr())
+	messages := make(chan []byte)
+	go conn.readMessages(messages)
 	for {
-		_, message, readErr := conn.clientConn.ReadMessage()
-		if readErr != nil {
-			clog.Println(conn, "terminated:", readErr)
-			conn.Database.removeConn(conn)
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				conn.Database.removeConn(conn)
+				return
+			}
+			statement, err := parseStatementMessage(message)
+			if err != nil {
+				clog.Println(conn, "couldn't decode statement message:", err)
+				statement = &StatementMessage{Statement: string(message)}
+			}
+			conn.addChannel(statement)
+			// this statement may have queued re-runs itself, which
+			// should happen before the next one
+			conn.handleReruns()
+		case <-conn.rerunsQueued:
+			conn.handleReruns()
+		}
+	}
+}
+
+// readMessages sends the messages the client sends to the given channel,
+// closing it when the client goes away.
+func (conn *Connection) readMessages(messages chan<- []byte) {
+	defer close(messages)
+	for {
+		_, message, err := conn.clientConn.ReadMessage()
+		if err != nil {
+			clog.Println(conn, "terminated:", err)
 			return
 		}
-		stringMessage := string(message)
-		conn.addChannel(stringMessage)
+		messages <- message
+	}
+}
+
+// parseStatementMessage decodes a StatementMessage if the client sent
+// JSON, and otherwise treats the whole message as the statement.
+func parseStatementMessage(message []byte) (*StatementMessage, error) {
+	if len(message) == 0 || message[0] != '{' {
+		return &StatementMessage{Statement: string(message)}, nil
+	}
+	statement := &StatementMessage{}
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.UseNumber() // so integer arguments stay integers
+	if err := decoder.Decode(statement); err != nil {
+		return nil, err
 	}
+	return statement, nil
 }
 
-func (conn *Connection) addChannel(statement string) {
-	channel := NewChannel(statement, conn.NextChannelID, conn)
+func (conn *Connection) addChannel(statement *StatementMessage) {
+	channel := NewChannel(statement.Statement, statement.Args, conn.NextChannelID, conn)
 	conn.NextChannelID++
 	conn.Channels[channel.ID] = channel
 
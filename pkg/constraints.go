@@ -0,0 +1,255 @@
+package treesql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+// validateConstraints checks the constraints declared on columns being
+// created and after them, given the table with those columns.
+func (db *Database) validateConstraints(
+	table *TableDescriptor, columns []*CreateTableColumn, constraints []*TableConstraint,
+) error {
+	for _, column := range columns {
+		if column.Check != nil {
+			if err := db.validateExpr(column.Check, table); err != nil {
+				return err
+			}
+		}
+	}
+	for _, constraint := range constraints {
+		if constraint.Check != nil {
+			if err := db.validateExpr(constraint.Check, table); err != nil {
+				return err
+			}
+			continue
+		}
+		listed := map[string]bool{}
+		for _, columnName := range constraint.Unique {
+			if table.getColumn(columnName) == nil {
+				return errorAt(constraint.Pos, columnName, &NoSuchColumn{TableName: table.Name, ColumnName: columnName})
+			}
+			if listed[columnName] {
+				return errorAt(constraint.Pos, columnName, &DuplicateColumn{ColumnName: columnName})
+			}
+			listed[columnName] = true
+		}
+	}
+	return nil
+}
+
+// newConstraints describes the constraints declared on columns being
+// created and after them. They're named as Postgres would, e.g.
+// users_email_key or users_age_check, with a number on the end if
+// another table's constraint already has the name.
+func (db *Database) newConstraints(
+	tableName string, columns []*CreateTableColumn, constraints []*TableConstraint,
+) []*Constraint {
+	taken := map[string]bool{}
+	for _, table := range db.Schema.Tables {
+		for _, constraint := range table.Constraints {
+			taken[constraint.Name] = true
+		}
+	}
+	name := func(parts ...string) string {
+		base := strings.Join(parts, "_")
+		name := base
+		for idx := 1; taken[name]; idx++ {
+			name = fmt.Sprintf("%s%d", base, idx)
+		}
+		taken[name] = true
+		return name
+	}
+
+	var described []*Constraint
+	for _, column := range columns {
+		if column.Unique {
+			described = append(described, &Constraint{
+				Name:   name(tableName, column.Name, "key"),
+				Unique: []string{column.Name},
+			})
+		}
+		if column.Check != nil {
+			described = append(described, &Constraint{
+				Name:  name(tableName, column.Name, "check"),
+				Check: column.Check,
+			})
+		}
+	}
+	for _, constraint := range constraints {
+		if constraint.Check != nil {
+			described = append(described, &Constraint{
+				Name:  name(tableName, "check"),
+				Check: constraint.Check,
+			})
+			continue
+		}
+		parts := append(append([]string{tableName}, constraint.Unique...), "key")
+		described = append(described, &Constraint{
+			Name:   name(parts...),
+			Unique: constraint.Unique,
+		})
+	}
+	return described
+}
+
+// leadsUnique returns whether the column is the first of one of the
+// UNIQUE constraints; it's indexed, so that they can be checked by
+// looking records up in its index rather than scanning the table.
+func leadsUnique(constraints []*Constraint, columnName string) bool {
+	for _, constraint := range constraints {
+		if constraint.Check == nil && constraint.Unique[0] == columnName {
+			return true
+		}
+	}
+	return false
+}
+
+// columnNames returns the names of the columns the constraint is on.
+func (constraint *Constraint) columnNames() []string {
+	if constraint.Check == nil {
+		return constraint.Unique
+	}
+	var columnNames []string
+	for _, term := range constraint.Check.terms() {
+		if term.Column != nil {
+			columnNames = append(columnNames, *term.Column)
+		}
+	}
+	return columnNames
+}
+
+// involves returns whether the constraint is on the given column.
+func (constraint *Constraint) involves(columnName string) bool {
+	for _, name := range constraint.columnNames() {
+		if name == columnName {
+			return true
+		}
+	}
+	return false
+}
+
+// withColumnRenamed returns a copy of the constraint in which a column
+// has a new name.
+func (constraint *Constraint) withColumnRenamed(oldName string, newName string) *Constraint {
+	renamed := &Constraint{Name: constraint.Name}
+	if constraint.Check != nil {
+		// parse a copy, rather than changing the expression in place
+		renamed.Check, _ = ParseExpr(constraint.Check.Format())
+		for _, term := range renamed.Check.terms() {
+			if term.Column != nil && *term.Column == oldName {
+				term.Column = &newName
+			}
+		}
+		return renamed
+	}
+	for _, columnName := range constraint.Unique {
+		if columnName == oldName {
+			columnName = newName
+		}
+		renamed.Unique = append(renamed.Unique, columnName)
+	}
+	return renamed
+}
+
+// checkConstraints checks that the records a statement wrote satisfy their
+// tables' constraints, as they are once the statement is done.
+func checkConstraints(tx *bolt.Tx, events []*TableEvent) error {
+	checked := map[string]bool{}
+	for _, event := range events {
+		if event.NewRecord == nil {
+			continue
+		}
+		table := event.NewRecord.Table
+		key := event.NewRecord.primaryKey()
+		if checked[table.Name+"."+key] {
+			continue
+		}
+		checked[table.Name+"."+key] = true
+		// it may have been changed or deleted since
+		current := tx.Bucket([]byte(table.Name)).Get([]byte(key))
+		if current == nil {
+			continue
+		}
+		record := table.RecordFromBytes(current)
+		for _, constraint := range table.Constraints {
+			if err := constraint.check(tx, record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkTableConstraints checks that all of a table's records satisfy
+// the given constraints, e.g. when they're added to it.
+func checkTableConstraints(tx *bolt.Tx, table *TableDescriptor, constraints []*Constraint) error {
+	return tx.Bucket([]byte(table.Name)).ForEach(func(_ []byte, value []byte) error {
+		record := table.RecordFromBytes(value)
+		for _, constraint := range constraints {
+			if err := constraint.check(tx, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// check returns an error if the record violates the constraint.
+func (constraint *Constraint) check(tx *bolt.Tx, record *Record) error {
+	table := record.Table
+	if constraint.Check != nil {
+		if constraint.Check.satisfiedBy(record) {
+			return nil
+		}
+		return &CheckViolation{
+			TableName:      table.Name,
+			ConstraintName: constraint.Name,
+			Check:          constraint.Check.Format(),
+			Key:            record.primaryKey(),
+		}
+	}
+	// records with a null in the columns don't conflict with any
+	values := make([]*Value, len(constraint.Unique))
+	for idx, columnName := range constraint.Unique {
+		values[idx] = record.GetField(columnName)
+		if values[idx].Null {
+			return nil
+		}
+	}
+	// only records with the same value in the first column, which is
+	// indexed, can conflict
+	key := record.primaryKey()
+	leading := table.getColumn(constraint.Unique[0])
+	index := tx.Bucket(indexBucketName(leading))
+	if index == nil {
+		return nil
+	}
+	keys := index.Bucket(indexKey(leading, values[0]))
+	if keys == nil {
+		return nil
+	}
+	records := tx.Bucket([]byte(table.Name))
+	return keys.ForEach(func(otherKey []byte, _ []byte) error {
+		if string(otherKey) == key {
+			return nil
+		}
+		other := table.RecordFromBytes(records.Get(otherKey))
+		for idx, columnName := range constraint.Unique {
+			if otherValue := other.GetField(columnName); otherValue.Null || otherValue.key() != values[idx].key() {
+				return nil
+			}
+		}
+		conditions := make([]string, len(constraint.Unique))
+		for idx, columnName := range constraint.Unique {
+			conditions[idx] = NewEqualsExpr(columnName, values[idx]).Format()
+		}
+		return &UniqueViolation{
+			TableName:      table.Name,
+			ConstraintName: constraint.Name,
+			Values:         strings.Join(conditions, " AND "),
+		}
+	})
+}
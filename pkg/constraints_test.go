@@ -0,0 +1,208 @@
+package treesql
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestConstraints(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		// Verify that constraints are checked.
+		{
+			stmt:  `CREATE TABLE users (id serial PRIMARY KEY, name string, UNIQUE (nope))`,
+			error: "validation error: no such column in table users: nope",
+		},
+		{
+			stmt:  `CREATE TABLE users (id serial PRIMARY KEY, name string, UNIQUE (name, name))`,
+			error: "validation error: column listed more than once: name",
+		},
+		{
+			stmt:  `CREATE TABLE users (id serial PRIMARY KEY, age int CHECK (age > "old"))`,
+			error: "validation error: can't compare int to string",
+		},
+		// Happy path: constraints on columns, and on several of them.
+		{
+			stmt: `CREATE TABLE users (id serial PRIMARY KEY, email string UNIQUE CHECK (email <> ""), age int CHECK (age >= 13), first_name string, last_name string, UNIQUE (first_name, last_name), CHECK (first_name <> last_name))`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO users (email, age, first_name, last_name) VALUES ("pete@example.com", 30, "pete", "smith")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt:  `INSERT INTO users (email, age, first_name, last_name) VALUES ("pete@example.com", 20, "peter", "smith")`,
+			error: `executing insert: record already exists in users with email = "pete@example.com", violating unique constraint users_email_key`,
+		},
+		{
+			stmt:  `INSERT INTO users (email) VALUES ("alice@example.com"), ("alice@example.com")`,
+			error: `executing insert: record already exists in users with email = "alice@example.com", violating unique constraint users_email_key`,
+		},
+		{
+			stmt:  `INSERT INTO users (email, age) VALUES ("alice@example.com", 12)`,
+			error: "executing insert: record in users with primary key 2 violates check constraint users_age_check: age >= 13",
+		},
+		{
+			stmt:  `INSERT INTO users (first_name, last_name) VALUES ("pete", "smith")`,
+			error: `executing insert: record already exists in users with first_name = "pete" AND last_name = "smith", violating unique constraint users_first_name_last_name_key`,
+		},
+		{
+			stmt:  `INSERT INTO users (first_name, last_name) VALUES ("bob", "bob")`,
+			error: `executing insert: record in users with primary key 2 violates check constraint users_check: first_name <> last_name`,
+		},
+		// Nulls don't conflict, or fail checks.
+		{
+			stmt: `INSERT INTO users (first_name, last_name) VALUES ("alice", NULL), ("alice", NULL)`,
+			ack:  "INSERT 2",
+		},
+		{
+			stmt:  `UPDATE users SET email = "pete@example.com" WHERE id = 2`,
+			error: `executing update: record already exists in users with email = "pete@example.com", violating unique constraint users_email_key`,
+		},
+		{
+			stmt: `UPDATE users SET age = age - 17 WHERE id <> 0`,
+			ack:  "UPDATE 3",
+		},
+		{
+			stmt:  `UPDATE users SET age = age - 1 WHERE id <> 0`,
+			error: "executing update: record in users with primary key 1 violates check constraint users_age_check: age >= 13",
+		},
+		// Constraints declared on added columns have to hold for existing
+		// records too.
+		{
+			stmt:  `ALTER TABLE users ADD COLUMN handle string UNIQUE DEFAULT "anon"`,
+			error: `altering table: record already exists in users with handle = "anon", violating unique constraint users_handle_key`,
+		},
+		{
+			stmt: `ALTER TABLE users ADD COLUMN handle string UNIQUE`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			stmt: `ALTER TABLE users RENAME COLUMN age TO years`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			stmt:  `INSERT INTO users (years) VALUES (5)`,
+			error: "executing insert: record in users with primary key 4 violates check constraint users_age_check: years >= 13",
+		},
+		{
+			stmt: `ALTER TABLE users DROP COLUMN last_name`,
+			ack:  "ALTER TABLE",
+		},
+		// Names are unique among all tables' constraints.
+		{
+			stmt: `CREATE TABLE users_email (id int PRIMARY KEY, CHECK (id > 0))`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			query: `MANY __constraints__ WHERE table_name = "users" { name, type, columns, check }`,
+			initialResult: `[
+  {
+    "check": null,
+    "columns": "email",
+    "name": "users_email_key",
+    "type": "UNIQUE"
+  },
+  {
+    "check": "email \u003c\u003e \"\"",
+    "columns": null,
+    "name": "users_email_check",
+    "type": "CHECK"
+  },
+  {
+    "check": "years \u003e= 13",
+    "columns": null,
+    "name": "users_age_check",
+    "type": "CHECK"
+  },
+  {
+    "check": null,
+    "columns": "handle",
+    "name": "users_handle_key",
+    "type": "UNIQUE"
+  }
+]`,
+		},
+		// UNIQUE constraints' first columns are indexed, so that they're
+		// checked without scanning the table.
+		{
+			query: `MANY __indexes__ WHERE table_name = "users" { column_name }`,
+			initialResult: `[
+  {
+    "column_name": "email"
+  },
+  {
+    "column_name": "first_name"
+  },
+  {
+    "column_name": "handle"
+  }
+]`,
+		},
+		{
+			query: `MANY __constraints__ WHERE table_name = "users_email" { name, check }`,
+			initialResult: `[
+  {
+    "check": "id \u003e 0",
+    "name": "users_email_check1"
+  }
+]`,
+		},
+		{
+			stmt: `DROP TABLE users_email`,
+			ack:  "DROP TABLE",
+		},
+		{
+			query:         `MANY __constraints__ WHERE table_name = "users_email" { name }`,
+			initialResult: `[]`,
+		},
+		{
+			stmt:  `DELETE FROM __constraints__ WHERE name = "users_email_key"`,
+			error: "validation error: attemtped to write to __constraints__, but builtin tables are read-only",
+		},
+	})
+}
+
+func TestConstraintViolationDetails(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE users (id serial PRIMARY KEY, email string UNIQUE, age int CHECK (age >= 13))`,
+		`INSERT INTO users (email, age) VALUES ("pete@example.com", 30)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Clients can tell violations apart by code, and get at their details.
+	_, err = client.Exec(`INSERT INTO users (email, age) VALUES ("pete@example.com", 20)`)
+	if statementErr, ok := err.(*StatementError); !ok || statementErr.Code != "unique_violation" {
+		t.Fatalf("expected a *StatementError with code unique_violation; got %#v", err)
+	}
+	uniqueViolation, ok := errors.Cause(err).(*UniqueViolation)
+	if !ok {
+		t.Fatalf("expected a *UniqueViolation; got %#v", errors.Cause(err))
+	}
+	if uniqueViolation.ConstraintName != "users_email_key" || uniqueViolation.Values != `email = "pete@example.com"` {
+		t.Fatalf("unexpected unique violation %#v", uniqueViolation)
+	}
+
+	_, err = client.Exec(`UPDATE users SET age = 12 WHERE id = 1`)
+	if statementErr, ok := err.(*StatementError); !ok || statementErr.Code != "check_violation" {
+		t.Fatalf("expected a *StatementError with code check_violation; got %#v", err)
+	}
+	checkViolation, ok := errors.Cause(err).(*CheckViolation)
+	if !ok {
+		t.Fatalf("expected a *CheckViolation; got %#v", errors.Cause(err))
+	}
+	if checkViolation.ConstraintName != "users_age_check" || checkViolation.Key != "1" {
+		t.Fatalf("unexpected check violation %#v", checkViolation)
+	}
+}
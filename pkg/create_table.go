@@ -1,7 +1,6 @@
 package treesql
 
 import (
-	"encoding/binary"
 	"fmt"
 
 	"github.com/boltdb/bolt"
@@ -13,13 +12,15 @@ func (db *Database) validateCreateTable(create *CreateTable) error {
 	// does table already exist?
 	_, ok := db.Schema.Tables[create.Name]
 	if ok {
-		return &TableAlreadyExists{TableName: create.Name}
+		return errorAt(create.Pos, create.Name, &TableAlreadyExists{TableName: create.Name})
 	}
-	// types are real
+	// types are real, and defaults fit
 	for _, column := range create.Columns {
-		knownType := column.TypeName == "string" || column.TypeName == "int"
-		if !knownType {
-			return &NonexistentType{TypeName: column.TypeName}
+		if _, knownType := columnTypeOf(column.TypeName); !knownType {
+			return errorAt(column.Pos, column.TypeName, &NonexistentType{TypeName: column.TypeName})
+		}
+		if err := validateDefault(create.Name, column); err != nil {
+			return err
 		}
 	}
 	// only one primary key
@@ -30,22 +31,110 @@ func (db *Database) validateCreateTable(create *CreateTable) error {
 		}
 	}
 	if primaryKeyCount != 1 {
-		return &WrongNoPrimaryKey{Count: primaryKeyCount}
+		return errorAt(create.Pos, create.Name, &WrongNoPrimaryKey{Count: primaryKeyCount})
 	}
-	// referenced table exists
-	// TODO: column same type as primary key
+	// referenced table exists (or is this one, e.g. for trees of comments),
+	// isn't a builtin, and its primary key is the same type as the column
 	for _, column := range create.Columns {
-		if column.References != nil {
-			_, tableExists := db.Schema.Tables[*column.References]
+		if column.References == nil {
+			continue
+		}
+		if isBuiltinTable(*column.References) {
+			return errorAt(column.Pos, *column.References, &ReferenceToBuiltinTable{
+				TableName:  create.Name,
+				ColumnName: column.Name,
+				References: *column.References,
+			})
+		}
+		var primaryKeyType ColumnType
+		if *column.References == create.Name {
+			for _, other := range create.Columns {
+				if other.PrimaryKey {
+					primaryKeyType, _ = columnTypeOf(other.TypeName)
+				}
+			}
+		} else {
+			referenced, tableExists := db.Schema.Tables[*column.References]
 			if !tableExists {
-				return &NoSuchTable{TableName: *column.References}
+				return errorAt(column.Pos, *column.References, &NoSuchTable{TableName: *column.References})
 			}
+			primaryKeyType = referenced.getColumn(referenced.PrimaryKey).Type
 		}
+		if columnType, _ := columnTypeOf(column.TypeName); columnType != primaryKeyType {
+			return errorAt(column.Pos, *column.References, &ReferenceTypeMismatch{
+				TableName:      create.Name,
+				ColumnName:     column.Name,
+				ColumnType:     columnType,
+				References:     *column.References,
+				PrimaryKeyType: primaryKeyType,
+			})
+		}
+	}
+	// constraints' columns exist, and checks compare them to values of
+	// the right types
+	table := &TableDescriptor{Name: create.Name}
+	for _, column := range create.Columns {
+		columnType, _ := columnTypeOf(column.TypeName)
+		table.Columns = append(table.Columns, &ColumnDescriptor{Name: column.Name, Type: columnType})
+	}
+	if err := db.validateConstraints(table, create.Columns, create.Constraints); err != nil {
+		return err
 	}
 	// TODO: dedup column names
 	return nil
 }
 
+// validateDefault checks that the column's default, if it has one, fits in
+// it. Defaults are evaluated without a record, so they can't use columns.
+func validateDefault(tableName string, column *CreateTableColumn) error {
+	if column.Default == nil {
+		return nil
+	}
+	pos := column.Default.Left.Left.Left.Pos
+	if column.TypeName == serialTypeName {
+		return errorAt(pos, "", &SerialDefault{ColumnName: column.Name})
+	}
+	columnType, _ := columnTypeOf(column.TypeName)
+	if term := column.Default.term(); term != nil {
+		coercePlainStrings([]*Term{term}, columnType)
+	}
+	valueType, err := column.Default.typeIn(&TableDescriptor{Name: tableName})
+	if err != nil {
+		return err
+	}
+	if valueType == nil && (column.NotNull || column.PrimaryKey) {
+		return errorAt(pos, "", &NullViolation{TableName: tableName, ColumnName: column.Name})
+	}
+	if valueType != nil && !assignable(*valueType, columnType) {
+		return errorAt(pos, "", &AssignmentTypeMismatch{
+			ColumnName: column.Name,
+			ColumnType: columnType,
+			ValueType:  *valueType,
+		})
+	}
+	return nil
+}
+
+// newColumn describes a column being created, with the next column ID.
+// References are indexed, so that joins along them don't scan.
+func (db *Database) newColumn(parsed *CreateTableColumn) *ColumnDescriptor {
+	var reference *ColumnReference
+	if parsed.References != nil {
+		reference = newColumnReference(*parsed.References, parsed.OnDelete, parsed.OnUpdate)
+	}
+	columnType, _ := columnTypeOf(parsed.TypeName)
+	return &ColumnDescriptor{
+		ID:               db.Schema.NextColumnID,
+		Name:             parsed.Name,
+		Type:             columnType,
+		NotNull:          parsed.NotNull,
+		Default:          parsed.Default,
+		Serial:           parsed.TypeName == serialTypeName,
+		ReferencesColumn: reference,
+		Indexed:          reference != nil,
+	}
+}
+
 func (conn *Connection) ExecuteCreateTable(create *CreateTable, channel *Channel) error {
 	// find primary key
 	var primaryKey string
@@ -56,8 +145,12 @@ func (conn *Connection) ExecuteCreateTable(create *CreateTable, channel *Channel
 		}
 	}
 	columnRecords := make([]*Record, len(create.Columns))
+	var indexRecords []*Record
+	constraints := conn.Database.newConstraints(create.Name, create.Columns, create.Constraints)
+	constraintRecords := make([]*Record, len(constraints))
 	updateErr := conn.Database.BoltDB.Update(func(tx *bolt.Tx) error {
 		tableSpec := conn.Database.AddTable(create.Name, primaryKey, make([]*ColumnDescriptor, len(create.Columns)))
+		tableSpec.Constraints = constraints
 		// create bucket for new table
 		tx.CreateBucket([]byte(create.Name))
 		// add to in-memory schema
@@ -70,20 +163,9 @@ func (conn *Connection) ExecuteCreateTable(create *CreateTable, channel *Channel
 		}
 		// write to __columns__
 		for idx, parsedColumn := range create.Columns {
-			// extract reference
-			var reference *ColumnReference
-			if parsedColumn.References != nil {
-				reference = &ColumnReference{
-					TableName: *parsedColumn.References,
-				}
-			}
 			// build column spec
-			columnSpec := &ColumnDescriptor{
-				ID:               conn.Database.Schema.NextColumnID,
-				Name:             parsedColumn.Name,
-				ReferencesColumn: reference,
-				Type:             NameToType[parsedColumn.TypeName],
-			}
+			columnSpec := conn.Database.newColumn(parsedColumn)
+			columnSpec.Indexed = columnSpec.Indexed || leadsUnique(constraints, columnSpec.Name)
 			conn.Database.Schema.NextColumnID++
 			// put column spec in in-memory schema copy
 			// TODO: synchronize access to this mutable shared data structure!!
@@ -98,17 +180,34 @@ func (conn *Connection) ExecuteCreateTable(create *CreateTable, channel *Channel
 				return columnPutErr
 			}
 			columnRecords[idx] = columnRecord
+			if columnSpec.Indexed {
+				if err := buildIndex(tx, tableSpec, columnSpec); err != nil {
+					return err
+				}
+				indexRecords = append(indexRecords, columnSpec.IndexToRecord(create.Name, conn.Database))
+			}
+		}
+		// write to __constraints__
+		constraintsBucket := tx.Bucket([]byte("__constraints__"))
+		for idx, constraint := range constraints {
+			constraintRecords[idx] = constraint.ToRecord(create.Name, conn.Database)
+			if err := constraintsBucket.Put([]byte(constraint.Name), constraintRecords[idx].ToBytes()); err != nil {
+				return err
+			}
 		}
 		// push live query messages
 		conn.Database.PushTableEvent(channel, "__tables__", nil, tableRecord)
 		for _, columnRecord := range columnRecords {
 			conn.Database.PushTableEvent(channel, "__columns__", nil, columnRecord)
 		}
+		for _, constraintRecord := range constraintRecords {
+			conn.Database.PushTableEvent(channel, "__constraints__", nil, constraintRecord)
+		}
+		for _, indexRecord := range indexRecords {
+			conn.Database.PushTableEvent(channel, "__indexes__", nil, indexRecord)
+		}
 		// write next column id sequence
-		nextColumnIDBytes := make([]byte, 4)
-		binary.BigEndian.PutUint32(nextColumnIDBytes, uint32(conn.Database.Schema.NextColumnID))
-		tx.Bucket([]byte("__sequences__")).Put([]byte("__next_column_id__"), nextColumnIDBytes)
-		return nil
+		return conn.Database.saveNextColumnID(tx)
 	})
 	if updateErr != nil {
 		return errors.Wrap(updateErr, "creating table")
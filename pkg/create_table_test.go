@@ -8,19 +8,23 @@ func TestCreateTable(t *testing.T) {
 	runSimpleTestScript(t, []simpleTestStmt{
 		// validate that there's a primary key
 		{
-			stmt:  "CREATETABLE foo (id int)",
+			stmt:  "CREATE TABLE foo (id int)",
 			error: `validation error: tables should have exactly one column marked "primary key"; given 0`,
 		},
 		// validate that references exist
 		{
-			stmt:  "CREATETABLE bar (id int PRIMARYKEY, blog_post_id string REFERENCESTABLE blog_posts)",
+			stmt:  "CREATE TABLE bar (id int PRIMARY KEY, blog_post_id string REFERENCES blog_posts)",
 			error: `validation error: no such table: blog_posts`,
 		},
+		{
+			stmt:  "CREATE TABLE bar (id int PRIMARY KEY, index_id string REFERENCES __indexes__)",
+			error: `validation error: column bar.index_id can't reference builtin table __indexes__`,
+		},
 		// happy path:
 		{
 			stmt: `
-				CREATETABLE blog_posts (
-					id string PRIMARYKEY,
+				CREATE TABLE blog_posts (
+					id string PRIMARY KEY,
 					title string
 				)
 			`,
@@ -28,9 +32,10 @@ func TestCreateTable(t *testing.T) {
 		},
 		{
 			stmt: `
-				CREATETABLE comments (
-					id string PRIMARYKEY,
-					blog_post_id string REFERENCESTABLE blog_posts,
+				CREATE TABLE comments (
+					id string PRIMARY KEY,
+					blog_post_id string REFERENCES blog_posts,
+					parent_id string REFERENCES comments,
 					body string
 				)
 			`,
@@ -3,6 +3,7 @@ package treesql
 import (
 	"context"
 	"errors"
+	"sync"
 
 	"github.com/boltdb/bolt"
 	"github.com/gorilla/websocket"
@@ -13,6 +14,7 @@ type Database struct {
 	BoltDB           *bolt.DB
 	Connections      map[ConnectionID]*Connection
 	NextConnectionID int
+	connectionsMu    sync.Mutex // guards Connections and NextConnectionID
 
 	Ctx     context.Context
 	Metrics *Metrics
@@ -37,6 +39,9 @@ func NewDatabase(dataFile string) (*Database, error) {
 	database.AddBuiltinSchema()
 	database.EnsureBuiltinSchema()
 	database.LoadUserSchema()
+	if err := database.addMissingIndexes(); err != nil {
+		return nil, err
+	}
 
 	database.Metrics = NewMetrics(database)
 
@@ -46,14 +51,18 @@ func NewDatabase(dataFile string) (*Database, error) {
 // AddConnection connects a websocket to the database, s.t. the database
 // will interact with the connection.
 func (db *Database) AddConnection(wsConn *websocket.Conn) {
+	db.connectionsMu.Lock()
 	conn := NewConnection(wsConn, db, db.NextConnectionID)
 	db.NextConnectionID++
 	db.Connections[conn.ID] = conn
+	db.connectionsMu.Unlock()
 	conn.HandleStatements()
 }
 
 func (db *Database) removeConn(conn *Connection) {
+	db.connectionsMu.Lock()
 	delete(db.Connections, conn.ID)
+	db.connectionsMu.Unlock()
 	for _, table := range db.Schema.Tables {
 		table.removeListenersForConn(conn.ID)
 	}
@@ -81,9 +90,21 @@ func (db *Database) ValidateStatement(statement *Statement) error {
 	if statement.CreateTable != nil {
 		return db.validateCreateTable(statement.CreateTable)
 	}
+	if statement.CreateIndex != nil {
+		return db.validateCreateIndex(statement.CreateIndex)
+	}
 	if statement.Update != nil {
 		return db.validateUpdate(statement.Update)
 	}
+	if statement.Delete != nil {
+		return db.validateDelete(statement.Delete)
+	}
+	if statement.DropTable != nil {
+		return db.validateDropTable(statement.DropTable)
+	}
+	if statement.AlterTable != nil {
+		return db.validateAlterTable(statement.AlterTable)
+	}
 	return errors.New("unknown statement type")
 }
 
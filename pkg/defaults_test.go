@@ -0,0 +1,187 @@
+package treesql
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDefaults(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		// Verify that defaults are checked.
+		{
+			stmt:  `CREATE TABLE users (id serial PRIMARY KEY DEFAULT 1)`,
+			error: "validation error: serial column id is numbered automatically, so it can't have a DEFAULT",
+		},
+		{
+			stmt:  `CREATE TABLE users (id string PRIMARY KEY, karma int DEFAULT "lots")`,
+			error: "validation error: can't assign string value to int column karma",
+		},
+		{
+			stmt:  `CREATE TABLE users (id string PRIMARY KEY, joined timestamp DEFAULT "yesterday")`,
+			error: `validation error: "yesterday" isn't a valid timestamp; timestamp values are written in RFC 3339, e.g. "2006-01-02T15:04:05Z", between 1678 and 2262`,
+		},
+		{
+			stmt:  `CREATE TABLE users (id string PRIMARY KEY, name string NOT NULL DEFAULT NULL)`,
+			error: "validation error: column users.name is NOT NULL, so it needs a value",
+		},
+		{
+			stmt:  `CREATE TABLE users (id string PRIMARY KEY, karma int DEFAULT nope())`,
+			error: "validation error: no such function: nope",
+		},
+		// Happy path: inserts which leave columns out get their defaults.
+		{
+			stmt: `CREATE TABLE users (id serial PRIMARY KEY, name string NOT NULL DEFAULT "anonymous", token string DEFAULT gen_uuid(), joined timestamp DEFAULT now(), karma float DEFAULT 1 + 1)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO users (name) VALUES ("pete"), ("alice")`,
+			ack:  "INSERT 2",
+		},
+		{
+			stmt: `INSERT INTO users (karma, token) VALUES (0.5, NULL)`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt:  `INSERT INTO users (id) VALUES (3)`,
+			error: "executing insert: record already exists with primary key id=3",
+		},
+		{
+			query: `MANY users WHERE joined > "2018-01-01T00:00:00Z" { id, name, karma, token_length: length(token) }`,
+			initialResult: `[
+  {
+    "id": 1,
+    "karma": 2,
+    "name": "pete",
+    "token_length": 36
+  },
+  {
+    "id": 2,
+    "karma": 2,
+    "name": "alice",
+    "token_length": 36
+  },
+  {
+    "id": 3,
+    "karma": 0.5,
+    "name": "anonymous",
+    "token_length": null
+  }
+]`,
+		},
+		// Existing records get the default of an added column.
+		{
+			stmt: `ALTER TABLE users ADD COLUMN bio string NOT NULL DEFAULT $1`,
+			args: []interface{}{""},
+			ack:  "ALTER TABLE",
+		},
+		{
+			stmt: `ALTER TABLE users ADD COLUMN signup_number serial`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			stmt: `INSERT INTO users (name) VALUES ("bob")`,
+			ack:  "INSERT 1",
+		},
+		{
+			query: `MANY users { id, bio, signup_number }`,
+			initialResult: `[
+  {
+    "bio": "",
+    "id": 1,
+    "signup_number": 1
+  },
+  {
+    "bio": "",
+    "id": 2,
+    "signup_number": 2
+  },
+  {
+    "bio": "",
+    "id": 3,
+    "signup_number": 3
+  },
+  {
+    "bio": "",
+    "id": 4,
+    "signup_number": 4
+  }
+]`,
+		},
+		{
+			query: `MANY __columns__ WHERE table_name = "users" AND (default IS NOT NULL OR serial = TRUE) { name, type, default, serial }`,
+			initialResult: `[
+  {
+    "default": null,
+    "name": "id",
+    "serial": true,
+    "type": "int"
+  },
+  {
+    "default": "\"anonymous\"",
+    "name": "name",
+    "serial": false,
+    "type": "string"
+  },
+  {
+    "default": "gen_uuid()",
+    "name": "token",
+    "serial": false,
+    "type": "string"
+  },
+  {
+    "default": "now()",
+    "name": "joined",
+    "serial": false,
+    "type": "timestamp"
+  },
+  {
+    "default": "1 + 1",
+    "name": "karma",
+    "serial": false,
+    "type": "float"
+  },
+  {
+    "default": "\"\"",
+    "name": "bio",
+    "serial": false,
+    "type": "string"
+  },
+  {
+    "default": null,
+    "name": "signup_number",
+    "serial": true,
+    "type": "int"
+  }
+]`,
+		},
+	})
+}
+
+func TestInsertedKeys(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	if _, err := client.Exec(`CREATE TABLE blog_posts (id serial PRIMARY KEY, title string)`); err != nil {
+		t.Fatal(err)
+	}
+	// Generated keys are reported...
+	keys, err := client.Insert(`INSERT INTO blog_posts (title) VALUES ("hello world"), ("hello again world")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(keys) != "[1 2]" {
+		t.Fatalf("expected keys [1 2]; got %v", keys)
+	}
+	// ...and given ones aren't.
+	keys, err = client.Insert(`INSERT INTO blog_posts VALUES (5, "goodbye world")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys != nil {
+		t.Fatalf("expected no keys; got %v", keys)
+	}
+}
@@ -0,0 +1,97 @@
+package treesql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+func (db *Database) validateDelete(delete *Delete) error {
+	table, ok := db.Schema.Tables[delete.Table]
+	// table exists
+	if !ok {
+		return errorAt(delete.Pos, delete.Table, &NoSuchTable{
+			TableName: delete.Table,
+		})
+	}
+	// table isn't a builtin
+	if isBuiltinTable(delete.Table) {
+		return errorAt(delete.Pos, delete.Table, &BuiltinWriteAttempt{
+			TableName: delete.Table,
+		})
+	}
+	// where clause is valid
+	if err := db.validateExpr(delete.Where, table); err != nil {
+		return err
+	}
+	return db.validateReturning(delete.Returning)
+}
+
+func (conn *Connection) ExecuteDelete(delete *Delete, channel *Channel) error {
+	startTime := time.Now()
+
+	// Delete from table.
+	table := conn.Database.Schema.Tables[delete.Table]
+	var deletedRecords []*Record
+	var events []*TableEvent
+	deleteErr := conn.Database.BoltDB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(delete.Table))
+		// Find matching records first; Bolt doesn't allow modifying
+		// a bucket from inside ForEach.
+		if err := bucket.ForEach(func(key []byte, value []byte) error {
+			record := table.RecordFromBytes(value)
+			if delete.Where.Evaluate(record) {
+				deletedRecords = append(deletedRecords, record)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, record := range deletedRecords {
+			key := record.primaryKey()
+			if err := bucket.Delete([]byte(key)); err != nil {
+				return err
+			}
+			if err := unindexRecord(tx, record); err != nil {
+				return err
+			}
+			events = append(events, &TableEvent{TableName: delete.Table, OldRecord: record})
+		}
+		// Carry out the actions of references to the deleted records once
+		// they're all gone, so deleting referencing records along with
+		// the records they reference isn't restricted.
+		for _, record := range deletedRecords {
+			cascaded, err := conn.Database.keyRemoved(tx, table, record.GetField(table.PrimaryKey), nil)
+			if err != nil {
+				return err
+			}
+			events = append(events, cascaded...)
+		}
+		// records referencing them may have been updated
+		return checkConstraints(tx, events)
+	})
+	if deleteErr != nil {
+		return errors.Wrap(deleteErr, "executing delete")
+	}
+
+	// Send live query updates, including for records which referenced
+	// the deleted ones.
+	conn.Database.pushTableEvents(channel, events)
+
+	// Return the deleted records if they were asked for, or else an ack message.
+	if delete.Returning != nil {
+		if err := conn.executeReturning(delete.Returning, deletedRecords, channel); err != nil {
+			return errors.Wrap(err, "returning deleted records")
+		}
+	} else {
+		channel.WriteAckMessage(fmt.Sprintf("DELETE %d", len(deletedRecords)))
+	}
+
+	// Record latency.
+	endTime := time.Now()
+	duration := endTime.Sub(startTime)
+	conn.Database.Metrics.deleteLatency.Observe(float64(duration.Nanoseconds()))
+	return nil
+}
@@ -0,0 +1,138 @@
+package treesql
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDelete(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: "CREATE TABLE blog_posts (id string PRIMARY KEY, title string)",
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "hello world")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("1", "hello again world")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("2", "hello again world")`,
+			ack:  "INSERT 1",
+		},
+		// Verify that the table and column are checked.
+		{
+			stmt:  `DELETE FROM posts WHERE id = "0"`,
+			error: "validation error: no such table: posts",
+		},
+		{
+			stmt:  `DELETE FROM blog_posts WHERE author = "0"`,
+			error: "validation error: no such column in table blog_posts: author",
+		},
+		{
+			stmt:  `DELETE FROM __tables__ WHERE name = "blog_posts"`,
+			error: "validation error: attemtped to write to __tables__, but builtin tables are read-only",
+		},
+		{
+			stmt:  `DELETE FROM __record_listeners__ WHERE id = "1"`,
+			error: "validation error: attemtped to write to __record_listeners__, but builtin tables are read-only",
+		},
+		// Happy path.
+		{
+			stmt: `DELETE FROM blog_posts WHERE id = "0"`,
+			ack:  "DELETE 1",
+		},
+		{
+			stmt: `DELETE FROM blog_posts WHERE title = "hello again world"`,
+			ack:  "DELETE 2",
+		},
+		{
+			stmt: `DELETE FROM blog_posts WHERE id = "0"`,
+			ack:  "DELETE 0",
+		},
+		{
+			query:         `MANY blog_posts { id }`,
+			initialResult: `[]`,
+		},
+		// Verify the primary key can be reused.
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "hello world")`,
+			ack:  "INSERT 1",
+		},
+	})
+}
+
+func TestLiveDelete(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+		`CREATE TABLE comments (id string PRIMARY KEY, blog_post_id string REFERENCES blog_posts, body string)`,
+		`INSERT INTO blog_posts VALUES ("0", "hello world")`,
+		`INSERT INTO comments VALUES ("0", "0", "nice post")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`
+		MANY blog_posts {
+			id,
+			comments: MANY comments {
+				id
+			}
+		} live
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		// The comment is reachable via both a record listener and a filtered
+		// table listener; it should only be announced once.
+		msg := <-lqChan.Updates
+		if msg.Type != RecordDeleteMessage {
+			done <- fmt.Errorf("expected %v but got %v", RecordDeleteMessage, msg.Type)
+			return
+		}
+		path := msg.RecordDeleteMessage.QueryPath
+		if len(path) != 3 || path[0]["id"] != "0" || path[1]["selection"] != "comments" || path[2]["id"] != "0" {
+			done <- fmt.Errorf("unexpected query path %v", path)
+			return
+		}
+		// Then the post, which was in the initial result.
+		msg = <-lqChan.Updates
+		if msg.Type != RecordDeleteMessage {
+			done <- fmt.Errorf("expected %v but got %v", RecordDeleteMessage, msg.Type)
+			return
+		}
+		path = msg.RecordDeleteMessage.QueryPath
+		if len(path) != 1 || path[0]["id"] != "0" {
+			done <- fmt.Errorf("unexpected query path %v", path)
+			return
+		}
+		done <- nil
+	}()
+
+	if _, err := client.Exec(`DELETE FROM comments WHERE id = "0"`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Exec(`DELETE FROM blog_posts WHERE id = "0"`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,170 @@
+package treesql
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	clog "github.com/vilterp/treesql/pkg/log"
+)
+
+func (db *Database) validateDropTable(drop *DropTable) error {
+	// table exists, and isn't a builtin
+	if _, ok := db.Schema.Tables[drop.Name]; !ok {
+		return errorAt(drop.Pos, drop.Name, &NoSuchTable{TableName: drop.Name})
+	}
+	if isBuiltinTable(drop.Name) {
+		return errorAt(drop.Pos, drop.Name, &BuiltinWriteAttempt{TableName: drop.Name})
+	}
+	// other tables don't reference it, unless we're removing the references
+	references := db.referencesToTable(drop.Name)
+	if len(references) > 0 && !drop.Cascade {
+		referencedBy := make([]string, len(references))
+		for idx, reference := range references {
+			referencedBy[idx] = fmt.Sprintf("%s.%s", reference.table.Name, reference.column.Name)
+		}
+		return errorAt(drop.Pos, drop.Name, &TableReferenced{TableName: drop.Name, ReferencedBy: referencedBy})
+	}
+	return nil
+}
+
+type columnOfTable struct {
+	table  *TableDescriptor
+	column *ColumnDescriptor
+}
+
+// referencesToTable returns the columns of other tables which reference
+// the given one, sorted by table and column name.
+func (db *Database) referencesToTable(tableName string) []columnOfTable {
+	var references []columnOfTable
+	for _, table := range db.Schema.Tables {
+		if table.Name == tableName {
+			continue
+		}
+		for _, columnName := range table.referencesTo(tableName) {
+			references = append(references, columnOfTable{table: table, column: table.getColumn(columnName)})
+		}
+	}
+	sort.Slice(references, func(i, j int) bool {
+		if references[i].table.Name != references[j].table.Name {
+			return references[i].table.Name < references[j].table.Name
+		}
+		return references[i].column.Name < references[j].column.Name
+	})
+	return references
+}
+
+func (conn *Connection) ExecuteDropTable(drop *DropTable, channel *Channel) error {
+	db := conn.Database
+	table := db.Schema.Tables[drop.Name]
+	// validation made sure these are only here if we're cascading
+	references := db.referencesToTable(drop.Name)
+
+	tableRecord := table.ToRecord(db)
+	columnRecords := make([]*Record, len(table.Columns))
+	for idx, column := range table.Columns {
+		columnRecords[idx] = column.ToRecord(drop.Name, db)
+	}
+	var indexRecords []*Record
+	for _, column := range table.Columns {
+		if column.Indexed {
+			indexRecords = append(indexRecords, column.IndexToRecord(drop.Name, db))
+		}
+	}
+	constraintRecords := make([]*Record, len(table.Constraints))
+	for idx, constraint := range table.Constraints {
+		constraintRecords[idx] = constraint.ToRecord(drop.Name, db)
+	}
+	// Referencing tables get new descriptors without the references,
+	// which replace the old ones once the change is committed, as for
+	// ALTER TABLE.
+	unreferencedTables := map[string]*TableDescriptor{}
+	oldReferenceRecords := make([]*Record, len(references))
+	newReferenceRecords := make([]*Record, len(references))
+	for idx, reference := range references {
+		unreferencedTable, ok := unreferencedTables[reference.table.Name]
+		if !ok {
+			copied := *reference.table
+			copied.Columns = append([]*ColumnDescriptor{}, reference.table.Columns...)
+			unreferencedTable = &copied
+			unreferencedTables[reference.table.Name] = unreferencedTable
+		}
+		unreferenced := *reference.column
+		unreferenced.ReferencesColumn = nil
+		for columnIdx, column := range unreferencedTable.Columns {
+			if column == reference.column {
+				unreferencedTable.Columns[columnIdx] = &unreferenced
+			}
+		}
+		oldReferenceRecords[idx] = reference.column.ToRecord(reference.table.Name, db)
+		newReferenceRecords[idx] = unreferenced.ToRecord(reference.table.Name, db)
+	}
+	updateErr := db.BoltDB.Update(func(tx *bolt.Tx) error {
+		// remove the table's records and indexes, and its rows in
+		// __tables__, __columns__ and __constraints__
+		if err := tx.DeleteBucket([]byte(drop.Name)); err != nil {
+			return err
+		}
+		if err := tx.Bucket([]byte("__tables__")).Delete([]byte(drop.Name)); err != nil {
+			return err
+		}
+		columnsBucket := tx.Bucket([]byte("__columns__"))
+		for _, column := range table.Columns {
+			if err := columnsBucket.Delete([]byte(fmt.Sprintf("%d", column.ID))); err != nil {
+				return err
+			}
+			if err := dropSerial(tx, column); err != nil {
+				return err
+			}
+			if err := dropIndex(tx, column); err != nil {
+				return err
+			}
+		}
+		constraintsBucket := tx.Bucket([]byte("__constraints__"))
+		for _, constraint := range table.Constraints {
+			if err := constraintsBucket.Delete([]byte(constraint.Name)); err != nil {
+				return err
+			}
+		}
+		// remove references to it
+		for idx, reference := range references {
+			key := []byte(fmt.Sprintf("%d", reference.column.ID))
+			if err := columnsBucket.Put(key, newReferenceRecords[idx].ToBytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if updateErr != nil {
+		return errors.Wrap(updateErr, "dropping table")
+	}
+
+	// update in-memory schema
+	db.Schema.tablesMu.Lock()
+	delete(db.Schema.Tables, drop.Name)
+	for name, unreferencedTable := range unreferencedTables {
+		db.Schema.Tables[name] = unreferencedTable
+	}
+	db.Schema.tablesMu.Unlock()
+	// push live query messages
+	db.PushTableEvent(channel, "__tables__", tableRecord, nil)
+	for _, columnRecord := range columnRecords {
+		db.PushTableEvent(channel, "__columns__", columnRecord, nil)
+	}
+	for _, constraintRecord := range constraintRecords {
+		db.PushTableEvent(channel, "__constraints__", constraintRecord, nil)
+	}
+	for _, indexRecord := range indexRecords {
+		db.PushTableEvent(channel, "__indexes__", indexRecord, nil)
+	}
+	for idx := range references {
+		db.PushTableEvent(channel, "__columns__", oldReferenceRecords[idx], newReferenceRecords[idx])
+	}
+	// end live queries which read from the table
+	db.closeLiveQueries(table, &TableDropped{TableName: drop.Name})
+
+	clog.Println(channel, "dropped table", drop.Name)
+	channel.WriteAckMessage("DROP TABLE")
+	return nil
+}
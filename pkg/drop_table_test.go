@@ -0,0 +1,145 @@
+package treesql
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDropTable(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE comments (id string PRIMARY KEY, post_id string REFERENCES blog_posts, body string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "hello world")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO comments VALUES ("0", "0", "nice post")`,
+			ack:  "INSERT 1",
+		},
+		// Verify that the table is checked.
+		{
+			stmt:  `DROP TABLE posts`,
+			error: "validation error: no such table: posts",
+		},
+		{
+			stmt:  `DROP TABLE __columns__`,
+			error: "validation error: attemtped to write to __columns__, but builtin tables are read-only",
+		},
+		{
+			stmt:  `DROP TABLE blog_posts`,
+			error: "validation error: can't drop table blog_posts, since it's referenced by comments.post_id; use CASCADE to remove the references",
+		},
+		// Happy path: the reference goes, but the referencing column stays.
+		{
+			stmt: `DROP TABLE blog_posts CASCADE`,
+			ack:  "DROP TABLE",
+		},
+		{
+			query: `MANY blog_posts { id }`,
+			error: "validation error: no such table: blog_posts",
+		},
+		{
+			query: `MANY __columns__ WHERE table_name = "comments" { name, references }`,
+			initialResult: `[
+  {
+    "name": "id",
+    "references": null
+  },
+  {
+    "name": "post_id",
+    "references": null
+  },
+  {
+    "name": "body",
+    "references": null
+  }
+]`,
+		},
+		{
+			query: `MANY comments { id, post_id }`,
+			initialResult: `[
+  {
+    "id": "0",
+    "post_id": "0"
+  }
+]`,
+		},
+		// Verify the name can be reused, starting out empty.
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, body string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			query:         `MANY blog_posts { id, body }`,
+			initialResult: `[]`,
+		},
+		{
+			stmt: `DROP TABLE comments`,
+			ack:  "DROP TABLE",
+		},
+		{
+			query:         `MANY __columns__ WHERE table_name = "comments" { name }`,
+			initialResult: `[]`,
+		},
+	})
+}
+
+func TestLiveDropTable(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+		`CREATE TABLE comments (id string PRIMARY KEY, post_id string REFERENCES blog_posts, body string)`,
+		`INSERT INTO blog_posts VALUES ("0", "hello world")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`LIVE MANY blog_posts { id, comments: MANY comments { id } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	if _, err := client.Exec(`DROP TABLE comments`); err != nil {
+		t.Fatal(err)
+	}
+	// The live query reads from the dropped table, so it's ended.
+	msg := <-updates
+	if msg.Type != ErrorMessage {
+		t.Fatalf("expected %v but got %v", ErrorMessage, msg.Type)
+	}
+	if msg.ErrorDetail.Code != "table_dropped" {
+		t.Fatalf("expected code table_dropped; got %s", msg.ErrorDetail.Code)
+	}
+	cause := errors.Cause(newStatementError(msg.ErrorDetail))
+	if dropped, ok := cause.(*TableDropped); !ok || dropped.TableName != "comments" {
+		t.Fatalf("expected comments to have been dropped; got %#v", cause)
+	}
+
+	// Writes to the other table no longer go to it.
+	if _, err := client.Exec(`INSERT INTO blog_posts VALUES ("1", "hello again world")`); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-updates:
+		t.Fatalf("expected no more updates; got %v", msg.Type)
+	default:
+	}
+}
@@ -1,6 +1,9 @@
 package treesql
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type NoSuchTable struct {
 	TableName string
@@ -37,6 +40,163 @@ func (e *InsertWrongNumFields) Error() string {
 	return fmt.Sprintf("table %s has %d columns, but insert statement provided %d", e.TableName, e.Wanted, e.Got)
 }
 
+type InsertWrongNumValues struct {
+	Row    int
+	Wanted int
+	Got    int
+}
+
+func (e *InsertWrongNumValues) Error() string {
+	return fmt.Sprintf("insert statement lists %d columns, but row %d provides %d values", e.Wanted, e.Row, e.Got)
+}
+
+type InsertMissingPrimaryKey struct {
+	TableName  string
+	ColumnName string
+}
+
+func (e *InsertMissingPrimaryKey) Error() string {
+	return fmt.Sprintf("insert into %s must provide primary key column %s", e.TableName, e.ColumnName)
+}
+
+type DuplicateColumn struct {
+	ColumnName string
+}
+
+func (e *DuplicateColumn) Error() string {
+	return fmt.Sprintf("column listed more than once: %s", e.ColumnName)
+}
+
+type ColumnAlreadyExists struct {
+	TableName  string
+	ColumnName string
+}
+
+func (e *ColumnAlreadyExists) Error() string {
+	return fmt.Sprintf("column already exists in table %s: %s", e.TableName, e.ColumnName)
+}
+
+type DropPrimaryKey struct {
+	TableName  string
+	ColumnName string
+}
+
+func (e *DropPrimaryKey) Error() string {
+	return fmt.Sprintf("can't drop column %s.%s, since it's the primary key", e.TableName, e.ColumnName)
+}
+
+type ColumnAlreadyReferences struct {
+	TableName  string
+	ColumnName string
+	References string
+}
+
+func (e *ColumnAlreadyReferences) Error() string {
+	return fmt.Sprintf("column %s.%s already references table %s", e.TableName, e.ColumnName, e.References)
+}
+
+type IndexAlreadyExists struct {
+	TableName  string
+	ColumnName string
+}
+
+func (e *IndexAlreadyExists) Error() string {
+	return fmt.Sprintf("column %s.%s is already indexed", e.TableName, e.ColumnName)
+}
+
+type NoReference struct {
+	TableName  string
+	ColumnName string
+}
+
+func (e *NoReference) Error() string {
+	return fmt.Sprintf("column %s.%s doesn't reference a table", e.TableName, e.ColumnName)
+}
+
+type ReferenceToBuiltinTable struct {
+	TableName  string
+	ColumnName string
+	References string
+}
+
+func (e *ReferenceToBuiltinTable) Error() string {
+	return fmt.Sprintf("column %s.%s can't reference builtin table %s", e.TableName, e.ColumnName, e.References)
+}
+
+type ReferenceTypeMismatch struct {
+	TableName      string
+	ColumnName     string
+	ColumnType     ColumnType
+	References     string
+	PrimaryKeyType ColumnType
+}
+
+func (e *ReferenceTypeMismatch) Error() string {
+	return fmt.Sprintf(
+		"column %s.%s is of type %s, so it can't reference table %s, whose primary key is of type %s",
+		e.TableName, e.ColumnName, TypeToName[e.ColumnType], e.References, TypeToName[e.PrimaryKeyType],
+	)
+}
+
+type ForeignKeyViolation struct {
+	TableName  string
+	ColumnName string
+	References string
+	Key        string
+}
+
+func (e *ForeignKeyViolation) Error() string {
+	return fmt.Sprintf(
+		"column %s.%s references a record in %s with primary key %s, but there isn't one",
+		e.TableName, e.ColumnName, e.References, e.Key,
+	)
+}
+
+type RecordReferenced struct {
+	TableName    string
+	Key          string
+	ReferencedBy string // as table.column
+	Deleted      bool   // or else its primary key changed
+}
+
+func (e *RecordReferenced) Error() string {
+	change := "changing its primary key"
+	if e.Deleted {
+		change = "deleting it"
+	}
+	return fmt.Sprintf(
+		"record in %s with primary key %s is referenced by %s, which restricts %s",
+		e.TableName, e.Key, e.ReferencedBy, change,
+	)
+}
+
+type UniqueViolation struct {
+	TableName      string
+	ConstraintName string
+	Values         string // e.g. `email = "pete@example.com"`
+}
+
+func (e *UniqueViolation) Error() string {
+	return fmt.Sprintf(
+		"record already exists in %s with %s, violating unique constraint %s",
+		e.TableName, e.Values, e.ConstraintName,
+	)
+}
+
+type CheckViolation struct {
+	TableName      string
+	ConstraintName string
+	Check          string
+	Key            string
+}
+
+func (e *CheckViolation) Error() string {
+	return fmt.Sprintf(
+		"record in %s with primary key %s violates check constraint %s: %s",
+		e.TableName, e.Key, e.ConstraintName, e.Check,
+	)
+}
+
 type TableAlreadyExists struct {
 	TableName string
 }
@@ -45,6 +205,26 @@ func (e *TableAlreadyExists) Error() string {
 	return fmt.Sprintf("table already exists: %s", e.TableName)
 }
 
+type TableReferenced struct {
+	TableName    string
+	ReferencedBy []string // as table.column
+}
+
+func (e *TableReferenced) Error() string {
+	return fmt.Sprintf(
+		"can't drop table %s, since it's referenced by %s; use CASCADE to remove the references",
+		e.TableName, strings.Join(e.ReferencedBy, ", "),
+	)
+}
+
+type TableDropped struct {
+	TableName string
+}
+
+func (e *TableDropped) Error() string {
+	return fmt.Sprintf("table %s was dropped", e.TableName)
+}
+
 type NonexistentType struct {
 	TypeName string
 }
@@ -70,6 +250,238 @@ func (e *NoReferenceForJoin) Error() string {
 	return fmt.Sprintf("query requires a column in table `%s` referencing table `%s`; none found", e.FromTable, e.ToTable)
 }
 
+type AmbiguousJoin struct {
+	FromTable string
+	ToTable   string
+	Columns   []string
+}
+
+func (e *AmbiguousJoin) Error() string {
+	return fmt.Sprintf(
+		"table `%s` has several columns referencing table `%s` (%s); choose one with VIA",
+		e.FromTable, e.ToTable, strings.Join(e.Columns, ", "),
+	)
+}
+
+type ViaNotReference struct {
+	FromTable  string
+	ColumnName string
+	ToTable    string
+}
+
+func (e *ViaNotReference) Error() string {
+	return fmt.Sprintf("column `%s.%s` doesn't reference table `%s`", e.FromTable, e.ColumnName, e.ToTable)
+}
+
+type RecursiveNotSelfReference struct {
+	TableName  string
+	TableAbove *string
+}
+
+func (e *RecursiveNotSelfReference) Error() string {
+	if e.TableAbove == nil {
+		return fmt.Sprintf("RECURSIVE only applies to nested selections; got top-level %s", e.TableName)
+	}
+	return fmt.Sprintf(
+		"RECURSIVE selections must be of the same table as the one above; got %s inside %s",
+		e.TableName, *e.TableAbove,
+	)
+}
+
+type InvalidMaxDepth struct {
+	MaxDepth int
+}
+
+func (e *InvalidMaxDepth) Error() string {
+	return fmt.Sprintf("MAXDEPTH must be at least 1; got %d", e.MaxDepth)
+}
+
+type ComparisonTypeMismatch struct {
+	Left  ColumnType
+	Right ColumnType
+}
+
+func (e *ComparisonTypeMismatch) Error() string {
+	return fmt.Sprintf("can't compare %s to %s", TypeToName[e.Left], TypeToName[e.Right])
+}
+
+type WindowOnOne struct {
+	TableName string
+}
+
+func (e *WindowOnOne) Error() string {
+	return fmt.Sprintf("ORDER BY, LIMIT and OFFSET only apply to MANY selections; got ONE %s", e.TableName)
+}
+
+type AggregateNeedsColumn struct {
+	Function string
+}
+
+func (e *AggregateNeedsColumn) Error() string {
+	return fmt.Sprintf("%s needs a column, e.g. %s table.column", e.Function, e.Function)
+}
+
+type AggregateWrongType struct {
+	Function string
+	Type     ColumnType
+}
+
+func (e *AggregateWrongType) Error() string {
+	return fmt.Sprintf("%s only applies to int and float columns; got %s", e.Function, TypeToName[e.Type])
+}
+
+type WrongNumArguments struct {
+	Wanted int
+	Got    int
+}
+
+func (e *WrongNumArguments) Error() string {
+	return fmt.Sprintf("statement has %d placeholders, but %d arguments were given", e.Wanted, e.Got)
+}
+
+type UnsupportedArgument struct {
+	Placeholder string
+	Value       interface{}
+}
+
+func (e *UnsupportedArgument) Error() string {
+	return fmt.Sprintf("can't bind %#v to %s; arguments must be strings, numbers, bools or null", e.Value, e.Placeholder)
+}
+
+type UnboundPlaceholder struct {
+	Placeholder string
+}
+
+func (e *UnboundPlaceholder) Error() string {
+	return fmt.Sprintf("placeholder %s wasn't bound to an argument", e.Placeholder)
+}
+
+type OperatorWrongType struct {
+	Op     string
+	Wanted ColumnType
+	Got    ColumnType
+}
+
+func (e *OperatorWrongType) Error() string {
+	return fmt.Sprintf("operator %s only applies to %s values; got %s", e.Op, TypeToName[e.Wanted], TypeToName[e.Got])
+}
+
+type NoSuchFunction struct {
+	Name string
+}
+
+func (e *NoSuchFunction) Error() string {
+	return fmt.Sprintf("no such function: %s", e.Name)
+}
+
+type FunctionWrongNumArgs struct {
+	Name   string
+	Wanted int
+	Got    int
+}
+
+func (e *FunctionWrongNumArgs) Error() string {
+	return fmt.Sprintf("%s takes %d arguments; given %d", e.Name, e.Wanted, e.Got)
+}
+
+type FunctionWrongType struct {
+	Name   string
+	Wanted ColumnType
+	Got    ColumnType
+}
+
+func (e *FunctionWrongType) Error() string {
+	return fmt.Sprintf("%s takes %s arguments; given %s", e.Name, TypeToName[e.Wanted], TypeToName[e.Got])
+}
+
+type AssignmentTypeMismatch struct {
+	ColumnName string
+	ColumnType ColumnType
+	ValueType  ColumnType
+}
+
+func (e *AssignmentTypeMismatch) Error() string {
+	return fmt.Sprintf(
+		"can't assign %s value to %s column %s",
+		TypeToName[e.ValueType], TypeToName[e.ColumnType], e.ColumnName,
+	)
+}
+
+type InvalidInt struct {
+	Number string
+}
+
+func (e *InvalidInt) Error() string {
+	return fmt.Sprintf("%s isn't a valid int; ints are whole numbers from %d to %d", e.Number, MinInt, MaxInt)
+}
+
+// valueFormats describes how values of types written as strings are written.
+var valueFormats = map[ColumnType]string{
+	TypeTimestamp: `in RFC 3339, e.g. "2006-01-02T15:04:05Z", between 1678 and 2262`,
+	TypeBytes:     "in base64",
+}
+
+type InvalidValue struct {
+	Type ColumnType
+	Text string
+}
+
+func (e *InvalidValue) Error() string {
+	message := fmt.Sprintf("%q isn't a valid %s", e.Text, TypeToName[e.Type])
+	if format, ok := valueFormats[e.Type]; ok {
+		message += fmt.Sprintf("; %s values are written %s", TypeToName[e.Type], format)
+	}
+	return message
+}
+
+type NullViolation struct {
+	TableName  string
+	ColumnName string
+}
+
+func (e *NullViolation) Error() string {
+	return fmt.Sprintf("column %s.%s is NOT NULL, so it needs a value", e.TableName, e.ColumnName)
+}
+
+type SerialDefault struct {
+	ColumnName string
+}
+
+func (e *SerialDefault) Error() string {
+	return fmt.Sprintf("serial column %s is numbered automatically, so it can't have a DEFAULT", e.ColumnName)
+}
+
+type SequenceExhausted struct {
+	ColumnName string
+}
+
+func (e *SequenceExhausted) Error() string {
+	return fmt.Sprintf("serial column %s has run out of values; ints go up to %d", e.ColumnName, MaxInt)
+}
+
+type SyntaxError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// SyntaxErrors are the syntax errors found in a statement, in order.
+type SyntaxErrors struct {
+	Errors []error
+}
+
+func (e *SyntaxErrors) Error() string {
+	messages := make([]string, len(e.Errors))
+	for idx, err := range e.Errors {
+		messages[idx] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
 // TODO: maybe just use errors.Wrap for these
 
 type ParseError struct {
@@ -0,0 +1,209 @@
+package treesql
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorDetail is the structured form of an error, sent to clients
+// along with its message so they can point at the offending part of
+// the statement and tell kinds of errors apart.
+type ErrorDetail struct {
+	Code    string
+	Message string
+	Span    *Span           // nil if the error isn't about any one part of the statement
+	Fields  json.RawMessage // e.g. {"TableName": "blog_posts"} for no_such_table
+	// Others are further errors found in the statement, e.g. when it has
+	// several syntax errors. Message covers them too.
+	Others []*ErrorDetail `json:",omitempty"`
+}
+
+// Span is a stretch of a statement's text. End is exclusive.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// errorCodes identifies each kind of error to clients, which branch on
+// them: once added, codes shouldn't change.
+var errorCodes = map[string]func() error{
+	"syntax_error":                 func() error { return &SyntaxError{} },
+	"no_such_table":                func() error { return &NoSuchTable{} },
+	"no_such_column":               func() error { return &NoSuchColumn{} },
+	"builtin_write_attempt":        func() error { return &BuiltinWriteAttempt{} },
+	"insert_wrong_num_fields":      func() error { return &InsertWrongNumFields{} },
+	"insert_wrong_num_values":      func() error { return &InsertWrongNumValues{} },
+	"insert_missing_primary_key":   func() error { return &InsertMissingPrimaryKey{} },
+	"duplicate_column":             func() error { return &DuplicateColumn{} },
+	"table_already_exists":         func() error { return &TableAlreadyExists{} },
+	"column_already_exists":        func() error { return &ColumnAlreadyExists{} },
+	"drop_primary_key":             func() error { return &DropPrimaryKey{} },
+	"column_already_references":    func() error { return &ColumnAlreadyReferences{} },
+	"no_reference":                 func() error { return &NoReference{} },
+	"index_already_exists":         func() error { return &IndexAlreadyExists{} },
+	"reference_type_mismatch":      func() error { return &ReferenceTypeMismatch{} },
+	"reference_to_builtin_table":   func() error { return &ReferenceToBuiltinTable{} },
+	"table_referenced":             func() error { return &TableReferenced{} },
+	"table_dropped":                func() error { return &TableDropped{} },
+	"nonexistent_type":             func() error { return &NonexistentType{} },
+	"wrong_num_primary_keys":       func() error { return &WrongNoPrimaryKey{} },
+	"no_reference_for_join":        func() error { return &NoReferenceForJoin{} },
+	"ambiguous_join":               func() error { return &AmbiguousJoin{} },
+	"via_not_reference":            func() error { return &ViaNotReference{} },
+	"recursive_not_self_reference": func() error { return &RecursiveNotSelfReference{} },
+	"invalid_max_depth":            func() error { return &InvalidMaxDepth{} },
+	"comparison_type_mismatch":     func() error { return &ComparisonTypeMismatch{} },
+	"window_on_one":                func() error { return &WindowOnOne{} },
+	"aggregate_needs_column":       func() error { return &AggregateNeedsColumn{} },
+	"aggregate_wrong_type":         func() error { return &AggregateWrongType{} },
+	"wrong_num_arguments":          func() error { return &WrongNumArguments{} },
+	"unsupported_argument":         func() error { return &UnsupportedArgument{} },
+	"unbound_placeholder":          func() error { return &UnboundPlaceholder{} },
+	"operator_wrong_type":          func() error { return &OperatorWrongType{} },
+	"no_such_function":             func() error { return &NoSuchFunction{} },
+	"function_wrong_num_args":      func() error { return &FunctionWrongNumArgs{} },
+	"function_wrong_type":          func() error { return &FunctionWrongType{} },
+	"assignment_type_mismatch":     func() error { return &AssignmentTypeMismatch{} },
+	"invalid_int":                  func() error { return &InvalidInt{} },
+	"invalid_value":                func() error { return &InvalidValue{} },
+	"null_violation":               func() error { return &NullViolation{} },
+	"serial_default":               func() error { return &SerialDefault{} },
+	"sequence_exhausted":           func() error { return &SequenceExhausted{} },
+	"record_already_exists":        func() error { return &RecordAlreadyExists{} },
+	"foreign_key_violation":        func() error { return &ForeignKeyViolation{} },
+	"record_referenced":            func() error { return &RecordReferenced{} },
+	"unique_violation":             func() error { return &UniqueViolation{} },
+	"check_violation":              func() error { return &CheckViolation{} },
+}
+
+// unknownErrorCode is the code of errors not listed in errorCodes.
+const unknownErrorCode = "unknown"
+
+var codesByType = map[reflect.Type]string{}
+
+func init() {
+	for code, newError := range errorCodes {
+		codesByType[reflect.TypeOf(newError())] = code
+	}
+}
+
+// positionedError is an error about a particular token of a statement:
+// the first one at or after pos which reads token, or the one at pos
+// if token is empty.
+type positionedError struct {
+	error
+	pos   Position
+	token string
+}
+
+// errorAt attaches a position to err, unless it already has one from
+// further down the tree.
+func errorAt(pos Position, token string, err error) error {
+	if _, ok := err.(*positionedError); ok {
+		return err
+	}
+	return &positionedError{error: err, pos: pos, token: token}
+}
+
+// newErrorDetail describes an error which occurred running statement.
+func newErrorDetail(err error, statement string) *ErrorDetail {
+	cause := err
+	switch wrapper := cause.(type) {
+	case *ParseError:
+		cause = wrapper.error
+	case *ValidationError:
+		cause = wrapper.error
+	}
+	var others []error
+	if syntaxErrors, ok := cause.(*SyntaxErrors); ok {
+		cause, others = syntaxErrors.Errors[0], syntaxErrors.Errors[1:]
+	}
+	detail := describeError(cause, statement)
+	detail.Message = err.Error()
+	for _, other := range others {
+		detail.Others = append(detail.Others, describeError(other, statement))
+	}
+	return detail
+}
+
+func describeError(err error, statement string) *ErrorDetail {
+	detail := &ErrorDetail{
+		Code:    unknownErrorCode,
+		Message: err.Error(),
+	}
+	// errors from executing statements are wrapped with what was
+	// being done, e.g. "executing insert"
+	err = errors.Cause(err)
+	if positioned, ok := err.(*positionedError); ok {
+		detail.Span = tokenSpan(statement, positioned.pos, positioned.token)
+		err = positioned.error
+	}
+	if code, ok := codesByType[reflect.TypeOf(err)]; ok {
+		detail.Code = code
+		if fields, err := json.Marshal(err); err == nil {
+			detail.Fields = fields
+		}
+	}
+	return detail
+}
+
+// tokenSpan finds the span of the token an error is about. If it can't
+// be found (e.g. at the end of the statement), the span is empty.
+func tokenSpan(statement string, pos Position, token string) *Span {
+	for _, tok := range lex(statement) {
+		if tok.typ == eofToken {
+			break
+		}
+		if tok.pos.Offset < pos.Offset {
+			continue
+		}
+		if token != "" && !strings.EqualFold(tok.text, token) {
+			continue
+		}
+		return &Span{Start: tok.pos, End: tok.pos.advance(tok.raw)}
+	}
+	return &Span{Start: pos, End: pos}
+}
+
+// StatementError is an error returned by the server for a statement.
+// Err is the error as the server had it, e.g. a *NoSuchColumn; use
+// errors.Cause to get at it.
+type StatementError struct {
+	Code    string
+	Message string
+	Span    *Span
+	Err     error
+	Others  []*StatementError
+}
+
+func (e *StatementError) Error() string {
+	return e.Message
+}
+
+func (e *StatementError) Cause() error {
+	return e.Err
+}
+
+func newStatementError(detail *ErrorDetail) *StatementError {
+	statementErr := &StatementError{
+		Code:    detail.Code,
+		Message: detail.Message,
+		Span:    detail.Span,
+	}
+	if newError, ok := errorCodes[detail.Code]; ok {
+		typed := newError()
+		if err := json.Unmarshal(detail.Fields, typed); err == nil {
+			statementErr.Err = typed
+		}
+	}
+	if statementErr.Err == nil {
+		statementErr.Err = errors.New(detail.Message)
+	}
+	for _, other := range detail.Others {
+		statementErr.Others = append(statementErr.Others, newStatementError(other))
+	}
+	return statementErr
+}
@@ -0,0 +1,123 @@
+package treesql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestErrorDetails(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	if _, err := client.Exec(`CREATE TABLE blog_posts (id string PRIMARY KEY, title string, views int)`); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		query string
+		code  string
+		span  Span
+		cause error // if given
+	}{
+		{
+			query: `MANY blog_posts { id, }`,
+			code:  "syntax_error",
+			span:  Span{Start: Position{Offset: 22, Line: 1, Column: 23}, End: Position{Offset: 23, Line: 1, Column: 24}},
+		},
+		{
+			query: `MANY blog_post { id }`,
+			code:  "no_such_table",
+			span:  Span{Start: Position{Offset: 5, Line: 1, Column: 6}, End: Position{Offset: 14, Line: 1, Column: 15}},
+			cause: &NoSuchTable{TableName: "blog_post"},
+		},
+		{
+			query: "MANY blog_posts\nWHERE id = \"0\" AND author = \"pete\" { id }",
+			code:  "no_such_column",
+			span:  Span{Start: Position{Offset: 35, Line: 2, Column: 20}, End: Position{Offset: 41, Line: 2, Column: 26}},
+			cause: &NoSuchColumn{TableName: "blog_posts", ColumnName: "author"},
+		},
+		{
+			query: `MANY blog_posts { id, score: views - title }`,
+			code:  "operator_wrong_type",
+			span:  Span{Start: Position{Offset: 35, Line: 1, Column: 36}, End: Position{Offset: 36, Line: 1, Column: 37}},
+			cause: &OperatorWrongType{Op: "-", Wanted: TypeInt, Got: TypeString},
+		},
+	}
+	for idx, testCase := range cases {
+		_, err := client.Query(testCase.query)
+		statementErr, ok := err.(*StatementError)
+		if !ok {
+			t.Fatalf("case %d: expected a *StatementError; got %#v", idx, err)
+		}
+		if statementErr.Code != testCase.code {
+			t.Fatalf("case %d: expected code %s; got %s", idx, testCase.code, statementErr.Code)
+		}
+		if statementErr.Span == nil || *statementErr.Span != testCase.span {
+			t.Fatalf("case %d: expected span %+v; got %+v", idx, testCase.span, statementErr.Span)
+		}
+		if testCase.cause == nil {
+			continue
+		}
+		if cause := errors.Cause(err); !reflect.DeepEqual(cause, testCase.cause) {
+			t.Fatalf("case %d: expected cause %#v; got %#v", idx, testCase.cause, cause)
+		}
+	}
+}
+
+func TestExecutionErrorDetails(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE users (id string PRIMARY KEY, name string)`,
+		`CREATE TABLE blog_posts (id string PRIMARY KEY, author_id string REFERENCES users, title string)`,
+		`INSERT INTO users VALUES ("0", "pete")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Errors which happen while running a statement, rather than
+	// validating it, are described the same way.
+	cases := []struct {
+		stmt  string
+		code  string
+		cause error
+	}{
+		{
+			stmt:  `INSERT INTO users VALUES ("0", "alice")`,
+			code:  "record_already_exists",
+			cause: &RecordAlreadyExists{ColName: "id", Val: "0"},
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("0", "1", "hello world")`,
+			code:  "foreign_key_violation",
+			cause: &ForeignKeyViolation{TableName: "blog_posts", ColumnName: "author_id", References: "users", Key: "1"},
+		},
+	}
+	for idx, testCase := range cases {
+		_, err := client.Exec(testCase.stmt)
+		statementErr, ok := err.(*StatementError)
+		if !ok {
+			t.Fatalf("case %d: expected a *StatementError; got %#v", idx, err)
+		}
+		if statementErr.Code != testCase.code {
+			t.Fatalf("case %d: expected code %s; got %s", idx, testCase.code, statementErr.Code)
+		}
+		if cause := errors.Cause(err); !reflect.DeepEqual(cause, testCase.cause) {
+			t.Fatalf("case %d: expected cause %#v; got %#v", idx, testCase.cause, cause)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package treesql
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// validation
+
+func (db *Database) validateExpr(expr *Expr, table *TableDescriptor) error {
+	for _, and := range expr.Or {
+		for _, not := range and.And {
+			if not.Predicate.Parens != nil {
+				if err := db.validateExpr(not.Predicate.Parens, table); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := validateComparison(not.Predicate.Comparison, table); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateComparison(comparison *Comparison, table *TableDescriptor) error {
+	operands := comparison.operands()
+	// plain strings compared to e.g. a timestamp column are read as timestamps
+	for _, term := range operands {
+		if term.isPlainString() {
+			continue
+		}
+		termType, err := term.typeIn(table)
+		if err != nil {
+			return err
+		}
+		if termType != nil {
+			coercePlainStrings(operands, *termType)
+			break
+		}
+	}
+	// every operand has to be comparable to the first one
+	var leftType *ColumnType
+	for _, term := range operands {
+		termType, err := term.typeIn(table)
+		if err != nil {
+			return err
+		}
+		if termType == nil {
+			continue
+		}
+		if leftType == nil {
+			leftType = termType
+			continue
+		}
+		if !comparableTypes(*leftType, *termType) {
+			return errorAt(term.Pos, "", &ComparisonTypeMismatch{Left: *leftType, Right: *termType})
+		}
+	}
+	return nil
+}
+
+// isPlainString returns whether the term is a string literal not marked
+// with a type, e.g. `"2018-01-02T15:04:05Z"` but not `TIMESTAMP "2018-01-02T15:04:05Z"`.
+func (term *Term) isPlainString() bool {
+	return term.String != nil && term.TypeName == nil
+}
+
+// coercePlainStrings marks the plain strings among terms as being of
+// the given type, if it's one which is written as a string.
+func coercePlainStrings(terms []*Term, columnType ColumnType) {
+	if !writtenAsString(columnType) {
+		return
+	}
+	for _, term := range terms {
+		if term.isPlainString() {
+			typeName := TypeToName[columnType]
+			term.TypeName = &typeName
+		}
+	}
+}
+
+// typeIn returns the type of this term, or nil for NULL, which
+// can be compared to anything.
+func (term *Term) typeIn(table *TableDescriptor) (*ColumnType, error) {
+	var termType ColumnType
+	switch {
+	case term.Null:
+		return nil, nil
+	case term.Number != nil || term.Bool != nil || term.String != nil || term.Placeholder != nil:
+		value, err := term.value()
+		if err != nil {
+			return nil, errorAt(term.Pos, "", err)
+		}
+		termType = value.Type
+	case term.Column != nil:
+		column := table.getColumn(*term.Column)
+		if column == nil {
+			return nil, errorAt(term.Pos, "", &NoSuchColumn{TableName: table.Name, ColumnName: *term.Column})
+		}
+		termType = column.Type
+	}
+	return &termType, nil
+}
+
+// evaluation
+
+// Evaluate returns whether the record satisfies the expression.
+// The expression should have been validated against the record's table.
+func (expr *Expr) Evaluate(record *Record) bool {
+	for _, and := range expr.Or {
+		if and.evaluate(record) {
+			return true
+		}
+	}
+	return false
+}
+
+func (and *AndExpr) evaluate(record *Record) bool {
+	for _, not := range and.And {
+		if !not.evaluate(record) {
+			return false
+		}
+	}
+	return true
+}
+
+func (not *NotExpr) evaluate(record *Record) bool {
+	var result bool
+	if not.Predicate.Parens != nil {
+		result = not.Predicate.Parens.Evaluate(record)
+	} else {
+		result = not.Predicate.Comparison.evaluate(record)
+	}
+	return result != not.Not
+}
+
+func (comparison *Comparison) evaluate(record *Record) bool {
+	left := comparison.Left.evaluate(record)
+	if comparison.IsNull != nil {
+		return left.Null != comparison.IsNull.Not
+	}
+	// comparisons involving NULL are never true
+	if left.Null {
+		return false
+	}
+	if comparison.Between != nil {
+		low := comparison.Between.Low.evaluate(record)
+		high := comparison.Between.High.evaluate(record)
+		if low.Null || high.Null {
+			return false
+		}
+		return left.Compare(low) >= 0 && left.Compare(high) <= 0
+	}
+	right := comparison.Right.evaluate(record)
+	if right.Null {
+		return false
+	}
+	cmp := left.Compare(right)
+	switch comparison.Op {
+	case "=":
+		return cmp == 0
+	case "<>", "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	panic(fmt.Sprintf("unknown comparison operator %s", comparison.Op))
+}
+
+func (term *Term) evaluate(record *Record) *Value {
+	if term.Column != nil {
+		return record.GetField(*term.Column)
+	}
+	value, _ := term.value()
+	return value
+}
+
+// value returns the value of a term which isn't a column.
+func (term *Term) value() (*Value, error) {
+	switch {
+	case term.Placeholder != nil:
+		return nil, &UnboundPlaceholder{Placeholder: *term.Placeholder}
+	case term.Null:
+		return &Value{Null: true}, nil
+	case term.Number != nil:
+		return numberValue(*term.Number)
+	case term.Bool != nil:
+		return &Value{Type: TypeBool, BoolVal: *term.Bool}, nil
+	case term.TypeName != nil:
+		return stringValue(NameToType[*term.TypeName], *term.String)
+	default:
+		return &Value{Type: TypeString, StringVal: *term.String}, nil
+	}
+}
+
+// satisfiedBy returns whether the record satisfies the expression as a
+// CHECK constraint. Unlike in WHERE clauses, comparisons involving NULL
+// are unknown rather than false, as in SQL, and only a false result fails.
+func (expr *Expr) satisfiedBy(record *Record) bool {
+	result, known := expr.evaluateKnown(record)
+	return result || !known
+}
+
+// evaluateKnown returns the expression's result, and whether it's known.
+func (expr *Expr) evaluateKnown(record *Record) (bool, bool) {
+	allKnown := true
+	for _, and := range expr.Or {
+		result, known := and.evaluateKnown(record)
+		if known && result {
+			return true, true
+		}
+		allKnown = allKnown && known
+	}
+	return false, allKnown
+}
+
+func (and *AndExpr) evaluateKnown(record *Record) (bool, bool) {
+	allKnown := true
+	for _, not := range and.And {
+		result, known := not.evaluateKnown(record)
+		if known && !result {
+			return false, true
+		}
+		allKnown = allKnown && known
+	}
+	return true, allKnown
+}
+
+func (not *NotExpr) evaluateKnown(record *Record) (bool, bool) {
+	if not.Predicate.Parens != nil {
+		result, known := not.Predicate.Parens.evaluateKnown(record)
+		return result != not.Not, known
+	}
+	comparison := not.Predicate.Comparison
+	return comparison.evaluate(record) != not.Not, !comparison.involvesNull(record)
+}
+
+// involvesNull returns whether one of the comparison's operands is NULL,
+// unless it's checking for that.
+func (comparison *Comparison) involvesNull(record *Record) bool {
+	if comparison.IsNull != nil {
+		return false
+	}
+	for _, term := range comparison.operands() {
+		if term.evaluate(record).Null {
+			return true
+		}
+	}
+	return false
+}
+
+// helpers
+
+// operands returns the terms a comparison compares.
+func (comparison *Comparison) operands() []*Term {
+	operands := []*Term{comparison.Left}
+	if comparison.Right != nil {
+		operands = append(operands, comparison.Right)
+	}
+	if comparison.Between != nil {
+		operands = append(operands, comparison.Between.Low, comparison.Between.High)
+	}
+	return operands
+}
+
+// terms returns the operands of the expression's comparisons,
+// e.g. to find the columns it uses.
+func (expr *Expr) terms() []*Term {
+	var terms []*Term
+	for _, and := range expr.Or {
+		for _, not := range and.And {
+			if not.Predicate.Parens != nil {
+				terms = append(terms, not.Predicate.Parens.terms()...)
+				continue
+			}
+			terms = append(terms, not.Predicate.Comparison.operands()...)
+		}
+	}
+	return terms
+}
+
+// equalityCondition returns the column and value if this expression is
+// just `column = literal`, so that callers can use a point lookup or a
+// filtered table listener instead of scanning.
+func (expr *Expr) equalityCondition() (string, *Value, bool) {
+	if len(expr.Or) != 1 || len(expr.Or[0].And) != 1 {
+		return "", nil, false
+	}
+	not := expr.Or[0].And[0]
+	comparison := not.Predicate.Comparison
+	if not.Not || comparison == nil || comparison.Op != "=" {
+		return "", nil, false
+	}
+	column, literal := comparison.Left, comparison.Right
+	if column.Column == nil {
+		column, literal = literal, column
+	}
+	if column.Column == nil || literal.Column != nil || literal.Null {
+		return "", nil, false
+	}
+	return *column.Column, literal.evaluate(nil), true
+}
+
+// NewEqualsExpr returns an expression for `columnName = value`.
+func NewEqualsExpr(columnName string, value *Value) *Expr {
+	var literal *Term
+	switch value.Type {
+	case TypeInt:
+		number := strconv.Itoa(value.IntVal)
+		literal = &Term{Number: &number}
+	case TypeFloat:
+		number := formatFloat(value.FloatVal)
+		literal = &Term{Number: &number}
+	case TypeBool:
+		boolVal := value.BoolVal
+		literal = &Term{Bool: &boolVal}
+	case TypeString:
+		str := value.StringVal
+		literal = &Term{String: &str}
+	default:
+		str := value.StringVal
+		switch value.Type {
+		case TypeTimestamp:
+			str = value.TimeVal.Format(time.RFC3339Nano)
+		case TypeBytes:
+			str = base64.StdEncoding.EncodeToString(value.BytesVal)
+		}
+		typeName := TypeToName[value.Type]
+		literal = &Term{String: &str, TypeName: &typeName}
+	}
+	return &Expr{
+		Or: []*AndExpr{{
+			And: []*NotExpr{{
+				Predicate: &Predicate{
+					Comparison: &Comparison{
+						Left:  &Term{Column: &columnName},
+						Op:    "=",
+						Right: literal,
+					},
+				},
+			}},
+		}},
+	}
+}
+
+// And returns an expression which is true when both expressions are.
+// Either may be nil.
+func (expr *Expr) And(other *Expr) *Expr {
+	if expr == nil {
+		return other
+	}
+	if other == nil {
+		return expr
+	}
+	return &Expr{
+		Or: []*AndExpr{{
+			And: []*NotExpr{
+				{Predicate: &Predicate{Parens: expr}},
+				{Predicate: &Predicate{Parens: other}},
+			},
+		}},
+	}
+}
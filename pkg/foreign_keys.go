@@ -0,0 +1,199 @@
+package treesql
+
+import (
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// checkReferences checks that the records a record references exist.
+func checkReferences(tx *bolt.Tx, record *Record) error {
+	for _, column := range record.Table.Columns {
+		if err := checkReference(tx, record, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkReference checks that the record one of a record's columns
+// references exists, if the column is a reference and isn't null.
+func checkReference(tx *bolt.Tx, record *Record, column *ColumnDescriptor) error {
+	if column.ReferencesColumn == nil {
+		return nil
+	}
+	value := record.GetField(column.Name)
+	if value.Null {
+		return nil
+	}
+	referenced := column.ReferencesColumn.TableName
+	bucket := tx.Bucket([]byte(referenced))
+	if bucket == nil || bucket.Get([]byte(value.key())) == nil {
+		return &ForeignKeyViolation{
+			TableName:  record.Table.Name,
+			ColumnName: column.Name,
+			References: referenced,
+			Key:        value.key(),
+		}
+	}
+	return nil
+}
+
+// checkColumnReferences checks that the records all of a table's
+// records reference in the given column exist.
+func checkColumnReferences(tx *bolt.Tx, table *TableDescriptor, column *ColumnDescriptor) error {
+	if column.ReferencesColumn == nil {
+		return nil
+	}
+	return tx.Bucket([]byte(table.Name)).ForEach(func(key []byte, value []byte) error {
+		return checkReference(tx, table.RecordFromBytes(value), column)
+	})
+}
+
+// referencingColumns returns the columns which reference the given
+// table, including its own, e.g. for trees of comments.
+func (db *Database) referencingColumns(table *TableDescriptor) []columnOfTable {
+	references := db.referencesToTable(table.Name)
+	for _, columnName := range table.referencesTo(table.Name) {
+		references = append(references, columnOfTable{table: table, column: table.getColumn(columnName)})
+	}
+	return references
+}
+
+// updateRecord writes a record's new version, moving it if its primary key
+// changed, in which case the actions of the references to it are carried
+// out. It returns the changes made, for live queries.
+func (db *Database) updateRecord(tx *bolt.Tx, oldRecord *Record, newRecord *Record) ([]*TableEvent, error) {
+	table := newRecord.Table
+	bucket := tx.Bucket([]byte(table.Name))
+	oldKey := oldRecord.primaryKey()
+	newKey := newRecord.primaryKey()
+	if newKey != oldKey {
+		if bucket.Get([]byte(newKey)) != nil {
+			return nil, &RecordAlreadyExists{ColName: table.PrimaryKey, Val: newKey}
+		}
+		if err := bucket.Delete([]byte(oldKey)); err != nil {
+			return nil, err
+		}
+	}
+	if err := unindexRecord(tx, oldRecord); err != nil {
+		return nil, err
+	}
+	if err := bucket.Put([]byte(newKey), newRecord.ToBytes()); err != nil {
+		return nil, err
+	}
+	if err := indexRecord(tx, newRecord); err != nil {
+		return nil, err
+	}
+	events := []*TableEvent{{TableName: table.Name, OldRecord: oldRecord, NewRecord: newRecord}}
+	if newKey == oldKey {
+		return events, nil
+	}
+	cascaded, err := db.keyRemoved(tx, table, oldRecord.GetField(table.PrimaryKey), newRecord.GetField(table.PrimaryKey))
+	if err != nil {
+		return nil, err
+	}
+	return append(events, cascaded...), nil
+}
+
+// keyRemoved carries out the actions of the references to a table's record
+// whose primary key was oldKey, and which was deleted (if newKey is nil)
+// or now has newKey. It returns the changes made, for live queries.
+func (db *Database) keyRemoved(tx *bolt.Tx, table *TableDescriptor, oldKey *Value, newKey *Value) ([]*TableEvent, error) {
+	var events []*TableEvent
+	for _, reference := range db.referencingColumns(table) {
+		action := reference.column.ReferencesColumn.OnDelete
+		if newKey != nil {
+			action = reference.column.ReferencesColumn.OnUpdate
+		}
+		if action == "" {
+			// builtin tables' references have no actions
+			continue
+		}
+		bucket := tx.Bucket([]byte(reference.table.Name))
+		for _, key := range referencingKeys(tx, reference, oldKey) {
+			// an earlier action may have changed or deleted it
+			current := bucket.Get(key)
+			if current == nil {
+				continue
+			}
+			record := reference.table.RecordFromBytes(current)
+			if value := record.GetField(reference.column.Name); value.Null || value.key() != oldKey.key() {
+				continue
+			}
+			var changes []*TableEvent
+			var err error
+			switch {
+			case action == Restrict:
+				return nil, &RecordReferenced{
+					TableName:    table.Name,
+					Key:          oldKey.key(),
+					ReferencedBy: fmt.Sprintf("%s.%s", reference.table.Name, reference.column.Name),
+					Deleted:      newKey == nil,
+				}
+			case action == Cascade && newKey == nil:
+				changes, err = db.deleteRecord(tx, record)
+			default:
+				newRecord := record.Clone()
+				if action == Cascade {
+					newRecord.SetValue(reference.column.Name, newKey)
+				} else {
+					if reference.table.notNull(reference.column) {
+						return nil, &NullViolation{TableName: reference.table.Name, ColumnName: reference.column.Name}
+					}
+					newRecord.SetValue(reference.column.Name, &Value{Null: true})
+				}
+				changes, err = db.updateRecord(tx, record, newRecord)
+			}
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, changes...)
+		}
+	}
+	return events, nil
+}
+
+// referencingKeys returns the primary keys of the records whose
+// referencing column has the given value, from its index.
+func referencingKeys(tx *bolt.Tx, reference columnOfTable, value *Value) [][]byte {
+	var keys [][]byte
+	index := tx.Bucket(indexBucketName(reference.column))
+	if index == nil {
+		return nil
+	}
+	valueKeys := index.Bucket(indexKey(reference.column, value))
+	if valueKeys == nil {
+		return nil
+	}
+	valueKeys.ForEach(func(key []byte, _ []byte) error {
+		keys = append(keys, append([]byte{}, key...))
+		return nil
+	})
+	return keys
+}
+
+// deleteRecord deletes a record, and carries out the actions of the
+// references to it. It returns the changes made, for live queries.
+func (db *Database) deleteRecord(tx *bolt.Tx, record *Record) ([]*TableEvent, error) {
+	table := record.Table
+	if err := tx.Bucket([]byte(table.Name)).Delete([]byte(record.primaryKey())); err != nil {
+		return nil, err
+	}
+	if err := unindexRecord(tx, record); err != nil {
+		return nil, err
+	}
+	events := []*TableEvent{{TableName: table.Name, OldRecord: record}}
+	cascaded, err := db.keyRemoved(tx, table, record.GetField(table.PrimaryKey), nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(events, cascaded...), nil
+}
+
+// pushTableEvents pushes the changes a statement made to live queries.
+func (db *Database) pushTableEvents(channel *Channel, events []*TableEvent) {
+	for _, event := range events {
+		db.PushTableEvent(channel, event.TableName, event.OldRecord, event.NewRecord)
+	}
+}
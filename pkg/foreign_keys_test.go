@@ -0,0 +1,206 @@
+package treesql
+
+import (
+	"testing"
+)
+
+func TestForeignKeys(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE users (id string PRIMARY KEY, name string)`,
+			ack:  "CREATE TABLE",
+		},
+		// Verify that references are type checked.
+		{
+			stmt:  `CREATE TABLE blog_posts (id string PRIMARY KEY, author_id int REFERENCES users)`,
+			error: "validation error: column blog_posts.author_id is of type int, so it can't reference table users, whose primary key is of type string",
+		},
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, author_id string REFERENCES users ON DELETE CASCADE, editor_id string REFERENCES users ON DELETE SET NULL ON UPDATE CASCADE, title string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE comments (id string PRIMARY KEY, post_id string NOT NULL REFERENCES blog_posts ON UPDATE CASCADE, body string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO users VALUES ("0", "pete"), ("1", "alice"), ("2", "bob")`,
+			ack:  "INSERT 3",
+		},
+		// Verify that referenced records have to exist.
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("p0", "3", NULL, "hello world")`,
+			error: "executing insert: column blog_posts.author_id references a record in users with primary key 3, but there isn't one",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("p0", "0", "2", "hello world"), ("p1", "1", "0", "hello again world")`,
+			ack:  "INSERT 2",
+		},
+		{
+			stmt: `INSERT INTO comments VALUES ("c0", "p0", "nice")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt:  `UPDATE blog_posts SET editor_id = "3" WHERE id = "p1"`,
+			error: "executing update: column blog_posts.editor_id references a record in users with primary key 3, but there isn't one",
+		},
+		{
+			stmt:  `ALTER TABLE comments ADD COLUMN author_id string DEFAULT "9" REFERENCES users`,
+			error: "altering table: column comments.author_id references a record in users with primary key 9, but there isn't one",
+		},
+		// RESTRICT, the default, stops deletes and primary key changes,
+		// including ones cascaded from other tables.
+		{
+			stmt:  `DELETE FROM users WHERE id = "0"`,
+			error: "executing delete: record in blog_posts with primary key p0 is referenced by comments.post_id, which restricts deleting it",
+		},
+		{
+			stmt:  `UPDATE users SET id = "3" WHERE id = "1"`,
+			error: "executing update: record in users with primary key 1 is referenced by blog_posts.author_id, which restricts changing its primary key",
+		},
+		// CASCADE carries primary key changes and deletes over to the
+		// referencing records; SET NULL clears their references.
+		{
+			stmt: `UPDATE users SET id = "4" WHERE id = "2"`,
+			ack:  "UPDATE 1",
+		},
+		{
+			stmt: `UPDATE blog_posts SET id = "p2" WHERE id = "p0"`,
+			ack:  "UPDATE 1",
+		},
+		{
+			query: `MANY blog_posts { id, editor_id, comments: MANY comments { id } }`,
+			initialResult: `[
+  {
+    "comments": [],
+    "editor_id": "0",
+    "id": "p1"
+  },
+  {
+    "comments": [
+      {
+        "id": "c0"
+      }
+    ],
+    "editor_id": "4",
+    "id": "p2"
+  }
+]`,
+		},
+		{
+			stmt: `DELETE FROM users WHERE id = "4" OR id = "1"`,
+			ack:  "DELETE 2",
+		},
+		{
+			query: `MANY blog_posts { id, author_id, editor_id }`,
+			initialResult: `[
+  {
+    "author_id": "0",
+    "editor_id": null,
+    "id": "p2"
+  }
+]`,
+		},
+		{
+			stmt:  `DELETE FROM blog_posts WHERE id = "p2"`,
+			error: "executing delete: record in blog_posts with primary key p2 is referenced by comments.post_id, which restricts deleting it",
+		},
+		// ...but records can be deleted along with the ones referencing them.
+		{
+			stmt: `CREATE TABLE replies (id string PRIMARY KEY, parent_id string REFERENCES replies)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO replies VALUES ("1", "0"), ("0", NULL)`,
+			ack:  "INSERT 2",
+		},
+		{
+			stmt: `DELETE FROM replies WHERE id <> ""`,
+			ack:  "DELETE 2",
+		},
+		{
+			query: `MANY __columns__ WHERE on_delete IS NOT NULL ORDER BY table_name { table_name, name, on_delete, on_update }`,
+			initialResult: `[
+  {
+    "name": "author_id",
+    "on_delete": "CASCADE",
+    "on_update": "RESTRICT",
+    "table_name": "blog_posts"
+  },
+  {
+    "name": "editor_id",
+    "on_delete": "SET NULL",
+    "on_update": "CASCADE",
+    "table_name": "blog_posts"
+  },
+  {
+    "name": "post_id",
+    "on_delete": "RESTRICT",
+    "on_update": "CASCADE",
+    "table_name": "comments"
+  },
+  {
+    "name": "parent_id",
+    "on_delete": "RESTRICT",
+    "on_update": "RESTRICT",
+    "table_name": "replies"
+  }
+]`,
+		},
+	})
+}
+
+func TestLiveForeignKeyActions(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE users (id string PRIMARY KEY, name string)`,
+		`CREATE TABLE blog_posts (id string PRIMARY KEY, author_id string REFERENCES users ON DELETE CASCADE, editor_id string REFERENCES users ON DELETE SET NULL)`,
+		`INSERT INTO users VALUES ("0", "pete"), ("1", "alice")`,
+		`INSERT INTO blog_posts VALUES ("0", "0", "1"), ("1", "1", NULL)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`LIVE MANY blog_posts { id, editor_id }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	// Deleting alice deletes her post and clears her as pete's editor,
+	// in either order.
+	if _, err := client.Exec(`DELETE FROM users WHERE id = "1"`); err != nil {
+		t.Fatal(err)
+	}
+	var recordUpdate *RecordUpdate
+	var recordDelete *RecordDelete
+	for i := 0; i < 2; i++ {
+		msg := <-updates
+		switch msg.Type {
+		case RecordUpdateMessage:
+			recordUpdate = msg.RecordUpdateMessage
+		case RecordDeleteMessage:
+			recordDelete = msg.RecordDeleteMessage
+		default:
+			t.Fatalf("expected record update or delete; got %v", msg.Type)
+		}
+	}
+	if recordUpdate == nil {
+		t.Fatal("expected record update for post 0")
+	}
+	if editorID := recordUpdate.Fields["editor_id"]; editorID != nil {
+		t.Fatalf("expected null editor_id; got %v", editorID)
+	}
+	if recordDelete == nil {
+		t.Fatal("expected record delete for post 1")
+	}
+}
@@ -3,6 +3,7 @@ package treesql
 import (
 	"bytes"
 	"fmt"
+	"strings"
 )
 
 type NodeFormatter interface {
@@ -13,6 +14,9 @@ func (n *Statement) Format() string {
 	if n.CreateTable != nil {
 		return n.CreateTable.Format()
 	}
+	if n.CreateIndex != nil {
+		return n.CreateIndex.Format()
+	}
 	if n.Insert != nil {
 		return n.Insert.Format()
 	}
@@ -22,80 +26,403 @@ func (n *Statement) Format() string {
 	if n.Update != nil {
 		return n.Update.Format()
 	}
+	if n.Delete != nil {
+		return n.Delete.Format()
+	}
+	if n.DropTable != nil {
+		return n.DropTable.Format()
+	}
+	if n.AlterTable != nil {
+		return n.AlterTable.Format()
+	}
 	panic(fmt.Sprintf("unknown %v", n))
 }
 
 func (n *CreateTable) Format() string {
-	buf := bytes.NewBufferString("CREATETABLE ")
+	buf := bytes.NewBufferString("CREATE TABLE ")
 	buf.WriteString(n.Name)
 	buf.WriteString(" (")
 	for idx, col := range n.Columns {
 		if idx > 0 {
 			buf.WriteString(", ")
 		}
-		buf.WriteString(col.Name)
-		buf.WriteString(" ")
-		buf.WriteString(col.TypeName)
-		if col.PrimaryKey {
-			buf.WriteString(" PRIMARYKEY")
-		}
-		if col.References != nil {
-			buf.WriteString(" REFERENCESTABLE ")
-			buf.WriteString(*col.References)
-		}
+		buf.WriteString(col.Format())
+	}
+	for _, constraint := range n.Constraints {
+		buf.WriteString(", ")
+		buf.WriteString(constraint.Format())
 	}
 	buf.WriteString(")")
 	return buf.String()
 }
 
+func (n *TableConstraint) Format() string {
+	if n.Check != nil {
+		return fmt.Sprintf("CHECK (%s)", n.Check.Format())
+	}
+	return fmt.Sprintf("UNIQUE (%s)", strings.Join(n.Unique, ", "))
+}
+
+func (n *CreateTableColumn) Format() string {
+	buf := bytes.NewBufferString(n.Name)
+	buf.WriteString(" ")
+	buf.WriteString(n.TypeName)
+	if n.PrimaryKey {
+		buf.WriteString(" PRIMARY KEY")
+	}
+	if n.NotNull {
+		buf.WriteString(" NOT NULL")
+	}
+	if n.Default != nil {
+		buf.WriteString(" DEFAULT ")
+		buf.WriteString(n.Default.Format())
+	}
+	if n.Unique {
+		buf.WriteString(" UNIQUE")
+	}
+	if n.Check != nil {
+		buf.WriteString(" CHECK (")
+		buf.WriteString(n.Check.Format())
+		buf.WriteString(")")
+	}
+	if n.References != nil {
+		buf.WriteString(" REFERENCES ")
+		buf.WriteString(*n.References)
+		buf.WriteString(formatReferenceActions(n.OnDelete, n.OnUpdate))
+	}
+	return buf.String()
+}
+
+func formatReferenceActions(onDelete ReferenceAction, onUpdate ReferenceAction) string {
+	formatted := ""
+	if onDelete != "" {
+		formatted += " ON DELETE " + string(onDelete)
+	}
+	if onUpdate != "" {
+		formatted += " ON UPDATE " + string(onUpdate)
+	}
+	return formatted
+}
+
+func (n *CreateIndex) Format() string {
+	return fmt.Sprintf("CREATE INDEX ON %s (%s)", n.Table, n.ColumnName)
+}
+
+func (n *DropTable) Format() string {
+	if n.Cascade {
+		return fmt.Sprintf("DROP TABLE %s CASCADE", n.Name)
+	}
+	return fmt.Sprintf("DROP TABLE %s", n.Name)
+}
+
+func (n *AlterTable) Format() string {
+	prefix := fmt.Sprintf("ALTER TABLE %s ", n.Name)
+	switch {
+	case n.AddColumn != nil:
+		return prefix + "ADD COLUMN " + n.AddColumn.Format()
+	case n.DropColumn != nil:
+		return prefix + "DROP COLUMN " + n.DropColumn.Name
+	case n.RenameColumn != nil:
+		return prefix + fmt.Sprintf("RENAME COLUMN %s TO %s", n.RenameColumn.Name, n.RenameColumn.NewName)
+	case n.AddReference != nil:
+		reference := n.AddReference
+		return prefix + fmt.Sprintf("ADD REFERENCE %s REFERENCES %s", reference.Name, reference.References) +
+			formatReferenceActions(reference.OnDelete, reference.OnUpdate)
+	case n.DropReference != nil:
+		return prefix + "DROP REFERENCE " + n.DropReference.Name
+	}
+	panic(fmt.Sprintf("unknown %v", n))
+}
+
 func (n *Select) Format() string {
 	buf := bytes.NewBufferString("")
+	if n.Live {
+		buf.WriteString("LIVE ")
+	}
 	if n.Many {
 		buf.WriteString("MANY ")
 	} else {
 		buf.WriteString("ONE ")
 	}
 	buf.WriteString(n.Table)
+	if n.Via != nil {
+		buf.WriteString(" VIA ")
+		buf.WriteString(*n.Via)
+	}
+	if n.Recursive {
+		buf.WriteString(" RECURSIVE")
+		if n.MaxDepth != nil {
+			buf.WriteString(fmt.Sprintf(" MAXDEPTH %d", *n.MaxDepth))
+		}
+	}
 	if n.Where != nil {
 		buf.WriteString(" WHERE ")
-		buf.WriteString(n.Where.ColumnName)
-		buf.WriteString(" = ")
-		buf.WriteString(fmt.Sprintf(`%#v`, n.Where.Value))
+		buf.WriteString(n.Where.Format())
+	}
+	if n.OrderBy != nil {
+		buf.WriteString(" ORDER BY ")
+		buf.WriteString(n.OrderBy.ColumnName)
+		if n.OrderBy.Desc {
+			buf.WriteString(" DESC")
+		}
+	}
+	if n.Limit != nil {
+		buf.WriteString(fmt.Sprintf(" LIMIT %d", *n.Limit))
+	}
+	if n.Offset != nil {
+		buf.WriteString(fmt.Sprintf(" OFFSET %d", *n.Offset))
 	}
-	buf.WriteString(" { ")
-	for idx, selection := range n.Selections {
+	buf.WriteString(" ")
+	buf.WriteString(formatSelections(n.Selections))
+	return buf.String()
+}
+
+func formatSelections(selections []*Selection) string {
+	buf := bytes.NewBufferString("{ ")
+	for idx, selection := range selections {
 		if idx > 0 {
 			buf.WriteString(", ")
 		}
+		if selection.Star {
+			buf.WriteString("*")
+		}
 		buf.WriteString(selection.Name)
+		if selection.Aggregate != nil {
+			buf.WriteString(": ")
+			buf.WriteString(selection.Aggregate.Format())
+		}
 		if selection.SubSelect != nil {
 			buf.WriteString(": ")
 			sel := selection.SubSelect.Format()
 			buf.WriteString(sel)
 		}
+		if selection.Expr != nil {
+			buf.WriteString(": ")
+			buf.WriteString(selection.Expr.Format())
+		}
 	}
 	buf.WriteString(" }")
 	return buf.String()
 }
 
+func (n *Aggregate) Format() string {
+	buf := bytes.NewBufferString(n.Function)
+	buf.WriteString(" ")
+	buf.WriteString(n.Table)
+	if n.ColumnName != nil {
+		buf.WriteString(".")
+		buf.WriteString(*n.ColumnName)
+	}
+	if n.Via != nil {
+		buf.WriteString(" VIA ")
+		buf.WriteString(*n.Via)
+	}
+	if n.Where != nil {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(n.Where.Format())
+	}
+	return buf.String()
+}
+
+func (n *Expr) Format() string {
+	buf := bytes.NewBufferString("")
+	for idx, and := range n.Or {
+		if idx > 0 {
+			buf.WriteString(" OR ")
+		}
+		buf.WriteString(and.Format())
+	}
+	return buf.String()
+}
+
+func (n *AndExpr) Format() string {
+	buf := bytes.NewBufferString("")
+	for idx, not := range n.And {
+		if idx > 0 {
+			buf.WriteString(" AND ")
+		}
+		if not.Not {
+			buf.WriteString("NOT ")
+		}
+		if not.Predicate.Parens != nil {
+			buf.WriteString("(")
+			buf.WriteString(not.Predicate.Parens.Format())
+			buf.WriteString(")")
+		} else {
+			buf.WriteString(not.Predicate.Comparison.Format())
+		}
+	}
+	return buf.String()
+}
+
+func (n *Comparison) Format() string {
+	switch {
+	case n.IsNull != nil && n.IsNull.Not:
+		return fmt.Sprintf("%s IS NOT NULL", n.Left.Format())
+	case n.IsNull != nil:
+		return fmt.Sprintf("%s IS NULL", n.Left.Format())
+	case n.Between != nil:
+		return fmt.Sprintf(
+			"%s BETWEEN %s AND %s",
+			n.Left.Format(), n.Between.Low.Format(), n.Between.High.Format(),
+		)
+	}
+	return fmt.Sprintf("%s %s %s", n.Left.Format(), n.Op, n.Right.Format())
+}
+
+func (n *Term) Format() string {
+	switch {
+	case n.Null:
+		return "NULL"
+	case n.Number != nil:
+		return *n.Number
+	case n.Bool != nil:
+		return formatBool(*n.Bool)
+	case n.String != nil:
+		return formatString(n.TypeName, *n.String)
+	case n.Placeholder != nil:
+		return *n.Placeholder
+	default:
+		return *n.Column
+	}
+}
+
+func (n *Literal) Format() string {
+	if n.Placeholder != nil {
+		return *n.Placeholder
+	}
+	if n.Number != nil {
+		return *n.Number
+	}
+	if n.Null {
+		return "NULL"
+	}
+	if n.Bool != nil {
+		return formatBool(*n.Bool)
+	}
+	return formatString(n.TypeName, *n.String)
+}
+
+func formatBool(boolVal bool) string {
+	if boolVal {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// formatString quotes a string literal, prefixed with its type if it has one.
+// Strings can't contain escapes, so ones with double quotes are single-quoted.
+func formatString(typeName *string, str string) string {
+	quoted := fmt.Sprintf(`"%s"`, str)
+	if strings.Contains(str, `"`) {
+		quoted = fmt.Sprintf("'%s'", str)
+	}
+	if typeName != nil {
+		return strings.ToUpper(*typeName) + " " + quoted
+	}
+	return quoted
+}
+
 func (n *Update) Format() string {
-	return fmt.Sprintf(
-		"UPDATE %s SET %s = %#v WHERE %s = %#v",
-		n.Table, n.ColumnName, n.Value, n.WhereColumnName, n.EqualsValue,
-	)
+	buf := bytes.NewBufferString("UPDATE ")
+	buf.WriteString(n.Table)
+	buf.WriteString(" SET ")
+	buf.WriteString(formatAssignments(n.Assignments))
+	buf.WriteString(" WHERE ")
+	buf.WriteString(n.Where.Format())
+	buf.WriteString(n.Returning.formatReturning())
+	return buf.String()
+}
+
+func formatAssignments(assignments []*Assignment) string {
+	formatted := make([]string, len(assignments))
+	for idx, assignment := range assignments {
+		formatted[idx] = fmt.Sprintf("%s = %s", assignment.ColumnName, assignment.Value.Format())
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func (n *ValueExpr) Format() string {
+	buf := bytes.NewBufferString(n.Left.Format())
+	for _, op := range n.Rest {
+		buf.WriteString(fmt.Sprintf(" %s %s", op.Op, op.Right.Format()))
+	}
+	return buf.String()
+}
+
+func (n *Sum) Format() string {
+	buf := bytes.NewBufferString(n.Left.Format())
+	for _, op := range n.Rest {
+		buf.WriteString(fmt.Sprintf(" %s %s", op.Op, op.Right.Format()))
+	}
+	return buf.String()
+}
+
+func (n *Product) Format() string {
+	buf := bytes.NewBufferString(n.Left.Format())
+	for _, op := range n.Rest {
+		buf.WriteString(fmt.Sprintf(" %s %s", op.Op, op.Right.Format()))
+	}
+	return buf.String()
+}
+
+func (n *Factor) Format() string {
+	if n.Parens != nil {
+		return fmt.Sprintf("(%s)", n.Parens.Format())
+	}
+	if n.Call == nil {
+		return n.Term.Format()
+	}
+	args := make([]string, len(n.Call.Args))
+	for idx, arg := range n.Call.Args {
+		args[idx] = arg.Format()
+	}
+	return fmt.Sprintf("%s(%s)", n.Term.Format(), strings.Join(args, ", "))
+}
+
+func (n *Delete) Format() string {
+	return fmt.Sprintf("DELETE FROM %s WHERE %s%s", n.Table, n.Where.Format(), n.Returning.formatReturning())
+}
+
+// formatReturning formats a RETURNING clause, or nothing if there isn't one.
+func (n *Select) formatReturning() string {
+	if n == nil {
+		return ""
+	}
+	return " RETURNING " + formatSelections(n.Selections)
 }
 
 func (n *Insert) Format() string {
 	buf := bytes.NewBufferString("INSERT INTO ")
 	buf.WriteString(n.Table)
+	if len(n.Columns) > 0 {
+		buf.WriteString(" (")
+		buf.WriteString(strings.Join(n.Columns, ", "))
+		buf.WriteString(")")
+	}
 	buf.WriteString(" VALUES ")
-	buf.WriteString("(")
-	for idx, value := range n.Values {
-		if idx > 0 {
+	for rowIdx, row := range n.Rows {
+		if rowIdx > 0 {
 			buf.WriteString(", ")
 		}
-		buf.WriteString(fmt.Sprintf("%#v", value))
+		buf.WriteString("(")
+		for idx, value := range row.Values {
+			if idx > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(value.Format())
+		}
+		buf.WriteString(")")
 	}
-	buf.WriteString(")")
+	if n.OnConflict != nil {
+		buf.WriteString(" ON CONFLICT DO ")
+		if n.OnConflict.DoNothing {
+			buf.WriteString("NOTHING")
+		} else {
+			buf.WriteString("UPDATE SET ")
+			buf.WriteString(formatAssignments(n.OnConflict.Assignments))
+		}
+	}
+	buf.WriteString(n.Returning.formatReturning())
 	return buf.String()
 }
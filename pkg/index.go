@@ -0,0 +1,452 @@
+package treesql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	clog "github.com/vilterp/treesql/pkg/log"
+)
+
+func (db *Database) validateCreateIndex(create *CreateIndex) error {
+	// table exists, and isn't a builtin
+	table, ok := db.Schema.Tables[create.Table]
+	if !ok {
+		return errorAt(create.Pos, create.Table, &NoSuchTable{TableName: create.Table})
+	}
+	if isBuiltinTable(create.Table) {
+		return errorAt(create.Pos, create.Table, &BuiltinWriteAttempt{TableName: create.Table})
+	}
+	// column exists, and isn't indexed yet
+	column := table.getColumn(create.ColumnName)
+	if column == nil {
+		return errorAt(create.Pos, create.ColumnName, &NoSuchColumn{TableName: create.Table, ColumnName: create.ColumnName})
+	}
+	if column.Indexed {
+		return errorAt(create.Pos, create.ColumnName, &IndexAlreadyExists{TableName: create.Table, ColumnName: create.ColumnName})
+	}
+	return nil
+}
+
+func (conn *Connection) ExecuteCreateIndex(create *CreateIndex, channel *Channel) error {
+	db := conn.Database
+	table := db.Schema.Tables[create.Table]
+	column := table.getColumn(create.ColumnName)
+
+	indexed := *column
+	indexed.Indexed = true
+	oldColumnRecord := column.ToRecord(table.Name, db)
+	newColumnRecord := indexed.ToRecord(table.Name, db)
+	updateErr := db.BoltDB.Update(func(tx *bolt.Tx) error {
+		key := []byte(fmt.Sprintf("%d", column.ID))
+		if err := tx.Bucket([]byte("__columns__")).Put(key, newColumnRecord.ToBytes()); err != nil {
+			return err
+		}
+		if err := buildIndex(tx, table, &indexed); err != nil {
+			return err
+		}
+		// update in-memory schema before committing, so that
+		// writes after this one keep the index up to date
+		// TODO: synchronize access to this mutable shared data structure!!
+		column.Indexed = true
+		return nil
+	})
+	if updateErr != nil {
+		return errors.Wrap(updateErr, "creating index")
+	}
+	db.PushTableEvent(channel, "__columns__", oldColumnRecord, newColumnRecord)
+	db.PushTableEvent(channel, "__indexes__", nil, indexed.IndexToRecord(table.Name, db))
+
+	clog.Println(channel, "created index on", fmt.Sprintf("%s.%s", table.Name, column.Name))
+	channel.WriteAckMessage("CREATE INDEX")
+	return nil
+}
+
+// addMissingIndexes indexes the columns which reference tables or lead
+// UNIQUE constraints but aren't indexed, having been created before those
+// were indexed, so that joins along them and checks of them don't scan.
+func (db *Database) addMissingIndexes() error {
+	return db.BoltDB.Update(func(tx *bolt.Tx) error {
+		columnsBucket := tx.Bucket([]byte("__columns__"))
+		for _, table := range db.Schema.Tables {
+			for _, column := range table.Columns {
+				// builtin tables' references have no actions, and they
+				// aren't stored in buckets
+				referencing := column.ReferencesColumn != nil && column.ReferencesColumn.OnDelete != ""
+				if column.Indexed || !(referencing || leadsUnique(table.Constraints, column.Name)) {
+					continue
+				}
+				if err := buildIndex(tx, table, column); err != nil {
+					return err
+				}
+				column.Indexed = true
+				key := []byte(fmt.Sprintf("%d", column.ID))
+				if err := columnsBucket.Put(key, column.ToRecord(table.Name, db).ToBytes()); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// An index is a Bolt bucket holding a bucket for each value of the
+// column, named with indexKey(value), which holds the primary keys of
+// the records with that value. Nulls aren't indexed, since no comparison
+// with one is true.
+
+// indexBucketName is the name of the bucket holding a column's index. It's
+// named by column ID, so that it survives the column being renamed.
+func indexBucketName(column *ColumnDescriptor) []byte {
+	return []byte(fmt.Sprintf("__index_%d__", column.ID))
+}
+
+// indexKey encodes a value of an indexed column, such that values' encodings
+// are in the same order as the values. They start with a 'v', since bucket
+// names can't be empty.
+func indexKey(column *ColumnDescriptor, value *Value) []byte {
+	key := []byte{'v'}
+	switch column.Type {
+	case TypeInt:
+		return append(key, orderedInt(int64(value.IntVal))...)
+	case TypeFloat:
+		return append(key, orderedFloat(value.float())...)
+	case TypeBool:
+		if value.BoolVal {
+			return append(key, 1)
+		}
+		return append(key, 0)
+	case TypeTimestamp:
+		nanos := make([]byte, 4)
+		binary.BigEndian.PutUint32(nanos, uint32(value.TimeVal.Nanosecond()))
+		return append(append(key, orderedInt(value.TimeVal.Unix())...), nanos...)
+	case TypeBytes:
+		return append(key, value.BytesVal...)
+	}
+	return append(key, value.StringVal...)
+}
+
+// orderedInt encodes an int as big-endian bytes, with the sign bit
+// flipped so that negative numbers come first.
+func orderedInt(i int64) []byte {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, uint64(i)^(1<<63))
+	return encoded
+}
+
+// orderedFloat encodes a float's bits such that they're in the same order
+// as the floats: positive numbers get their sign bit set, and negative
+// numbers have all their bits flipped, so that bigger ones come first.
+func orderedFloat(f float64) []byte {
+	if f == 0 {
+		f = 0 // -0 is equal to 0
+	}
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, bits)
+	return encoded
+}
+
+// buildIndex creates a column's index, from the table's existing records.
+func buildIndex(tx *bolt.Tx, table *TableDescriptor, column *ColumnDescriptor) error {
+	if _, err := tx.CreateBucket(indexBucketName(column)); err != nil {
+		return err
+	}
+	// Bolt doesn't allow writes while iterating
+	var records []*Record
+	if err := tx.Bucket([]byte(table.Name)).ForEach(func(_ []byte, value []byte) error {
+		records = append(records, table.RecordFromBytes(value))
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := addToIndex(tx, column, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropIndex removes an indexed column's index, when the column is dropped.
+func dropIndex(tx *bolt.Tx, column *ColumnDescriptor) error {
+	if !column.Indexed {
+		return nil
+	}
+	return tx.DeleteBucket(indexBucketName(column))
+}
+
+// indexRecord adds a record which was written to the indexes on its
+// table's columns.
+func indexRecord(tx *bolt.Tx, record *Record) error {
+	for _, column := range record.Table.Columns {
+		if !column.Indexed {
+			continue
+		}
+		if err := addToIndex(tx, column, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexRecord removes a record which was deleted, or is being
+// overwritten, from the indexes on its table's columns.
+func unindexRecord(tx *bolt.Tx, record *Record) error {
+	for _, column := range record.Table.Columns {
+		if !column.Indexed {
+			continue
+		}
+		value := record.GetField(column.Name)
+		if value.Null {
+			continue
+		}
+		index := tx.Bucket(indexBucketName(column))
+		valueKey := indexKey(column, value)
+		keys := index.Bucket(valueKey)
+		if keys == nil {
+			continue
+		}
+		if err := keys.Delete([]byte(record.primaryKey())); err != nil {
+			return err
+		}
+		// don't leave values no records have behind
+		if first, _ := keys.Cursor().First(); first == nil {
+			if err := index.DeleteBucket(valueKey); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func addToIndex(tx *bolt.Tx, column *ColumnDescriptor, record *Record) error {
+	value := record.GetField(column.Name)
+	if value.Null {
+		return nil
+	}
+	keys, err := tx.Bucket(indexBucketName(column)).CreateBucketIfNotExists(indexKey(column, value))
+	if err != nil {
+		return err
+	}
+	return keys.Put([]byte(record.primaryKey()), []byte{})
+}
+
+// indexScan is a range of an indexed column's values, the records with
+// which are looked up in its index.
+type indexScan struct {
+	column *ColumnDescriptor
+	low    *Value // inclusive; nil if there's no lower bound
+	high   *Value // inclusive; nil if there's no upper bound
+}
+
+// indexScan returns a range of values of one of the table's indexed columns
+// that records satisfying the expression have to be in, if it has one; it
+// may include records which don't satisfy it, so they still have to be
+// checked. It's an equality if there is one, e.g. for a join.
+func (expr *Expr) indexScan(table *TableDescriptor) *indexScan {
+	if expr == nil || len(expr.Or) != 1 {
+		return nil
+	}
+	// narrow down the range of each column compared to a value
+	var scans []*indexScan
+	for _, not := range expr.Or[0].And {
+		if not.Not {
+			continue
+		}
+		var scan *indexScan
+		if not.Predicate.Parens != nil {
+			scan = not.Predicate.Parens.indexScan(table)
+		} else {
+			scan = not.Predicate.Comparison.indexScan(table)
+		}
+		if scan == nil {
+			continue
+		}
+		narrowed := false
+		for _, other := range scans {
+			if other.column == scan.column {
+				other.narrow(scan)
+				narrowed = true
+			}
+		}
+		if !narrowed {
+			scans = append(scans, scan)
+		}
+	}
+	// use the narrowest range
+	var best *indexScan
+	for _, scan := range scans {
+		if best == nil || scan.bounds() > best.bounds() {
+			best = scan
+		}
+	}
+	return best
+}
+
+// bounds ranks a range by how narrow it probably is: 3 for a single value,
+// 2 for a range between two values, and 1 for a range with one end open.
+func (scan *indexScan) bounds() int {
+	switch {
+	case scan.low != nil && scan.high != nil && scan.low.Compare(scan.high) == 0:
+		return 3
+	case scan.low != nil && scan.high != nil:
+		return 2
+	}
+	return 1
+}
+
+// narrow narrows the range down to the values in both it and another
+// one of the same column.
+func (scan *indexScan) narrow(other *indexScan) {
+	if scan.low == nil || (other.low != nil && other.low.Compare(scan.low) > 0) {
+		scan.low = other.low
+	}
+	if scan.high == nil || (other.high != nil && other.high.Compare(scan.high) < 0) {
+		scan.high = other.high
+	}
+}
+
+// flippedOps are the operators comparing the other way around, for
+// comparisons with the column on the right.
+var flippedOps = map[string]string{
+	"=":  "=",
+	"<":  ">",
+	"<=": ">=",
+	">":  "<",
+	">=": "<=",
+}
+
+// indexScan returns the range of an indexed column's values the comparison
+// is true for, if it compares one to values.
+func (comparison *Comparison) indexScan(table *TableDescriptor) *indexScan {
+	if comparison.IsNull != nil {
+		return nil
+	}
+	columnTerm, other := comparison.Left, comparison.Right
+	op := comparison.Op
+	if comparison.Between == nil && columnTerm.Column == nil {
+		columnTerm, other = other, columnTerm
+		op = flippedOps[op]
+	}
+	if columnTerm.Column == nil {
+		return nil
+	}
+	column := table.getColumn(*columnTerm.Column)
+	if column == nil || !column.Indexed {
+		return nil
+	}
+	// values which aren't of the column's type aren't in the same order
+	bound := func(term *Term) *Value {
+		if term.Column != nil {
+			return nil
+		}
+		value := term.evaluate(nil)
+		if value.Null || !assignable(value.Type, column.Type) {
+			return nil
+		}
+		return value
+	}
+	scan := &indexScan{column: column}
+	if comparison.Between != nil {
+		scan.low = bound(comparison.Between.Low)
+		scan.high = bound(comparison.Between.High)
+		if scan.low == nil && scan.high == nil {
+			return nil
+		}
+		return scan
+	}
+	value := bound(other)
+	if value == nil {
+		return nil
+	}
+	switch op {
+	case "=":
+		scan.low, scan.high = value, value
+	case "<", "<=":
+		scan.high = value
+	case ">", ">=":
+		scan.low = value
+	default:
+		return nil
+	}
+	return scan
+}
+
+// index iterator
+
+// IndexIterator iterates over the records with values in a range of an
+// indexed column, in the order of the values.
+type IndexIterator struct {
+	table   *TableDescriptor
+	records *bolt.Bucket
+	index   *bolt.Bucket
+	values  *bolt.Cursor // over the index's buckets of primary keys, one per value
+	keys    *bolt.Cursor // over the primary keys of the records with the current value
+	low     []byte
+	high    []byte
+	started bool
+}
+
+func newIndexIterator(ex *SelectExecution, table *TableDescriptor, scan *indexScan) (*IndexIterator, error) {
+	index := ex.Transaction.Bucket(indexBucketName(scan.column))
+	iterator := &IndexIterator{
+		table:   table,
+		records: ex.Transaction.Bucket([]byte(table.Name)),
+		index:   index,
+		values:  index.Cursor(),
+	}
+	if scan.low != nil {
+		iterator.low = indexKey(scan.column, scan.low)
+	}
+	if scan.high != nil {
+		iterator.high = indexKey(scan.column, scan.high)
+	}
+	return iterator, nil
+}
+
+func (it *IndexIterator) Next() *Record {
+	for {
+		if it.keys != nil {
+			if key, _ := it.keys.Next(); key != nil {
+				return it.table.RecordFromBytes(it.records.Get(key))
+			}
+		}
+		// on to the next value, if it's in range
+		var value []byte
+		switch {
+		case it.started:
+			value, _ = it.values.Next()
+		case it.low != nil:
+			value, _ = it.values.Seek(it.low)
+		default:
+			value, _ = it.values.First()
+		}
+		it.started = true
+		if value == nil || (it.high != nil && bytes.Compare(value, it.high) > 0) {
+			return nil
+		}
+		it.keys = it.index.Bucket(value).Cursor()
+		if key, _ := it.keys.First(); key != nil {
+			return it.table.RecordFromBytes(it.records.Get(key))
+		}
+	}
+}
+
+func (it *IndexIterator) Get(key string) (*Record, error) {
+	rawRecord := it.records.Get([]byte(key))
+	if rawRecord == nil {
+		return nil, nil
+	}
+	return it.table.RecordFromBytes(rawRecord), nil
+}
+
+func (it *IndexIterator) Close() {}
@@ -0,0 +1,313 @@
+package treesql
+
+import (
+	"testing"
+)
+
+func TestIndexes(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE comments (id string PRIMARY KEY, blog_post_id string REFERENCES blog_posts ON DELETE CASCADE, score int, rating float, body string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "hello world"), ("1", "hello again world")`,
+			ack:  "INSERT 2",
+		},
+		{
+			stmt: `INSERT INTO comments VALUES ("0", "0", 5, 1.5, "first"), ("1", "0", -3, -2.5, "second"), ("2", "1", 0, NULL, "third"), ("3", NULL, 12, 0, "fourth")`,
+			ack:  "INSERT 4",
+		},
+		// Verify that indexes are checked.
+		{
+			stmt:  `CREATE INDEX ON replies (blog_post_id)`,
+			error: "validation error: no such table: replies",
+		},
+		{
+			stmt:  `CREATE INDEX ON comments (post_id)`,
+			error: "validation error: no such column in table comments: post_id",
+		},
+		{
+			stmt:  `CREATE INDEX ON __columns__ (table_name)`,
+			error: "validation error: attemtped to write to __columns__, but builtin tables are read-only",
+		},
+		// References are indexed automatically.
+		{
+			stmt:  `CREATE INDEX ON comments (blog_post_id)`,
+			error: "validation error: column comments.blog_post_id is already indexed",
+		},
+		// Happy path: indexes are built from existing records...
+		{
+			stmt: `CREATE INDEX ON comments (score)`,
+			ack:  "CREATE INDEX",
+		},
+		{
+			stmt: `CREATE INDEX ON comments (rating)`,
+			ack:  "CREATE INDEX",
+		},
+		{
+			query: `MANY blog_posts { id, comments: MANY comments { id }, comment_count: COUNT comments }`,
+			initialResult: `[
+  {
+    "comment_count": 2,
+    "comments": [
+      {
+        "id": "0"
+      },
+      {
+        "id": "1"
+      }
+    ],
+    "id": "0"
+  },
+  {
+    "comment_count": 1,
+    "comments": [
+      {
+        "id": "2"
+      }
+    ],
+    "id": "1"
+  }
+]`,
+		},
+		// ...and records are scanned in their order, for ranges.
+		{
+			query: `MANY comments WHERE score >= -3 AND 5 >= score { id, score }`,
+			initialResult: `[
+  {
+    "id": "1",
+    "score": -3
+  },
+  {
+    "id": "2",
+    "score": 0
+  },
+  {
+    "id": "0",
+    "score": 5
+  }
+]`,
+		},
+		{
+			query: `MANY comments WHERE rating BETWEEN -3 AND 1 AND body <> "fourth" { id, rating }`,
+			initialResult: `[
+  {
+    "id": "1",
+    "rating": -2.5
+  }
+]`,
+		},
+		{
+			query: `MANY comments WHERE blog_post_id = "0" AND score < 5 { id }`,
+			initialResult: `[
+  {
+    "id": "1"
+  }
+]`,
+		},
+		// Writes keep indexes up to date.
+		{
+			stmt: `UPDATE comments SET score = score + 10, blog_post_id = "1" WHERE id = "1"`,
+			ack:  "UPDATE 1",
+		},
+		{
+			stmt: `UPDATE comments SET id = "4" WHERE id = "0"`,
+			ack:  "UPDATE 1",
+		},
+		{
+			stmt: `INSERT INTO comments VALUES ("5", "1", 6, 2, "fifth")`,
+			ack:  "INSERT 1",
+		},
+		{
+			query: `MANY comments WHERE score > 0 { id, score }`,
+			initialResult: `[
+  {
+    "id": "4",
+    "score": 5
+  },
+  {
+    "id": "5",
+    "score": 6
+  },
+  {
+    "id": "1",
+    "score": 7
+  },
+  {
+    "id": "3",
+    "score": 12
+  }
+]`,
+		},
+		{
+			stmt: `DELETE FROM blog_posts WHERE id = "1"`,
+			ack:  "DELETE 1",
+		},
+		{
+			query: `MANY blog_posts { id, comments: MANY comments { id } }`,
+			initialResult: `[
+  {
+    "comments": [
+      {
+        "id": "4"
+      }
+    ],
+    "id": "0"
+  }
+]`,
+		},
+		// Indexes follow their columns when they're renamed, and go
+		// away with them.
+		{
+			stmt: `ALTER TABLE comments RENAME COLUMN score TO points`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			query: `MANY comments WHERE points < 100 { id, points }`,
+			initialResult: `[
+  {
+    "id": "4",
+    "points": 5
+  },
+  {
+    "id": "3",
+    "points": 12
+  }
+]`,
+		},
+		{
+			stmt: `ALTER TABLE comments DROP COLUMN rating`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			query: `MANY __columns__ WHERE table_name = "comments" AND indexed = TRUE { name }`,
+			initialResult: `[
+  {
+    "name": "blog_post_id"
+  },
+  {
+    "name": "points"
+  }
+]`,
+		},
+	})
+}
+
+func TestReferenceIndexes(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE users (id string PRIMARY KEY, name string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE posts (id string PRIMARY KEY, author_id string REFERENCES users, reviewer_id string, title string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO users VALUES ("0", "pete"), ("1", "alice")`,
+			ack:  "INSERT 2",
+		},
+		{
+			stmt: `INSERT INTO posts VALUES ("0", "0", "1", "hello world"), ("1", "1", "1", "hello again world"), ("2", "1", NULL, "goodbye world")`,
+			ack:  "INSERT 3",
+		},
+		// References get indexes whether they're on created columns, added
+		// columns or existing columns, which are indexed from their records.
+		{
+			stmt: `ALTER TABLE posts ADD COLUMN editor_id string REFERENCES users`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			stmt: `ALTER TABLE posts ADD REFERENCE reviewer_id REFERENCES users`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			stmt: `CREATE INDEX ON posts (title)`,
+			ack:  "CREATE INDEX",
+		},
+		{
+			query: `MANY __indexes__ WHERE table_name = "posts" { table_name, column_name }`,
+			initialResult: `[
+  {
+    "column_name": "author_id",
+    "table_name": "posts"
+  },
+  {
+    "column_name": "reviewer_id",
+    "table_name": "posts"
+  },
+  {
+    "column_name": "title",
+    "table_name": "posts"
+  },
+  {
+    "column_name": "editor_id",
+    "table_name": "posts"
+  }
+]`,
+		},
+		{
+			query: `MANY users { id, reviewed: MANY posts VIA reviewer_id { id }, written: COUNT posts VIA author_id }`,
+			initialResult: `[
+  {
+    "id": "0",
+    "reviewed": [],
+    "written": 1
+  },
+  {
+    "id": "1",
+    "reviewed": [
+      {
+        "id": "0"
+      },
+      {
+        "id": "1"
+      }
+    ],
+    "written": 2
+  }
+]`,
+		},
+		// Indexes are listed under their columns' current names, until
+		// they're dropped.
+		{
+			stmt: `ALTER TABLE posts RENAME COLUMN reviewer_id TO reviewed_by`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			stmt: `ALTER TABLE posts DROP COLUMN editor_id`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			query: `MANY __indexes__ WHERE table_name = "posts" { column_name }`,
+			initialResult: `[
+  {
+    "column_name": "author_id"
+  },
+  {
+    "column_name": "reviewed_by"
+  },
+  {
+    "column_name": "title"
+  }
+]`,
+		},
+		{
+			stmt:  `DELETE FROM __indexes__ WHERE table_name = "posts"`,
+			error: "validation error: attemtped to write to __indexes__, but builtin tables are read-only",
+		},
+		{
+			stmt: `DROP TABLE posts`,
+			ack:  "DROP TABLE",
+		},
+		{
+			query:         `MANY __indexes__ { column_name }`,
+			initialResult: `[]`,
+		},
+	})
+}
@@ -1,6 +1,8 @@
 package treesql
 
 import (
+	"bytes"
+	"fmt"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -11,48 +13,288 @@ func (db *Database) validateInsert(insert *Insert) error {
 	// does table exist
 	tableSpec, ok := db.Schema.Tables[insert.Table]
 	if !ok {
-		return &NoSuchTable{TableName: insert.Table}
+		return errorAt(insert.Pos, insert.Table, &NoSuchTable{TableName: insert.Table})
 	}
 	// can't insert into builtins
-	if insert.Table == "__tables__" || insert.Table == "__columns__" {
-		return &BuiltinWriteAttempt{TableName: insert.Table}
+	if isBuiltinTable(insert.Table) {
+		return errorAt(insert.Pos, insert.Table, &BuiltinWriteAttempt{TableName: insert.Table})
 	}
-	// right # fields (TODO: validate types)
-	wanted := len(tableSpec.Columns)
-	got := len(insert.Values)
-	if wanted != got {
-		return &InsertWrongNumFields{TableName: insert.Table, Wanted: wanted, Got: got}
+	if err := validateInsertColumns(insert, tableSpec); err != nil {
+		return err
+	}
+	if err := validateInsertTypes(insert, tableSpec); err != nil {
+		return err
+	}
+	// updates of existing records are valid
+	if insert.OnConflict != nil && !insert.OnConflict.DoNothing {
+		err := validateAssignments(insert.OnConflict.Assignments, tableSpec, tableSpec.withExcluded())
+		if err != nil {
+			return err
+		}
+	}
+	// returning selection is valid
+	return db.validateReturning(insert.Returning)
+}
+
+// validateInsertColumns checks that each row has a value for each
+// column, or for each listed one if the others can be left out.
+func validateInsertColumns(insert *Insert, tableSpec *TableDescriptor) error {
+	if len(insert.Columns) == 0 {
+		// right # fields
+		wanted := len(tableSpec.Columns)
+		for _, row := range insert.Rows {
+			got := len(row.Values)
+			if wanted != got {
+				return errorAt(row.Pos, "", &InsertWrongNumFields{TableName: insert.Table, Wanted: wanted, Got: got})
+			}
+		}
+		return nil
+	}
+	// listed columns exist, and aren't repeated
+	listed := map[string]bool{}
+	for _, columnName := range insert.Columns {
+		if tableSpec.getColumn(columnName) == nil {
+			return errorAt(insert.Pos, columnName, &NoSuchColumn{TableName: insert.Table, ColumnName: columnName})
+		}
+		if listed[columnName] {
+			return errorAt(insert.Pos, columnName, &DuplicateColumn{ColumnName: columnName})
+		}
+		listed[columnName] = true
+	}
+	// primary key and NOT NULL columns are given, or generated
+	if primaryKey := tableSpec.getColumn(tableSpec.PrimaryKey); !listed[primaryKey.Name] && !primaryKey.generated() {
+		return errorAt(insert.Pos, insert.Table, &InsertMissingPrimaryKey{TableName: insert.Table, ColumnName: tableSpec.PrimaryKey})
+	}
+	for _, column := range tableSpec.Columns {
+		if column.NotNull && !listed[column.Name] && !column.generated() {
+			return errorAt(insert.Pos, insert.Table, &NullViolation{TableName: insert.Table, ColumnName: column.Name})
+		}
+	}
+	// each row has a value for each listed column
+	for idx, row := range insert.Rows {
+		if len(row.Values) != len(insert.Columns) {
+			return errorAt(row.Pos, "", &InsertWrongNumValues{
+				Row:    idx + 1,
+				Wanted: len(insert.Columns),
+				Got:    len(row.Values),
+			})
+		}
 	}
 	return nil
 }
 
+// validateInsertTypes checks that each value fits in its column.
+// NULL fits in any column which isn't NOT NULL, and plain strings are
+// read as e.g. timestamps when they're for a timestamp column.
+func validateInsertTypes(insert *Insert, table *TableDescriptor) error {
+	columnNames := insert.columnNames(table)
+	for _, row := range insert.Rows {
+		for idx, literal := range row.Values {
+			column := table.getColumn(columnNames[idx])
+			if literal.Null {
+				if table.notNull(column) {
+					return errorAt(literal.Pos, "", &NullViolation{TableName: table.Name, ColumnName: column.Name})
+				}
+				continue
+			}
+			if literal.String != nil && literal.TypeName == nil && writtenAsString(column.Type) {
+				typeName := TypeToName[column.Type]
+				literal.TypeName = &typeName
+			}
+			value, err := literal.value()
+			if err != nil {
+				return errorAt(literal.Pos, "", err)
+			}
+			if !assignable(value.Type, column.Type) {
+				return errorAt(literal.Pos, "", &AssignmentTypeMismatch{
+					ColumnName: column.Name,
+					ColumnType: column.Type,
+					ValueType:  value.Type,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// columnNames returns the columns the insert's values are for:
+// those listed, or all of the table's, in order.
+func (insert *Insert) columnNames(table *TableDescriptor) []string {
+	if len(insert.Columns) > 0 {
+		return insert.Columns
+	}
+	columnNames := make([]string, len(table.Columns))
+	for idx, column := range table.Columns {
+		columnNames[idx] = column.Name
+	}
+	return columnNames
+}
+
+// excludedPrefix names the columns of the row being inserted in
+// ON CONFLICT DO UPDATE assignments, e.g. `excluded.title`.
+const excludedPrefix = "excluded."
+
+// withExcluded describes the records ON CONFLICT DO UPDATE assignments
+// are computed from: the existing record's columns, followed by those of
+// the row being inserted, prefixed with `excluded.`.
+func (table *TableDescriptor) withExcluded() *TableDescriptor {
+	columns := make([]*ColumnDescriptor, 0, 2*len(table.Columns))
+	columns = append(columns, table.Columns...)
+	for _, column := range table.Columns {
+		excluded := *column
+		excluded.Name = excludedPrefix + column.Name
+		columns = append(columns, &excluded)
+	}
+	return &TableDescriptor{
+		Name:       table.Name,
+		Columns:    columns,
+		PrimaryKey: table.PrimaryKey,
+	}
+}
+
+// withExcluded returns the record for computing the existing record's
+// update from, given the row being inserted.
+func (record *Record) withExcluded(scope *TableDescriptor, excluded *Record) *Record {
+	values := make([]Value, 0, len(record.Values)+len(excluded.Values))
+	values = append(values, record.Values...)
+	values = append(values, excluded.Values...)
+	return &Record{Table: scope, Values: values}
+}
+
+// value returns the literal's value. It should have been bound.
+func (literal *Literal) value() (*Value, error) {
+	switch {
+	case literal.Placeholder != nil:
+		return nil, &UnboundPlaceholder{Placeholder: *literal.Placeholder}
+	case literal.Null:
+		return &Value{Null: true}, nil
+	case literal.Number != nil:
+		return numberValue(*literal.Number)
+	case literal.Bool != nil:
+		return &Value{Type: TypeBool, BoolVal: *literal.Bool}, nil
+	case literal.TypeName != nil:
+		return stringValue(NameToType[*literal.TypeName], *literal.String)
+	default:
+		return &Value{Type: TypeString, StringVal: *literal.String}, nil
+	}
+}
+
 func (conn *Connection) ExecuteInsert(insert *Insert, channel *Channel) error {
 	startTime := time.Now()
 	table := conn.Database.Schema.Tables[insert.Table]
 
-	// Create record.
-	record := table.NewRecord()
-	for idx, value := range insert.Values {
-		record.SetString(table.Columns[idx].Name, value)
+	columnNames := insert.columnNames(table)
+	listed := map[string]bool{}
+	for _, columnName := range columnNames {
+		listed[columnName] = true
 	}
-	key := record.GetField(table.PrimaryKey).StringVal
 
-	// Write to table.
+	// Create records. Columns which weren't listed get their defaults
+	// when the records are written, or are left null.
+	records := make([]*Record, len(insert.Rows))
+	for rowIdx, row := range insert.Rows {
+		record := table.NewRecord()
+		for idx, literal := range row.Values {
+			value, _ := literal.value() // checked during validation
+			record.SetValue(columnNames[idx], value)
+		}
+		records[rowIdx] = record
+	}
+
+	// Write to table, all rows or none. Rows whose primary keys are taken
+	// are skipped, or update the existing records, if the insert says so.
+	var inserted, updated, written []*Record
+	var events []*TableEvent
+	var conflictScope *TableDescriptor
+	if insert.OnConflict != nil {
+		conflictScope = table.withExcluded()
+	}
 	err := conn.Database.BoltDB.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(insert.Table))
-		if current := bucket.Get([]byte(key)); current != nil {
-			return &RecordAlreadyExists{ColName: table.PrimaryKey, Val: key}
+		for _, record := range records {
+			for _, column := range table.Columns {
+				if listed[column.Name] || !column.generated() {
+					continue
+				}
+				value, err := generateValue(tx, table, column)
+				if err != nil {
+					return err
+				}
+				record.SetValue(column.Name, value)
+			}
+			key := record.primaryKey()
+			current := bucket.Get([]byte(key))
+			if current == nil {
+				if err := bucket.Put([]byte(key), record.ToBytes()); err != nil {
+					return err
+				}
+				if err := indexRecord(tx, record); err != nil {
+					return err
+				}
+				inserted = append(inserted, record)
+				written = append(written, record)
+				events = append(events, &TableEvent{TableName: table.Name, NewRecord: record})
+				continue
+			}
+			if insert.OnConflict == nil {
+				return &RecordAlreadyExists{ColName: table.PrimaryKey, Val: key}
+			}
+			if insert.OnConflict.DoNothing {
+				continue
+			}
+			oldRecord := table.RecordFromBytes(current)
+			newRecord, err := assign(insert.OnConflict.Assignments, oldRecord, oldRecord.withExcluded(conflictScope, record))
+			if err != nil {
+				return err
+			}
+			updated = append(updated, newRecord)
+			written = append(written, newRecord)
+			if bytes.Equal(newRecord.ToBytes(), current) {
+				continue
+			}
+			changes, err := conn.Database.updateRecord(tx, oldRecord, newRecord)
+			if err != nil {
+				return err
+			}
+			events = append(events, changes...)
 		}
-		return bucket.Put([]byte(key), record.ToBytes())
+		// Rows can reference rows later in the statement, or conflict
+		// with them, so check references and constraints once they're
+		// all written.
+		for _, record := range written {
+			if err := checkReferences(tx, record); err != nil {
+				return err
+			}
+		}
+		return checkConstraints(tx, events)
 	})
 	if err != nil {
 		return errors.Wrap(err, "executing insert")
 	}
 
-	// Push to live query listeners.
-	conn.Database.PushTableEvent(channel, insert.Table, nil, record)
-	// Return ack.
-	channel.WriteAckMessage("INSERT 1")
+	// Push to live query listeners, in the order the changes were made,
+	// since rows can update records inserted earlier in the statement.
+	conn.Database.pushTableEvents(channel, events)
+	// Return the written records if they were asked for, or else
+	// an ack, with the keys if they were generated.
+	if insert.Returning != nil {
+		if err := conn.executeReturning(insert.Returning, written, channel); err != nil {
+			return errors.Wrap(err, "returning inserted records")
+		}
+	} else {
+		var insertedKeys []interface{}
+		if !listed[table.PrimaryKey] {
+			insertedKeys = make([]interface{}, len(inserted))
+			for idx, record := range inserted {
+				insertedKeys[idx] = record.GetField(table.PrimaryKey).jsonValue()
+			}
+		}
+		ack := fmt.Sprintf("INSERT %d", len(inserted))
+		if insert.OnConflict != nil {
+			ack += fmt.Sprintf(" UPDATE %d", len(updated))
+		}
+		channel.WriteInsertAckMessage(ack, insertedKeys)
+	}
 
 	// Record latency.
 	endTime := time.Now()
@@ -5,7 +5,7 @@ import "testing"
 func TestInsert(t *testing.T) {
 	runSimpleTestScript(t, []simpleTestStmt{
 		{
-			stmt: "CREATETABLE blog_posts (id string PRIMARYKEY, body string)",
+			stmt: "CREATE TABLE blog_posts (id string PRIMARY KEY, body string)",
 			ack:  "CREATE TABLE",
 		},
 		{
@@ -28,3 +28,198 @@ func TestInsert(t *testing.T) {
 		},
 	})
 }
+
+func TestInsertMultipleRows(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: "CREATE TABLE blog_posts (id string PRIMARY KEY, title string, body string)",
+			ack:  "CREATE TABLE",
+		},
+		// Verify that column lists are checked.
+		{
+			stmt:  `INSERT INTO blog_posts (id, author) VALUES ("0", "pete")`,
+			error: "validation error: no such column in table blog_posts: author",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts (id, title, id) VALUES ("0", "hello", "1")`,
+			error: "validation error: column listed more than once: id",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts (title) VALUES ("hello")`,
+			error: "validation error: insert into blog_posts must provide primary key column id",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts (id, title) VALUES ("0", "hello"), ("1")`,
+			error: "validation error: insert statement lists 2 columns, but row 2 provides 1 values",
+		},
+		// Verify that nothing is written if any row fails.
+		{
+			stmt:  `INSERT INTO blog_posts (id, title) VALUES ("0", "hello"), ("0", "hello again")`,
+			error: "executing insert: record already exists with primary key id=0",
+		},
+		// Happy path.
+		{
+			stmt: `INSERT INTO blog_posts (title, id) VALUES ("hello", "0"), ("hello again", "1")`,
+			ack:  "INSERT 2",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("2", "hello once more", "bla bla"), ("3", "goodbye", "bla")`,
+			ack:  "INSERT 2",
+		},
+		{
+			query: `MANY blog_posts { id, title, body }`,
+			initialResult: `[
+  {
+    "body": null,
+    "id": "0",
+    "title": "hello"
+  },
+  {
+    "body": null,
+    "id": "1",
+    "title": "hello again"
+  },
+  {
+    "body": "bla bla",
+    "id": "2",
+    "title": "hello once more"
+  },
+  {
+    "body": "bla",
+    "id": "3",
+    "title": "goodbye"
+  }
+]`,
+		},
+	})
+}
+
+func TestInsertOnConflict(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: "CREATE TABLE blog_posts (id string PRIMARY KEY, title string NOT NULL, views int)",
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "hello world", 1)`,
+			ack:  "INSERT 1",
+		},
+		// Verify that updates are checked.
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("0", "hello world", 1) ON CONFLICT DO UPDATE SET body = excluded.title`,
+			error: "validation error: no such column in table blog_posts: body",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("0", "hello world", 1) ON CONFLICT DO UPDATE SET views = excluded.title`,
+			error: "validation error: can't assign string value to int column views",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("0", "hello world", 1) ON CONFLICT DO UPDATE SET title = excluded.body`,
+			error: "validation error: no such column in table blog_posts: excluded.body",
+		},
+		// Happy path: taken primary keys skip rows, or update the existing
+		// records from them.
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "goodbye world", 5), ("1", "hello again world", 2) ON CONFLICT DO NOTHING`,
+			ack:  "INSERT 1 UPDATE 0",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("1", "hello again world!", 3), ("2", "goodbye world", NULL) ON CONFLICT DO UPDATE SET title = excluded.title, views = views + excluded.views`,
+			ack:  "INSERT 1 UPDATE 1",
+		},
+		{
+			query: `INSERT INTO blog_posts (id, title) VALUES ("0", "hello world?") ON CONFLICT DO UPDATE SET title = excluded.title RETURNING { id, title, views }`,
+			initialResult: `[
+  {
+    "id": "0",
+    "title": "hello world?",
+    "views": 1
+  }
+]`,
+		},
+		{
+			query: `MANY blog_posts { * }`,
+			initialResult: `[
+  {
+    "id": "0",
+    "title": "hello world?",
+    "views": 1
+  },
+  {
+    "id": "1",
+    "title": "hello again world!",
+    "views": 5
+  },
+  {
+    "id": "2",
+    "title": "goodbye world",
+    "views": null
+  }
+]`,
+		},
+		// Without ON CONFLICT, a taken primary key is still an error.
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("2", "goodbye world", NULL)`,
+			error: "executing insert: record already exists with primary key id=2",
+		},
+	})
+}
+
+func TestLiveInsertOnConflict(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+		`INSERT INTO blog_posts VALUES ("0", "hello world")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`LIVE MANY blog_posts { id, title }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	if _, err := client.Exec(
+		`INSERT INTO blog_posts VALUES ("0", "hello world!"), ("1", "hello again world") ON CONFLICT DO UPDATE SET title = excluded.title`,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new record is pushed to the table listener, and the updated one
+	// to its record listener, with old and new records, in either order.
+	var tableUpdate *TableUpdate
+	var recordUpdate *RecordUpdate
+	for i := 0; i < 2; i++ {
+		msg := <-updates
+		switch msg.Type {
+		case TableUpdateMessage:
+			tableUpdate = msg.TableUpdateMessage
+		case RecordUpdateMessage:
+			recordUpdate = msg.RecordUpdateMessage
+		default:
+			t.Fatalf("expected table or record update; got %v", msg.Type)
+		}
+	}
+	if tableUpdate == nil || len(tableUpdate.Selection) != 1 || tableUpdate.Selection[0]["id"] != "1" {
+		t.Fatalf("expected table update for post 1; got %v", tableUpdate)
+	}
+	if recordUpdate == nil {
+		t.Fatal("expected record update for post 0")
+	}
+	if event := recordUpdate.TableEvent; event.OldRecord == nil || event.NewRecord == nil {
+		t.Fatalf("expected old and new records; got %v", event)
+	}
+	if title := recordUpdate.Fields["title"]; title != "hello world!" {
+		t.Fatalf("expected title hello world!; got %v", title)
+	}
+}
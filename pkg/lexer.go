@@ -0,0 +1,135 @@
+package treesql
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenType int
+
+const (
+	wordToken tokenType = iota // identifiers and keywords, which the parser tells apart by context
+	numberToken
+	stringToken
+	placeholderToken
+	operatorToken
+	invalidToken
+	eofToken
+)
+
+type token struct {
+	typ tokenType
+	pos Position
+	// text is the token as written; for strings, it's without the quotes.
+	text string
+	raw  string
+}
+
+// Position is a place in a statement's text. Lines and columns start at 1.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (pos Position) advance(text string) Position {
+	pos.Offset += len(text)
+	if lines := strings.Count(text, "\n"); lines > 0 {
+		pos.Line += lines
+		pos.Column = 1 + utf8.RuneCountInString(text[strings.LastIndex(text, "\n")+1:])
+		return pos
+	}
+	pos.Column += utf8.RuneCountInString(text)
+	return pos
+}
+
+// operators, longest first so e.g. `<=` isn't lexed as `<` and `=`
+var operators = []string{
+	"||", "<>", "!=", "<=", ">=",
+	"-", "+", "*", "/", "%", ",", ".", "(", ")", "{", "}", "=", "<", ">", ":",
+}
+
+// lex splits a statement into tokens, ending with an eofToken.
+// Characters which don't start a token become invalidTokens, which
+// the parser reports.
+func lex(statement string) []token {
+	var tokens []token
+	pos := Position{Line: 1, Column: 1}
+	rest := statement
+	for {
+		trimmed := strings.TrimLeft(rest, " \t\r\n")
+		pos = pos.advance(rest[:len(rest)-len(trimmed)])
+		rest = trimmed
+		if len(rest) == 0 {
+			return append(tokens, token{typ: eofToken, pos: pos})
+		}
+		tok := nextToken(rest)
+		tok.pos = pos
+		tokens = append(tokens, tok)
+		pos = pos.advance(tok.raw)
+		rest = rest[len(tok.raw):]
+	}
+}
+
+func nextToken(input string) token {
+	first := input[0]
+	switch {
+	case isWordStart(first):
+		end := 1
+		for end < len(input) && (isWordStart(input[end]) || isDigit(input[end])) {
+			end++
+		}
+		return token{typ: wordToken, text: input[:end], raw: input[:end]}
+
+	case isDigit(first):
+		end := scanDigits(input, 0)
+		if end+1 < len(input) && input[end] == '.' && isDigit(input[end+1]) {
+			end = scanDigits(input, end+1)
+		}
+		if end < len(input) && (input[end] == 'e' || input[end] == 'E') {
+			exponent := end + 1
+			if exponent < len(input) && (input[exponent] == '-' || input[exponent] == '+') {
+				exponent++
+			}
+			if exponent < len(input) && isDigit(input[exponent]) {
+				end = scanDigits(input, exponent)
+			}
+		}
+		return token{typ: numberToken, text: input[:end], raw: input[:end]}
+
+	case first == '"' || first == '\'':
+		end := strings.IndexByte(input[1:], first)
+		if end == -1 {
+			return token{typ: invalidToken, text: "unterminated string", raw: input}
+		}
+		return token{typ: stringToken, text: input[1 : end+1], raw: input[:end+2]}
+
+	case first == '$' && len(input) > 1 && input[1] >= '1' && input[1] <= '9':
+		end := scanDigits(input, 1)
+		return token{typ: placeholderToken, text: input[:end], raw: input[:end]}
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(input, op) {
+			return token{typ: operatorToken, text: op, raw: op}
+		}
+	}
+	r, size := utf8.DecodeRuneInString(input)
+	return token{typ: invalidToken, text: fmt.Sprintf("invalid character %q", r), raw: input[:size]}
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func scanDigits(input string, start int) int {
+	end := start
+	for end < len(input) && isDigit(input[end]) {
+		end++
+	}
+	return end
+}
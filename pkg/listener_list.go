@@ -1,6 +1,7 @@
 package treesql
 
 import (
+	"fmt"
 	"log"
 )
 
@@ -15,6 +16,26 @@ type Listener struct {
 	// vv nil for record listeners
 	Query     *Select
 	QueryPath *QueryPath
+	// vv only for windowed table listeners (with ORDER BY, LIMIT or OFFSET)
+	Filter    *Expr // condition the listener was registered on, if any
+	window    *liveWindow
+	aggregate *liveAggregate
+	// vv only for record listeners: the selections to send with record updates
+	selections []*Selection
+}
+
+// recomputes returns whether this listener recomputes its part of the
+// result on every write to its table, rather than only hearing about inserts.
+func (listener *Listener) recomputes() bool {
+	return listener.window != nil || listener.aggregate != nil
+}
+
+func (listener *Listener) recompute() {
+	if listener.window != nil {
+		listener.refreshWindow()
+	} else {
+		listener.aggregate.refresh(listener)
+	}
 }
 
 func (table *TableDescriptor) NewListenerList() *ListenerList {
@@ -52,22 +73,70 @@ func (list *ListenerList) removeListenersForConn(id ConnectionID) {
 	list.numListeners -= count
 }
 
+func (list *ListenerList) removeListenersForChannel(channel *Channel) {
+	listenersForConn := list.Listeners[channel.Connection.ID]
+	channelID := ChannelID(channel.ID)
+	list.numListeners -= len(listenersForConn[channelID])
+	delete(listenersForConn, channelID)
+}
+
 func (list *ListenerList) NumListeners() int {
 	return list.numListeners
 }
 
-func (list *ListenerList) AddQueryListener(ex *SelectExecution, query *Select, queryPath *QueryPath) {
-	list.addListener(&Listener{
+func (list *ListenerList) AddQueryListener(
+	ex *SelectExecution, query *Select, queryPath *QueryPath, filter *Expr, window []string,
+) {
+	listener := &Listener{
 		QueryExecution: ex,
 		Query:          query,
 		QueryPath:      queryPath,
+		Filter:         filter,
+	}
+	if query.windowed() {
+		listener.window = &liveWindow{keys: window}
+	}
+	list.addListener(listener)
+}
+
+func (list *ListenerList) AddAggregateListener(
+	ex *SelectExecution, aggregate *Aggregate, value interface{}, queryPath *QueryPath, filter *Expr,
+) {
+	list.addListener(&Listener{
+		QueryExecution: ex,
+		Query:          aggregate.asSelect(),
+		QueryPath:      queryPath,
+		Filter:         filter,
+		aggregate: &liveAggregate{
+			aggregate: aggregate,
+			value:     value,
+		},
 	})
 }
 
-func (list *ListenerList) AddRecordListener(ex *SelectExecution, queryPath *QueryPath) {
+// removeListener removes the listeners on the given channel and query path.
+func (list *ListenerList) removeListener(channel *Channel, queryPath *QueryPath) {
+	connID := channel.Connection.ID
+	channelID := ChannelID(channel.ID)
+	listenersForChannel := list.Listeners[connID][channelID]
+	remaining := make([]*Listener, 0, len(listenersForChannel))
+	for _, listener := range listenersForChannel {
+		if listener.QueryPath.String() != queryPath.String() {
+			remaining = append(remaining, listener)
+		}
+	}
+	if len(remaining) == len(listenersForChannel) {
+		return
+	}
+	list.numListeners -= len(listenersForChannel) - len(remaining)
+	list.Listeners[connID][channelID] = remaining
+}
+
+func (list *ListenerList) AddRecordListener(ex *SelectExecution, queryPath *QueryPath, selections []*Selection) {
 	list.addListener(&Listener{
 		QueryExecution: ex,
 		QueryPath:      queryPath,
+		selections:     selections,
 	})
 }
 
@@ -75,7 +144,10 @@ func (list *ListenerList) SendEvent(event *TableEvent) {
 	for _, listenersForConn := range list.Listeners {
 		for _, listenersForChannel := range listenersForConn {
 			for _, listener := range listenersForChannel {
-				if listener.Query != nil {
+				if listener.recomputes() {
+					// e.g. the new record may have moved others out of a window
+					go listener.recompute()
+				} else if listener.Query != nil {
 					// whole table or filtered table update
 					conn := listener.QueryExecution.Channel.Connection
 					// want to just be like "clone this, with this different..."
@@ -86,26 +158,81 @@ func (list *ListenerList) SendEvent(event *TableEvent) {
 						One:        listener.Query.One, // ugh
 						Selections: listener.Query.Selections,
 						Table:      listener.Query.Table,
-						Where: &Where{
-							ColumnName: list.Table.PrimaryKey,
-							Value:      event.NewRecord.GetField(list.Table.PrimaryKey).StringVal,
-						}, // TODO: doesn't work if there was already a query... need AND support
+						Recursive:  listener.Query.Recursive,
+						MaxDepth:   listener.Query.MaxDepth,
+						Where: NewEqualsExpr(
+							event.NewRecord.Table.PrimaryKey, event.NewRecord.GetField(event.NewRecord.Table.PrimaryKey),
+						).And(listener.Query.Where),
 					}
 					go func() {
 						result, selectErr := conn.ExecuteQueryForTableListener(
-							newQuery, int(listener.QueryExecution.ID), listener.QueryExecution.Channel,
+							newQuery, int(listener.QueryExecution.ID), listener.QueryExecution.Channel, listener.QueryPath,
 						)
 						if selectErr != nil {
 							log.Println("failed to execute query for table listener statement id", listener.QueryExecution.ID)
 						}
+						// The new record didn't match the listener's where clause.
+						if len(result) == 0 {
+							return
+						}
 						listener.QueryExecution.Channel.WriteTableUpdate(&TableUpdate{
 							QueryPath: listener.QueryPath.Flatten(),
 							Selection: result,
 						})
 					}()
 				} else {
-					// record update
-					listener.QueryExecution.Channel.WriteRecordUpdate(event, listener.QueryPath)
+					// record update, with the selected fields recomputed
+					fields, err := recordFields(listener.selections, event.NewRecord)
+					if err != nil {
+						log.Println("failed to compute fields for statement id", listener.QueryExecution.ID, ":", err)
+						continue
+					}
+					listener.QueryExecution.Channel.WriteRecordUpdate(event, listener.QueryPath, fields)
+				}
+			}
+		}
+	}
+}
+
+// SendDeleteEvent tells each listener's channel that the deleted record
+// is gone. Table listeners' query paths point at the selection, so the
+// record's primary key is appended to them. `sent` is keyed by channel and
+// query path, so that a channel reached via several lists is only told once.
+func (list *ListenerList) SendDeleteEvent(event *TableEvent, sent map[string]bool) {
+	primaryKeyValue := event.OldRecord.primaryKey()
+	for connID, listenersForConn := range list.Listeners {
+		for channelID, listenersForChannel := range listenersForConn {
+			for _, listener := range listenersForChannel {
+				// windowed and aggregate listeners find out about deletes by recomputing
+				if listener.recomputes() {
+					continue
+				}
+				queryPath := listener.QueryPath
+				if listener.Query != nil {
+					queryPath = &QueryPath{
+						ID:              &primaryKeyValue,
+						PreviousSegment: listener.QueryPath,
+					}
+				}
+				key := fmt.Sprintf("%d/%d/%s", connID, channelID, queryPath)
+				if sent[key] {
+					continue
+				}
+				sent[key] = true
+				listener.QueryExecution.Channel.WriteRecordDelete(event, queryPath)
+			}
+		}
+	}
+}
+
+// Recompute recomputes windowed and aggregate table listeners after a
+// record was updated or deleted. Other table listeners only care about inserts.
+func (list *ListenerList) Recompute() {
+	for _, listenersForConn := range list.Listeners {
+		for _, listenersForChannel := range listenersForConn {
+			for _, listener := range listenersForChannel {
+				if listener.recomputes() {
+					go listener.recompute()
 				}
 			}
 		}
@@ -13,6 +13,8 @@ type LiveQueryInfo struct {
 	TableEvents              chan *TableEvent
 	RecordSubscriptionEvents chan *RecordSubscriptionEvent
 	TableSubscriptionEvents  chan *TableSubscriptionEvent
+	// closed when the table is dropped, to stop HandleEvents
+	done chan struct{}
 	// subscribers
 
 	mu struct {
@@ -29,6 +31,7 @@ func (table *TableDescriptor) NewLiveQueryInfo() *LiveQueryInfo {
 		TableEvents:              make(chan *TableEvent),
 		TableSubscriptionEvents:  make(chan *TableSubscriptionEvent),
 		RecordSubscriptionEvents: make(chan *RecordSubscriptionEvent),
+		done:                     make(chan struct{}),
 	}
 	lqi.mu.TableListeners = make(map[ColumnName]map[string]*ListenerList)
 	lqi.mu.WholeTableListeners = table.NewListenerList()
@@ -51,6 +54,11 @@ type TableSubscriptionEvent struct {
 	// vv this and value null => subscribe to whole table w/ no filter
 	ColumnName *string
 	Value      *Value
+	// vv primary keys of the records in the window, for windowed selections
+	Window []string
+	// vv for aggregate selections, along with the value the client has
+	Aggregate      *Aggregate
+	AggregateValue interface{}
 
 	channel *Channel
 }
@@ -59,6 +67,7 @@ type RecordSubscriptionEvent struct {
 	QueryExecution *SelectExecution
 	Value          *Value
 	QueryPath      *QueryPath
+	Selections     []*Selection
 
 	channel *Channel
 }
@@ -68,16 +77,63 @@ func (table *TableDescriptor) removeListenersForConn(id ConnectionID) {
 	liveInfo.mu.Lock()
 	defer liveInfo.mu.Unlock()
 
-	liveInfo.mu.WholeTableListeners.removeListenersForConn(id)
+	// TODO: this is O(num vals being listened on)
+	// Index it by conn.
+	for _, list := range liveInfo.listenerLists() {
+		list.removeListenersForConn(id)
+	}
+}
+
+func (table *TableDescriptor) removeListenersForChannel(channel *Channel) {
+	liveInfo := table.LiveQueryInfo
+	liveInfo.mu.Lock()
+	defer liveInfo.mu.Unlock()
+
+	for _, list := range liveInfo.listenerLists() {
+		list.removeListenersForChannel(channel)
+	}
+}
+
+// listenerLists returns all of the table's listener lists.
+// Must be called with liveInfo.mu held.
+func (liveInfo *LiveQueryInfo) listenerLists() []*ListenerList {
+	lists := []*ListenerList{liveInfo.mu.WholeTableListeners}
 	for _, listenersForCol := range liveInfo.mu.TableListeners {
 		for _, listenersForVal := range listenersForCol {
-			listenersForVal.removeListenersForConn(id)
+			lists = append(lists, listenersForVal)
 		}
 	}
-	// TODO: this is O(num vals being listened on)
-	// Index it by conn.
 	for _, list := range liveInfo.mu.RecordListeners {
-		list.removeListenersForConn(id)
+		lists = append(lists, list)
+	}
+	return lists
+}
+
+// closeLiveQueries stops the table's HandleEvents goroutine, and ends each
+// live query listening to the table by sending it err. The table should
+// already be gone from the schema.
+func (db *Database) closeLiveQueries(table *TableDescriptor, err error) {
+	liveInfo := table.LiveQueryInfo
+	liveInfo.mu.Lock()
+	channels := map[*Channel]bool{}
+	for _, list := range liveInfo.listenerLists() {
+		for _, listenersForConn := range list.Listeners {
+			for _, listenersForChannel := range listenersForConn {
+				for _, listener := range listenersForChannel {
+					channels[listener.QueryExecution.Channel] = true
+				}
+			}
+		}
+	}
+	liveInfo.mu.Unlock()
+	close(liveInfo.done)
+
+	for channel := range channels {
+		channel.WriteErrorMessage(err)
+		for _, otherTable := range db.Schema.Tables {
+			otherTable.removeListenersForChannel(channel)
+		}
+		channel.Connection.removeChannel(channel)
 	}
 }
 
@@ -99,6 +155,9 @@ func (table *TableDescriptor) HandleEvents() {
 
 		case tableEvent := <-liveInfo.TableEvents:
 			table.handleTableEvent(tableEvent)
+
+		case <-liveInfo.done:
+			return
 		}
 	}
 }
@@ -108,44 +167,61 @@ func (table *TableDescriptor) handleTableSub(evt *TableSubscriptionEvent) {
 	liveInfo.mu.Lock()
 	defer liveInfo.mu.Unlock()
 
-	if evt.ColumnName == nil {
+	if evt.Aggregate != nil {
+		// aggregates are always joined to the record above
+		listenersForValue := table.filteredListeners(*evt.ColumnName, evt.Value)
+		listenersForValue.AddAggregateListener(
+			evt.QueryExecution, evt.Aggregate, evt.AggregateValue, evt.QueryPath,
+			NewEqualsExpr(*evt.ColumnName, evt.Value),
+		)
+	} else if evt.ColumnName == nil {
 		// whole table listener
 		liveInfo.mu.WholeTableListeners.AddQueryListener(
-			evt.QueryExecution, evt.SubQuery, evt.QueryPath,
+			evt.QueryExecution, evt.SubQuery, evt.QueryPath, nil, evt.Window,
 		)
 	} else {
 		// filtered listener
-		columnName := ColumnName(*evt.ColumnName)
-		// initialize listeners for this column (could be done at table create/load)
-		// but that would leave us open when new columns are added
-		listenersForColumn := liveInfo.mu.TableListeners[columnName]
-		if listenersForColumn == nil {
-			listenersForColumn = map[string]*ListenerList{}
-			liveInfo.mu.TableListeners[columnName] = listenersForColumn
-		}
-		// initialize listeners for this value in this column
-		listenersForValue := listenersForColumn[evt.Value.StringVal]
-		if listenersForValue == nil {
-			listenersForValue = table.NewListenerList()
-			listenersForColumn[evt.Value.StringVal] = listenersForValue
-		}
+		listenersForValue := table.filteredListeners(*evt.ColumnName, evt.Value)
 		listenersForValue.AddQueryListener(
 			evt.QueryExecution, evt.SubQuery, evt.QueryPath,
+			NewEqualsExpr(*evt.ColumnName, evt.Value), evt.Window,
 		)
 	}
 }
 
+// filteredListeners returns the listeners for records with the given value
+// in the given column, creating the list if need be.
+// Must be called with liveInfo.mu held.
+func (table *TableDescriptor) filteredListeners(columnNameStr string, value *Value) *ListenerList {
+	liveInfo := table.LiveQueryInfo
+	columnName := ColumnName(columnNameStr)
+	// initialize listeners for this column (could be done at table create/load)
+	// but that would leave us open when new columns are added
+	listenersForColumn := liveInfo.mu.TableListeners[columnName]
+	if listenersForColumn == nil {
+		listenersForColumn = map[string]*ListenerList{}
+		liveInfo.mu.TableListeners[columnName] = listenersForColumn
+	}
+	// initialize listeners for this value in this column
+	listenersForValue := listenersForColumn[value.key()]
+	if listenersForValue == nil {
+		listenersForValue = table.NewListenerList()
+		listenersForColumn[value.key()] = listenersForValue
+	}
+	return listenersForValue
+}
+
 func (table *TableDescriptor) handleRecordSub(evt *RecordSubscriptionEvent) {
 	liveInfo := table.LiveQueryInfo
 	liveInfo.mu.Lock()
 	defer liveInfo.mu.Unlock()
 
-	listenersForValue := liveInfo.mu.RecordListeners[evt.Value.StringVal]
+	listenersForValue := liveInfo.mu.RecordListeners[evt.Value.key()]
 	if listenersForValue == nil {
 		listenersForValue = table.NewListenerList()
-		liveInfo.mu.RecordListeners[evt.Value.StringVal] = listenersForValue
+		liveInfo.mu.RecordListeners[evt.Value.key()] = listenersForValue
 	}
-	listenersForValue.AddRecordListener(evt.QueryExecution, evt.QueryPath)
+	listenersForValue.AddRecordListener(evt.QueryExecution, evt.QueryPath, evt.Selections)
 }
 
 func (table *TableDescriptor) handleTableEvent(evt *TableEvent) {
@@ -158,10 +234,13 @@ func (table *TableDescriptor) handleTableEvent(evt *TableEvent) {
 		// clog.Println(evt.channel, "pushing insert event to table listeners")
 		// whole table listeners
 		liveInfo.mu.WholeTableListeners.SendEvent(evt)
-		// filtered table listeners
+		// filtered table listeners; nulls don't match any value
 		for columnName, listenersForColumn := range liveInfo.mu.TableListeners {
-			valueForColumn := evt.NewRecord.GetField(string(columnName)).StringVal
-			listenersForValue := listenersForColumn[valueForColumn]
+			valueForColumn := evt.NewRecord.GetField(string(columnName))
+			if valueForColumn.Null {
+				continue
+			}
+			listenersForValue := listenersForColumn[valueForColumn.key()]
 			if listenersForValue != nil {
 				listenersForValue.SendEvent(evt)
 			}
@@ -169,13 +248,40 @@ func (table *TableDescriptor) handleTableEvent(evt *TableEvent) {
 	} else if evt.OldRecord != nil && evt.NewRecord != nil {
 		clog.Println(evt.channel, "pushing update event to table listeners")
 		// record listeners
-		primaryKeyValue := evt.NewRecord.GetField(table.PrimaryKey).StringVal
+		primaryKeyValue := evt.NewRecord.primaryKey()
 		recordListeners := liveInfo.mu.RecordListeners[primaryKeyValue]
 		if recordListeners != nil {
 			recordListeners.SendEvent(evt)
 		}
+		// the update may have moved records into or out of windows, or changed aggregates
+		table.recomputeListeners(evt)
 	} else if evt.OldRecord != nil && evt.NewRecord == nil {
-		clog.Println(evt.channel, "TODO: handle delete events")
+		clog.Println(evt.channel, "pushing delete event to table listeners")
+		// A row in a live result set has a record listener, but it may also
+		// be reachable via the table listener that added it. Only tell each
+		// channel once per query path.
+		sent := map[string]bool{}
+		primaryKeyValue := evt.OldRecord.primaryKey()
+		// record listeners
+		recordListeners := liveInfo.mu.RecordListeners[primaryKeyValue]
+		if recordListeners != nil {
+			recordListeners.SendDeleteEvent(evt, sent)
+			delete(liveInfo.mu.RecordListeners, primaryKeyValue)
+		}
+		// whole table listeners
+		liveInfo.mu.WholeTableListeners.SendDeleteEvent(evt, sent)
+		// filtered table listeners
+		for columnName, listenersForColumn := range liveInfo.mu.TableListeners {
+			valueForColumn := evt.OldRecord.GetField(string(columnName))
+			if valueForColumn.Null {
+				continue
+			}
+			listenersForValue := listenersForColumn[valueForColumn.key()]
+			if listenersForValue != nil {
+				listenersForValue.SendDeleteEvent(evt, sent)
+			}
+		}
+		table.recomputeListeners(evt)
 	}
 	endTime := time.Now()
 	duration := endTime.Sub(startTime)
@@ -183,3 +289,36 @@ func (table *TableDescriptor) handleTableEvent(evt *TableEvent) {
 	metrics := evt.channel.Connection.Database.Metrics
 	metrics.liveQueryPushLatency.Observe(float64(duration.Nanoseconds()))
 }
+
+// recomputeListeners recomputes the windowed and aggregate table listeners
+// which the old or new version of the record in evt could matter to.
+// Must be called with liveInfo.mu held.
+func (table *TableDescriptor) recomputeListeners(evt *TableEvent) {
+	liveInfo := table.LiveQueryInfo
+	liveInfo.mu.WholeTableListeners.Recompute()
+	for columnName, listenersForColumn := range liveInfo.mu.TableListeners {
+		values := map[string]bool{}
+		for _, record := range []*Record{evt.OldRecord, evt.NewRecord} {
+			if record != nil && !record.GetField(string(columnName)).Null {
+				values[record.GetField(string(columnName)).key()] = true
+			}
+		}
+		for value := range values {
+			if listenersForValue := listenersForColumn[value]; listenersForValue != nil {
+				listenersForValue.Recompute()
+			}
+		}
+	}
+}
+
+// removeRecordListener stops sending updates for the record with the given
+// primary key to the given channel and query path.
+func (table *TableDescriptor) removeRecordListener(primaryKeyValue string, channel *Channel, queryPath *QueryPath) {
+	liveInfo := table.LiveQueryInfo
+	liveInfo.mu.Lock()
+	defer liveInfo.mu.Unlock()
+
+	if listeners := liveInfo.mu.RecordListeners[primaryKeyValue]; listeners != nil {
+		listeners.removeListener(channel, queryPath)
+	}
+}
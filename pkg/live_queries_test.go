@@ -1,6 +1,9 @@
 package treesql
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func TestLiveQueries(t *testing.T) {
 	server, client, err := NewTestServer()
@@ -11,17 +14,17 @@ func TestLiveQueries(t *testing.T) {
 	defer server.close()
 
 	if _, err := client.Exec(`
-		CREATETABLE blog_posts (
-			id string PRIMARYKEY,
+		CREATE TABLE blog_posts (
+			id string PRIMARY KEY,
 			title string
 		)
 	`); err != nil {
 		t.Fatal(err)
 	}
 	if _, err := client.Exec(`
-		CREATETABLE comments (
-			id string PRIMARYKEY,
-			blog_post_id string REFERENCESTABLE blog_posts,
+		CREATE TABLE comments (
+			id string PRIMARY KEY,
+			blog_post_id string REFERENCES blog_posts,
 			body string
 		)
 	`); err != nil {
@@ -94,3 +97,497 @@ func TestLiveQueries(t *testing.T) {
 
 	<-done // Make sure we're done
 }
+
+func TestLiveQueryWhere(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	if _, err := client.Exec(`CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`); err != nil {
+		t.Fatal(err)
+	}
+
+	_, lqChan, err := client.LiveQuery(`LIVE MANY blog_posts WHERE id > "m" { id }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	// Insert a post which doesn't match the where clause, then one which does.
+	if _, err := client.Exec(`INSERT INTO blog_posts VALUES ("a", "hello world")`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Exec(`INSERT INTO blog_posts VALUES ("z", "hello again world")`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the matching post should be pushed.
+	msg := <-updates
+	if msg.Type != TableUpdateMessage {
+		t.Fatalf("expected %v but got %v", TableUpdateMessage, msg.Type)
+	}
+	selection := msg.TableUpdateMessage.Selection
+	if len(selection) != 1 || selection[0]["id"] != "z" {
+		t.Fatalf("expected table update for post z; got %v", selection)
+	}
+}
+
+func TestLiveQueryWindow(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+		`INSERT INTO blog_posts VALUES ("b", "hello world")`,
+		`INSERT INTO blog_posts VALUES ("c", "hello again world")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`MANY blog_posts ORDER BY id LIMIT 2 { id } live`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	expectWindowUpdate := func(removed string, added string, order string) {
+		msg := <-updates
+		if msg.Type != WindowUpdateMessage {
+			t.Fatalf("expected %v but got %v", WindowUpdateMessage, msg.Type)
+		}
+		update := msg.WindowUpdateMessage
+		if fmt.Sprint(update.Removed) != removed {
+			t.Fatalf("expected removed %s; got %v", removed, update.Removed)
+		}
+		addedIDs := make([]interface{}, len(update.Added))
+		for idx, row := range update.Added {
+			addedIDs[idx] = row["id"]
+		}
+		if fmt.Sprint(addedIDs) != added {
+			t.Fatalf("expected added %s; got %v", added, addedIDs)
+		}
+		if fmt.Sprint(update.Order) != order {
+			t.Fatalf("expected order %s; got %v", order, update.Order)
+		}
+	}
+
+	// A post at the front pushes "c" out of the window.
+	if _, err := client.Exec(`INSERT INTO blog_posts VALUES ("a", "first!")`); err != nil {
+		t.Fatal(err)
+	}
+	expectWindowUpdate("[c]", "[a]", "[a b]")
+
+	// A post after the window doesn't change it.
+	if _, err := client.Exec(`INSERT INTO blog_posts VALUES ("d", "last")`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting a post in the window lets "c" back in.
+	if _, err := client.Exec(`DELETE FROM blog_posts WHERE id = "a"`); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-updates
+	if msg.Type != RecordDeleteMessage {
+		t.Fatalf("expected %v but got %v", RecordDeleteMessage, msg.Type)
+	}
+	expectWindowUpdate("[a]", "[c]", "[b c]")
+}
+
+// Windows are tracked by the same keys as records, whatever the type
+// of their primary key.
+func TestLiveQueryWindowFloatKeys(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE scores (id float PRIMARY KEY, name string)`,
+		`INSERT INTO scores VALUES (1, "pete"), (3, "alice")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`MANY scores ORDER BY id LIMIT 2 { id, name } live`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	// A score at the front pushes 3.0 out of the window, and 1.0 stays.
+	if _, err := client.Exec(`INSERT INTO scores VALUES (0.5, "bob")`); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-updates
+	if msg.Type != WindowUpdateMessage {
+		t.Fatalf("expected %v but got %v", WindowUpdateMessage, msg.Type)
+	}
+	update := msg.WindowUpdateMessage
+	if removed := fmt.Sprint(update.Removed); removed != "[3.0]" {
+		t.Fatalf("expected removed [3.0]; got %s", removed)
+	}
+	if len(update.Added) != 1 {
+		t.Fatalf("expected 1 added record; got %v", update.Added)
+	}
+	if order := fmt.Sprint(update.Order); order != "[0.5 1.0]" {
+		t.Fatalf("expected order [0.5 1.0]; got %s", order)
+	}
+
+	// Changes to the record which left aren't sent anymore.
+	if _, err := client.Exec(`UPDATE scores SET name = "alicia" WHERE id = 3`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Exec(`UPDATE scores SET name = "peter" WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	msg = <-updates
+	if msg.Type != RecordUpdateMessage {
+		t.Fatalf("expected %v but got %v", RecordUpdateMessage, msg.Type)
+	}
+	if name := msg.RecordUpdateMessage.Fields["name"]; name != "peter" {
+		t.Fatalf("expected update for peter; got %v", name)
+	}
+}
+
+func TestLiveQueryAggregate(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+		`CREATE TABLE comments (id string PRIMARY KEY, blog_post_id string REFERENCES blog_posts, body string)`,
+		`INSERT INTO blog_posts VALUES ("0", "hello world")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`MANY blog_posts { id, comment_count: COUNT comments } live`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	expectCount := func(count float64) {
+		msg := <-updates
+		if msg.Type != AggregateUpdateMessage {
+			t.Fatalf("expected %v but got %v", AggregateUpdateMessage, msg.Type)
+		}
+		update := msg.AggregateUpdateMessage
+		if update.Value != count {
+			t.Fatalf("expected count %v; got %v", count, update.Value)
+		}
+		path := update.QueryPath
+		if len(path) != 2 || path[0]["id"] != "0" || path[1]["selection"] != "comment_count" {
+			t.Fatalf("unexpected query path %v", path)
+		}
+	}
+
+	if _, err := client.Exec(`INSERT INTO comments VALUES ("0", "0", "nice post")`); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(1)
+
+	if _, err := client.Exec(`INSERT INTO comments VALUES ("1", "0", "nice post!")`); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(2)
+
+	// Updates which don't change the count aren't pushed.
+	if _, err := client.Exec(`UPDATE comments SET body = "meh" WHERE id = "0"`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Exec(`DELETE FROM comments WHERE id = "1"`); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(1)
+}
+
+func TestLiveQueryMultiRowInsert(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	if _, err := client.Exec(`CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`); err != nil {
+		t.Fatal(err)
+	}
+
+	_, lqChan, err := client.LiveQuery(`MANY blog_posts { id, title } live`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each row should be announced once; re-queries run concurrently,
+	// so they can arrive in either order.
+	done := make(chan error)
+	go func() {
+		seen := map[interface{}]bool{}
+		for i := 0; i < 2; i++ {
+			msg := <-lqChan.Updates
+			if msg.Type != TableUpdateMessage {
+				done <- fmt.Errorf("expected %v but got %v", TableUpdateMessage, msg.Type)
+				return
+			}
+			selection := msg.TableUpdateMessage.Selection
+			if len(selection) != 1 {
+				done <- fmt.Errorf("expected one record per update; got %v", selection)
+				return
+			}
+			seen[selection[0]["id"]] = true
+		}
+		if !seen["0"] || !seen["1"] {
+			done <- fmt.Errorf("expected updates for both records; got %v", seen)
+			return
+		}
+		done <- nil
+	}()
+
+	if _, err := client.Exec(
+		`INSERT INTO blog_posts (id, title) VALUES ("0", "hello world"), ("1", "hello again world")`,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLiveQueryRecursive(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE comments (id string PRIMARY KEY, parent_id string REFERENCES comments, body string)`,
+		`INSERT INTO comments VALUES ("0", NULL, "first")`,
+		`INSERT INTO comments VALUES ("1", "0", "reply")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`
+		MANY comments WHERE id = "0" {
+			id,
+			replies: MANY comments RECURSIVE { id, body }
+		} live
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	// A reply to the reply lands two levels down, and comes with
+	// its own (empty) replies.
+	if _, err := client.Exec(`INSERT INTO comments VALUES ("2", "1", "reply to reply")`); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-updates
+	if msg.Type != TableUpdateMessage {
+		t.Fatalf("expected %v but got %v", TableUpdateMessage, msg.Type)
+	}
+	path := fmt.Sprint(msg.TableUpdateMessage.QueryPath)
+	if path != "[map[id:0] map[selection:replies] map[id:1] map[selection:replies]]" {
+		t.Fatalf("unexpected query path %v", path)
+	}
+	selection := fmt.Sprint(msg.TableUpdateMessage.Selection)
+	if selection != "[map[body:reply to reply id:2 replies:[]]]" {
+		t.Fatalf("unexpected selection %v", selection)
+	}
+
+	// Updates to it land there too.
+	if _, err := client.Exec(`UPDATE comments SET body = "edited" WHERE id = "2"`); err != nil {
+		t.Fatal(err)
+	}
+	msg = <-updates
+	if msg.Type != RecordUpdateMessage {
+		t.Fatalf("expected %v but got %v", RecordUpdateMessage, msg.Type)
+	}
+	path = fmt.Sprint(msg.RecordUpdateMessage.QueryPath)
+	if path != "[map[id:0] map[selection:replies] map[id:1] map[selection:replies] map[id:2]]" {
+		t.Fatalf("unexpected query path %v", path)
+	}
+}
+
+func TestLiveQueryExpressions(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE users (id string PRIMARY KEY, first_name string, last_name string)`,
+		`INSERT INTO users VALUES ("0", "Ada", "Lovelace")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`
+		MANY users { id, given_name: first_name, display_name: first_name || " " || last_name } live
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	if _, err := client.Exec(`UPDATE users SET first_name = "Augusta" WHERE id = "0"`); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-updates
+	if msg.Type != RecordUpdateMessage {
+		t.Fatalf("expected %v but got %v", RecordUpdateMessage, msg.Type)
+	}
+	fields := fmt.Sprint(msg.RecordUpdateMessage.Fields)
+	if fields != "map[display_name:Augusta Lovelace given_name:Augusta id:0]" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+}
+
+func TestLiveQueryIntKeys(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE authors (id int PRIMARY KEY, name string)`,
+		`CREATE TABLE blog_posts (id int PRIMARY KEY, author_id int REFERENCES authors, views int)`,
+		`INSERT INTO authors VALUES (1, "pete")`,
+		`INSERT INTO blog_posts VALUES (10, 1, 0)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`LIVE MANY authors { id, posts: MANY blog_posts { id, views } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	// A post joined to its author by an int foreign key.
+	if _, err := client.Exec(`INSERT INTO blog_posts VALUES (11, 1, 5)`); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-updates
+	if msg.Type != TableUpdateMessage {
+		t.Fatalf("expected %v but got %v", TableUpdateMessage, msg.Type)
+	}
+	path := fmt.Sprint(msg.TableUpdateMessage.QueryPath)
+	if path != "[map[id:1] map[selection:posts]]" {
+		t.Fatalf("unexpected query path %v", path)
+	}
+	selection := msg.TableUpdateMessage.Selection
+	if len(selection) != 1 || selection[0]["id"] != float64(11) || selection[0]["views"] != float64(5) {
+		t.Fatalf("expected table update for post 11; got %v", selection)
+	}
+
+	// Ints are numbers in record updates too.
+	if _, err := client.Exec(`UPDATE blog_posts SET views = views + 1 WHERE id = 10`); err != nil {
+		t.Fatal(err)
+	}
+	msg = <-updates
+	if msg.Type != RecordUpdateMessage {
+		t.Fatalf("expected %v but got %v", RecordUpdateMessage, msg.Type)
+	}
+	path = fmt.Sprint(msg.RecordUpdateMessage.QueryPath)
+	if path != "[map[id:1] map[selection:posts] map[id:10]]" {
+		t.Fatalf("unexpected query path %v", path)
+	}
+	if views := msg.RecordUpdateMessage.Fields["views"]; views != float64(1) {
+		t.Fatalf("expected views to be 1; got %#v", views)
+	}
+}
+
+func TestLiveQueryNullReference(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE users (id string PRIMARY KEY, name string)`,
+		`CREATE TABLE blog_posts (id string PRIMARY KEY, author_id string REFERENCES users, title string)`,
+		`INSERT INTO blog_posts VALUES ("0", NULL, "hello world")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`LIVE MANY blog_posts { id, title, author: ONE users { name } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	// New posts without authors come through with null authors.
+	if _, err := client.Exec(`INSERT INTO blog_posts VALUES ("1", NULL, "hello again world")`); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-updates
+	if msg.Type != TableUpdateMessage {
+		t.Fatalf("expected %v but got %v", TableUpdateMessage, msg.Type)
+	}
+	selection := msg.TableUpdateMessage.Selection
+	if len(selection) != 1 || selection[0]["id"] != "1" {
+		t.Fatalf("expected table update for post 1; got %v", selection)
+	}
+	if author, ok := selection[0]["author"]; !ok || author != nil {
+		t.Fatalf("expected author to be null; got %#v", author)
+	}
+
+	// The posts themselves are still live.
+	if _, err := client.Exec(`UPDATE blog_posts SET title = "goodbye world" WHERE id = "0"`); err != nil {
+		t.Fatal(err)
+	}
+	msg = <-updates
+	if msg.Type != RecordUpdateMessage {
+		t.Fatalf("expected %v but got %v", RecordUpdateMessage, msg.Type)
+	}
+	if title := msg.RecordUpdateMessage.Fields["title"]; title != "goodbye world" {
+		t.Fatalf("expected title to be goodbye world; got %#v", title)
+	}
+}
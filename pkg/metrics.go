@@ -23,6 +23,7 @@ type Metrics struct {
 	selectLatency        prometheus.Summary
 	insertLatency        prometheus.Summary
 	updateLatency        prometheus.Summary
+	deleteLatency        prometheus.Summary
 	liveQueryPushLatency prometheus.Summary
 
 	scanLatency   prometheus.Summary
@@ -37,6 +38,8 @@ func NewMetrics(db *Database) *Metrics {
 				Help: "number of connections to this server over its lifetime",
 			},
 			func() float64 {
+				db.connectionsMu.Lock()
+				defer db.connectionsMu.Unlock()
 				return float64(db.NextConnectionID)
 			},
 		),
@@ -46,6 +49,8 @@ func NewMetrics(db *Database) *Metrics {
 				Help: "number of connections currently open",
 			},
 			func() float64 {
+				db.connectionsMu.Lock()
+				defer db.connectionsMu.Unlock()
 				return float64(len(db.Connections))
 			},
 		),
@@ -55,9 +60,11 @@ func NewMetrics(db *Database) *Metrics {
 				Help: "number of channels currently open across all connections",
 			},
 			func() float64 {
-				// TODO: synchronize access to db.Connections...
+				// TODO: synchronize access to conn.Channels...
 				// TODO: make this not O(connections) somehow...
 				// but I also don't want two sources of truth
+				db.connectionsMu.Lock()
+				defer db.connectionsMu.Unlock()
 				count := 0
 				for _, conn := range db.Connections {
 					count += len(conn.Channels)
@@ -140,6 +147,12 @@ func NewMetrics(db *Database) *Metrics {
 				Help: "latency to execute an UPDATE statement",
 			},
 		),
+		deleteLatency: prometheus.NewSummary(
+			prometheus.SummaryOpts{
+				Name: "delete_latency_ns",
+				Help: "latency to execute a DELETE statement",
+			},
+		),
 		liveQueryPushLatency: prometheus.NewSummary(
 			prometheus.SummaryOpts{
 				Name: "live_query_push_latency_ns",
@@ -174,6 +187,7 @@ func NewMetrics(db *Database) *Metrics {
 	reg.MustRegister(m.selectLatency)
 	reg.MustRegister(m.insertLatency)
 	reg.MustRegister(m.updateLatency)
+	reg.MustRegister(m.deleteLatency)
 	reg.MustRegister(m.liveQueryPushLatency)
 	reg.MustRegister(m.scanLatency)
 	reg.MustRegister(m.lookupLatency)
@@ -0,0 +1,138 @@
+package treesql
+
+import (
+	"testing"
+)
+
+func TestNulls(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE users (id string PRIMARY KEY, name string NOT NULL)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, title string NOT NULL, author_id string REFERENCES users, views int)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO users VALUES ("0", "pete")`,
+			ack:  "INSERT 1",
+		},
+		// Verify that NOT NULL columns, and primary keys, need values.
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("0", NULL, "0", 5)`,
+			error: "validation error: column blog_posts.title is NOT NULL, so it needs a value",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts (id, views) VALUES ("0", 5)`,
+			error: "validation error: column blog_posts.title is NOT NULL, so it needs a value",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES (NULL, "hello world", "0", 5)`,
+			error: "validation error: column blog_posts.id is NOT NULL, so it needs a value",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("0", $1, "0", 5)`,
+			args:  []interface{}{nil},
+			error: "validation error: column blog_posts.title is NOT NULL, so it needs a value",
+		},
+		// Happy path: other columns can be null, or left out.
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "hello world", "0", 5), ("1", "hello again world", NULL, $1)`,
+			args: []interface{}{nil},
+			ack:  "INSERT 2",
+		},
+		{
+			stmt: `INSERT INTO blog_posts (id, title) VALUES ("2", "goodbye world")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt:  `UPDATE blog_posts SET title = NULL WHERE id = "0"`,
+			error: "validation error: column blog_posts.title is NOT NULL, so it needs a value",
+		},
+		{
+			stmt: `UPDATE blog_posts SET views = views + 1 WHERE id <> ""`,
+			ack:  "UPDATE 3",
+		},
+		// A null reference joins to nothing; nulls sort last.
+		{
+			query: `MANY blog_posts ORDER BY views { id, views, author: ONE users { name } }`,
+			initialResult: `[
+  {
+    "author": [
+      {
+        "name": "pete"
+      }
+    ],
+    "id": "0",
+    "views": 6
+  },
+  {
+    "author": null,
+    "id": "1",
+    "views": null
+  },
+  {
+    "author": null,
+    "id": "2",
+    "views": null
+  }
+]`,
+		},
+		{
+			query: `MANY users { name, posts: MANY blog_posts { id } }`,
+			initialResult: `[
+  {
+    "name": "pete",
+    "posts": [
+      {
+        "id": "0"
+      }
+    ]
+  }
+]`,
+		},
+		{
+			query: `MANY blog_posts WHERE author_id IS NULL { id }`,
+			initialResult: `[
+  {
+    "id": "1"
+  },
+  {
+    "id": "2"
+  }
+]`,
+		},
+		// Existing records get null for added columns, so NOT NULL columns
+		// can only be added to empty tables.
+		{
+			stmt:  `ALTER TABLE blog_posts ADD COLUMN body string NOT NULL`,
+			error: "altering table: column blog_posts.body is NOT NULL, so it needs a value",
+		},
+		{
+			stmt: `ALTER TABLE blog_posts ADD COLUMN body string`,
+			ack:  "ALTER TABLE",
+		},
+		{
+			query: `ONE blog_posts WHERE id = "0" { body }`,
+			initialResult: `[
+  {
+    "body": null
+  }
+]`,
+		},
+		{
+			query: `MANY __columns__ WHERE table_name = "users" { name, not_null }`,
+			initialResult: `[
+  {
+    "name": "id",
+    "not_null": false
+  },
+  {
+    "name": "name",
+    "not_null": true
+  }
+]`,
+		},
+	})
+}
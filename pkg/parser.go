@@ -1,83 +1,1145 @@
 package treesql
 
 import (
-	"github.com/alecthomas/participle"
-	"github.com/alecthomas/participle/lexer"
-)
-
-var (
-	sqlLexer = lexer.Unquote(
-		lexer.Upper(
-			lexer.Must(
-				lexer.Regexp(`(\s+)`+
-					`|(?P<Keyword>(?i)LIVE|SELECT|INSERT|INTO|VALUES|CREATETABLE|PRIMARYKEY|REFERENCESTABLE|UPDATE|SET|ONE|MANY|FROM|TOP|DISTINCT|ALL|WHERE|GROUP|BY|HAVING|UNION|MINUS|EXCEPT|INTERSECT|ORDER|LIMIT|OFFSET|TRUE|FALSE|NULL|IS|NOT|ANY|SOME|BETWEEN|AND|OR|LIKE|AS)`+
-					`|(?P<Ident>[a-zA-Z_][a-zA-Z0-9_]*)`+
-					`|(?P<Number>[-+]?\d*\.?\d+([eE][-+]?\d+)?)`+
-					`|(?P<String>'[^']*'|"[^"]*")`+
-					`|(?P<Operators><>|!=|<=|>=|[-+*/%,.()\{\}=<>:])`,
-				),
-			),
-			"Keyword",
-		),
-		"String",
-	)
-	sqlParser = participle.MustBuild(&Statement{}, sqlLexer)
+	"fmt"
+	"strconv"
+	"strings"
 )
 
+// Statement is a parsed statement. Nodes' Pos fields are filled in by
+// the parser with the position of their first token, so that errors
+// can point at the part of the statement they're about.
 type Statement struct {
-	Select      *Select      `  @@`
-	Insert      *Insert      `| @@`
-	Update      *Update      `| @@`
-	CreateTable *CreateTable `| @@`
+	Select      *Select
+	Insert      *Insert
+	Update      *Update
+	Delete      *Delete
+	CreateTable *CreateTable
+	CreateIndex *CreateIndex
+	DropTable   *DropTable
+	AlterTable  *AlterTable
 }
 
 type CreateTable struct {
-	Name    string               `"CREATETABLE" @Ident` // parser can't distinguish idents and keywords
-	Columns []*CreateTableColumn `"(" @@ { "," @@ } ")"`
+	Pos         Position
+	Name        string
+	Columns     []*CreateTableColumn
+	Constraints []*TableConstraint // written among the columns, e.g. after them
 }
 
 type CreateTableColumn struct {
-	Name       string  `@Ident`
-	TypeName   string  `@Ident`
-	PrimaryKey bool    `[ @"PRIMARYKEY"`              // parser can't distinguish idents and keywords
-	References *string `| "REFERENCESTABLE" @Ident ]` // parser can't distinguish idents and keywords
+	Pos        Position
+	Name       string
+	TypeName   string // or "serial", for an int column numbering records 1, 2, 3...
+	PrimaryKey bool
+	NotNull    bool
+	Default    *ValueExpr // for inserts which leave the column out
+	Unique     bool
+	Check      *Expr
+	References *string
+	OnDelete   ReferenceAction // "" if not given
+	OnUpdate   ReferenceAction // "" if not given
+}
+
+// TableConstraint is a constraint on several of a table's columns,
+// e.g. `UNIQUE (first_name, last_name)` or `CHECK (starts < ends)`.
+// Exactly one of Unique and Check is set.
+type TableConstraint struct {
+	Pos    Position
+	Unique []string
+	Check  *Expr
+}
+
+// CreateIndex indexes one of a table's columns, so that queries
+// filtering or joining on it don't have to scan the table.
+type CreateIndex struct {
+	Pos        Position
+	Table      string
+	ColumnName string
+}
+
+// DropTable removes a table. With Cascade, other tables' references
+// to it are removed too; otherwise they stop it from being dropped.
+type DropTable struct {
+	Pos     Position
+	Name    string
+	Cascade bool
+}
+
+// AlterTable changes one of a table's columns; exactly one of the
+// actions is set.
+type AlterTable struct {
+	Pos           Position
+	Name          string
+	AddColumn     *CreateTableColumn
+	DropColumn    *AlterColumn
+	RenameColumn  *RenameColumn
+	AddReference  *AddReference
+	DropReference *AlterColumn
+}
+
+// AlterColumn names the column an action applies to.
+type AlterColumn struct {
+	Pos  Position
+	Name string
+}
+
+type RenameColumn struct {
+	Pos     Position
+	Name    string
+	NewName string
+}
+
+type AddReference struct {
+	Pos        Position
+	Name       string
+	References string
+	OnDelete   ReferenceAction // "" if not given
+	OnUpdate   ReferenceAction // "" if not given
 }
 
 type Insert struct {
-	Table  string   `"INSERT" "INTO" @Ident`
-	Values []string `"VALUES" "(" @String { "," @String } ")"`
+	Pos        Position
+	Table      string
+	Columns    []string // defaults to all columns, in order
+	Rows       []*InsertRow
+	OnConflict *OnConflict // nil if a taken primary key is an error
+	Returning  *Select     // MANY of Table, for the inserted records; nil if not given
+}
+
+// OnConflict says what to do with rows whose primary key is taken: skip
+// them, or update the existing records. Assignments can use the row's
+// values as `excluded.column`.
+type OnConflict struct {
+	Pos         Position
+	DoNothing   bool
+	Assignments []*Assignment // for DO UPDATE SET
+}
+
+type InsertRow struct {
+	Pos    Position
+	Values []*Literal
 }
 
 type Update struct {
-	Table           string `"UPDATE" @Ident`
-	ColumnName      string `"SET" @Ident`
-	Value           string `"=" @String`
-	WhereColumnName string `"WHERE" @Ident`
-	EqualsValue     string `"=" @String`
+	Pos         Position
+	Table       string
+	Assignments []*Assignment
+	Where       *Expr
+	Returning   *Select // MANY of Table, for the updated records; nil if not given
+}
+
+// Assignment is e.g. `views = views + 1`. The value is computed from
+// the row as it was before the update.
+type Assignment struct {
+	Pos        Position
+	ColumnName string
+	Value      *ValueExpr
+}
+
+// Literal is a value written to a column: a string, a number, a bool,
+// NULL, or a placeholder like `$1`, which is replaced by the statement's
+// first argument before validation.
+type Literal struct {
+	Pos         Position
+	String      *string
+	TypeName    *string // for strings written as another type, e.g. TIMESTAMP "2018-01-02T15:04:05Z"
+	Number      *string
+	Bool        *bool
+	Null        bool
+	Placeholder *string
+}
+
+type Delete struct {
+	Pos       Position
+	Table     string
+	Where     *Expr
+	Returning *Select // MANY of Table, for the deleted records; nil if not given
 }
 
 type Select struct {
-	Many       bool         `( @"MANY"`
-	One        bool         `| @"ONE" )`
-	Table      string       `@Ident`
-	Where      *Where       `[ "WHERE" @@ ]`
-	Selections []*Selection `"{" @@ { "," @@ } "}"` // TODO: * for all columns
-	Live       bool         `[ @"LIVE" ]`           // would put this at the beginning but it seems to cause indeterminancy
+	Pos        Position
+	Many       bool
+	One        bool
+	Table      string
+	Via        *string // the column to join on, if there's more than one
+	Recursive  bool    // repeat this selection inside itself, down a self-reference
+	MaxDepth   *int    // levels, including this one; unlimited if nil
+	Where      *Expr
+	OrderBy    *OrderBy
+	Limit      *int
+	Offset     *int
+	Selections []*Selection
+	Live       bool // written first, or (as before) last
+}
+
+type OrderBy struct {
+	Pos        Position
+	ColumnName string
+	Desc       bool
+}
+
+// Expr is a boolean expression, as found in WHERE clauses.
+// Precedence, loosest first: OR, AND, NOT, comparisons.
+type Expr struct {
+	Or []*AndExpr
+}
+
+type AndExpr struct {
+	And []*NotExpr
+}
+
+type NotExpr struct {
+	Not       bool
+	Predicate *Predicate
+}
+
+type Predicate struct {
+	Parens     *Expr
+	Comparison *Comparison
 }
 
-type Where struct {
-	ColumnName string `@Ident "="`
-	Value      string `@String`
+type Comparison struct {
+	Left    *Term
+	Op      string
+	Right   *Term
+	IsNull  *IsNull
+	Between *Between
+}
+
+type IsNull struct {
+	Not bool
+}
+
+type Between struct {
+	Low  *Term
+	High *Term
+}
+
+// Term is an operand of a comparison: a column of the
+// current row, or a literal.
+type Term struct {
+	Pos         Position
+	Null        bool
+	Number      *string
+	Bool        *bool
+	String      *string
+	TypeName    *string // as in Literal
+	Placeholder *string // bound to an argument before validation
+	Column      *string
+}
+
+// ValueExpr is an expression computing a value from the current
+// row, e.g. `views + 1`, `upper(title)` or `first_name || ' ' || last_name`.
+type ValueExpr struct {
+	Left *Sum
+	Rest []*ConcatOp
+}
+
+type ConcatOp struct {
+	Pos   Position
+	Op    string
+	Right *Sum
+}
+
+type Sum struct {
+	Left *Product
+	Rest []*SumOp
+}
+
+type SumOp struct {
+	Pos   Position
+	Op    string
+	Right *Product
+}
+
+type Product struct {
+	Left *Factor
+	Rest []*ProductOp
+}
+
+type ProductOp struct {
+	Pos   Position
+	Op    string
+	Right *Factor
+}
+
+type Factor struct {
+	Pos    Position
+	Parens *ValueExpr
+	Term   *Term
+	Call   *CallArgs // if present, Term is the function name
+}
+
+type CallArgs struct {
+	Args []*ValueExpr
 }
 
 type Selection struct {
-	Name      string  `@Ident`
-	SubSelect *Select `[ ":" @@ ]`
+	Pos       Position
+	Star      bool // expanded to the table's columns during validation
+	Name      string
+	Aggregate *Aggregate
+	SubSelect *Select
+	Expr      *ValueExpr // e.g. `score: upvotes - downvotes`, or just `title_text: title`
 }
 
-// Parse parses sql
+// Aggregate is e.g. `COUNT comments` or `SUM comments.score`, over the
+// records of a table referencing the table above, as with MANY.
+type Aggregate struct {
+	Pos        Position
+	Function   string
+	Table      string
+	ColumnName *string
+	Via        *string
+	Where      *Expr
+}
+
+// Parse parses sql. Syntax errors are returned as *SyntaxErrors: the
+// parser carries on after an error in a list item (a selection, a
+// column, a row...), so that it can report several at once.
 func Parse(sql string) (*Statement, error) {
-	result := &Statement{}
-	err := sqlParser.ParseString(sql, result)
-	return result, err
+	p := &parser{tokens: lex(sql)}
+	statement := p.parseStatement()
+	if len(p.errors) > 0 {
+		return nil, &SyntaxErrors{Errors: p.errors}
+	}
+	return statement, nil
+}
+
+// ParseValueExpr parses an expression on its own, e.g. a column's
+// default as it's stored in __columns__.
+func ParseValueExpr(text string) (*ValueExpr, error) {
+	p := &parser{tokens: lex(text)}
+	var expr *ValueExpr
+	func() {
+		defer p.recoverBailout(func() {})
+		expr = p.parseValueExpr()
+		if p.peek().typ != eofToken {
+			p.fail("end of expression")
+		}
+	}()
+	if len(p.errors) > 0 {
+		return nil, &SyntaxErrors{Errors: p.errors}
+	}
+	return expr, nil
+}
+
+// ParseExpr parses a boolean expression on its own, e.g. a CHECK
+// constraint as it's stored in __constraints__.
+func ParseExpr(text string) (*Expr, error) {
+	p := &parser{tokens: lex(text)}
+	var expr *Expr
+	func() {
+		defer p.recoverBailout(func() {})
+		expr = p.parseExpr()
+		if p.peek().typ != eofToken {
+			p.fail("end of expression")
+		}
+	}()
+	if len(p.errors) > 0 {
+		return nil, &SyntaxErrors{Errors: p.errors}
+	}
+	return expr, nil
+}
+
+// parser is a recursive descent parser. Keywords are only keywords where
+// the grammar expects one, so e.g. `references` can be a column name.
+type parser struct {
+	tokens []token
+	idx    int
+	errors []error
+}
+
+// bailout is panicked with to abandon parsing after an error, up to the
+// nearest point which can recover from it.
+type bailout struct{}
+
+// statements
+
+func (p *parser) parseStatement() (statement *Statement) {
+	statement = &Statement{}
+	defer p.recoverBailout(func() {})
+	switch {
+	case p.atKeyword("LIVE") || p.atKeyword("MANY") || p.atKeyword("ONE"):
+		statement.Select = p.parseTopLevelSelect()
+	case p.atKeyword("INSERT"):
+		statement.Insert = p.parseInsert()
+	case p.atKeyword("UPDATE"):
+		statement.Update = p.parseUpdate()
+	case p.atKeyword("DELETE"):
+		statement.Delete = p.parseDelete()
+	case p.atKeyword("CREATE"):
+		if next := p.peekAt(1); next.typ == wordToken && strings.EqualFold(next.text, "INDEX") {
+			statement.CreateIndex = p.parseCreateIndex()
+		} else {
+			statement.CreateTable = p.parseCreateTable()
+		}
+	case p.atKeyword("DROP"):
+		statement.DropTable = p.parseDropTable()
+	case p.atKeyword("ALTER"):
+		statement.AlterTable = p.parseAlterTable()
+	default:
+		p.fail("MANY, ONE, LIVE, INSERT, UPDATE, DELETE, CREATE TABLE, CREATE INDEX, DROP TABLE or ALTER TABLE")
+	}
+	if p.peek().typ != eofToken {
+		p.fail("end of statement")
+	}
+	return statement
+}
+
+func (p *parser) parseCreateTable() *CreateTable {
+	create := &CreateTable{Pos: p.peek().pos}
+	p.expectKeyword("CREATE")
+	if !p.acceptKeyword("TABLE") {
+		p.fail("TABLE or INDEX")
+	}
+	create.Name = p.expectWord("a table name")
+	p.expectOp("(")
+	p.commaList(")", func() {
+		// a column can be called e.g. "unique", but not followed by "("
+		if p.atKeyword("UNIQUE", "CHECK") && p.peekAt(1).typ == operatorToken && p.peekAt(1).text == "(" {
+			create.Constraints = append(create.Constraints, p.parseTableConstraint())
+			return
+		}
+		create.Columns = append(create.Columns, p.parseCreateTableColumn())
+	})
+	return create
+}
+
+func (p *parser) parseTableConstraint() *TableConstraint {
+	constraint := &TableConstraint{Pos: p.peek().pos}
+	if !p.acceptKeyword("UNIQUE") {
+		constraint.Check = p.parseCheck()
+		return constraint
+	}
+	p.expectOp("(")
+	p.commaList(")", func() {
+		constraint.Unique = append(constraint.Unique, p.expectWord("a column name"))
+	})
+	return constraint
+}
+
+func (p *parser) parseCheck() *Expr {
+	p.expectKeyword("CHECK")
+	p.expectOp("(")
+	check := p.parseExpr()
+	p.expectOp(")")
+	return check
+}
+
+func (p *parser) parseCreateTableColumn() *CreateTableColumn {
+	column := &CreateTableColumn{Pos: p.peek().pos}
+	column.Name = p.expectWord("a column name")
+	column.TypeName = p.expectWord("a type")
+	for {
+		switch {
+		case p.acceptKeyword("PRIMARY"):
+			p.expectKeyword("KEY")
+			column.PrimaryKey = true
+		case p.acceptKeyword("NOT"):
+			p.expectKeyword("NULL")
+			column.NotNull = true
+		case p.acceptKeyword("DEFAULT"):
+			column.Default = p.parseValueExpr()
+		case p.acceptKeyword("UNIQUE"):
+			column.Unique = true
+		case p.atKeyword("CHECK"):
+			column.Check = p.parseCheck()
+		case p.acceptKeyword("REFERENCES"):
+			references := p.expectWord("a table name")
+			column.References = &references
+			column.OnDelete, column.OnUpdate = p.parseReferenceActions()
+		default:
+			return column
+		}
+	}
+}
+
+// parseReferenceActions parses what happens when a referenced record is
+// deleted or has its primary key changed, e.g. `ON DELETE CASCADE`.
+func (p *parser) parseReferenceActions() (onDelete ReferenceAction, onUpdate ReferenceAction) {
+	for p.acceptKeyword("ON") {
+		action := &onUpdate
+		if p.acceptKeyword("DELETE") {
+			action = &onDelete
+		} else {
+			p.expectKeyword("UPDATE")
+		}
+		switch {
+		case p.acceptKeyword("CASCADE"):
+			*action = Cascade
+		case p.acceptKeyword("RESTRICT"):
+			*action = Restrict
+		case p.acceptKeyword("SET"):
+			p.expectKeyword("NULL")
+			*action = SetNull
+		default:
+			p.fail("CASCADE, SET NULL or RESTRICT")
+		}
+	}
+	return onDelete, onUpdate
+}
+
+func (p *parser) parseCreateIndex() *CreateIndex {
+	create := &CreateIndex{Pos: p.peek().pos}
+	p.expectKeyword("CREATE")
+	p.expectKeyword("INDEX")
+	p.expectKeyword("ON")
+	create.Table = p.expectWord("a table name")
+	p.expectOp("(")
+	create.ColumnName = p.expectWord("a column name")
+	p.expectOp(")")
+	return create
+}
+
+func (p *parser) parseDropTable() *DropTable {
+	drop := &DropTable{Pos: p.peek().pos}
+	p.expectKeyword("DROP")
+	p.expectKeyword("TABLE")
+	drop.Name = p.expectWord("a table name")
+	drop.Cascade = p.acceptKeyword("CASCADE")
+	return drop
+}
+
+func (p *parser) parseAlterTable() *AlterTable {
+	alter := &AlterTable{Pos: p.peek().pos}
+	p.expectKeyword("ALTER")
+	p.expectKeyword("TABLE")
+	alter.Name = p.expectWord("a table name")
+	switch {
+	case p.acceptKeyword("ADD"):
+		if p.acceptKeyword("REFERENCE") {
+			reference := &AddReference{Pos: p.peek().pos}
+			reference.Name = p.expectWord("a column name")
+			p.expectKeyword("REFERENCES")
+			reference.References = p.expectWord("a table name")
+			reference.OnDelete, reference.OnUpdate = p.parseReferenceActions()
+			alter.AddReference = reference
+			break
+		}
+		p.expectKeyword("COLUMN")
+		alter.AddColumn = p.parseCreateTableColumn()
+	case p.acceptKeyword("DROP"):
+		if p.acceptKeyword("REFERENCE") {
+			alter.DropReference = p.parseAlterColumn()
+			break
+		}
+		p.expectKeyword("COLUMN")
+		alter.DropColumn = p.parseAlterColumn()
+	case p.acceptKeyword("RENAME"):
+		p.expectKeyword("COLUMN")
+		rename := &RenameColumn{Pos: p.peek().pos}
+		rename.Name = p.expectWord("a column name")
+		p.expectKeyword("TO")
+		rename.NewName = p.expectWord("a column name")
+		alter.RenameColumn = rename
+	default:
+		p.fail("ADD, DROP or RENAME")
+	}
+	return alter
+}
+
+func (p *parser) parseAlterColumn() *AlterColumn {
+	column := &AlterColumn{Pos: p.peek().pos}
+	column.Name = p.expectWord("a column name")
+	return column
+}
+
+func (p *parser) parseInsert() *Insert {
+	insert := &Insert{Pos: p.peek().pos}
+	p.expectKeyword("INSERT")
+	p.expectKeyword("INTO")
+	insert.Table = p.expectWord("a table name")
+	if p.acceptOp("(") {
+		p.commaList(")", func() {
+			insert.Columns = append(insert.Columns, p.expectWord("a column name"))
+		})
+	}
+	p.expectKeyword("VALUES")
+	for {
+		p.recoverTo([]string{",", "ON", "RETURNING"}, func() {
+			insert.Rows = append(insert.Rows, p.parseInsertRow())
+		})
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	if p.atKeyword("ON") {
+		insert.OnConflict = p.parseOnConflict()
+	}
+	insert.Returning = p.parseReturning(insert.Table)
+	return insert
+}
+
+func (p *parser) parseOnConflict() *OnConflict {
+	onConflict := &OnConflict{Pos: p.peek().pos}
+	p.expectKeyword("ON")
+	p.expectKeyword("CONFLICT")
+	p.expectKeyword("DO")
+	if p.acceptKeyword("NOTHING") {
+		onConflict.DoNothing = true
+		return onConflict
+	}
+	p.expectKeyword("UPDATE")
+	p.expectKeyword("SET")
+	onConflict.Assignments = p.parseAssignments("RETURNING")
+	return onConflict
+}
+
+func (p *parser) parseInsertRow() *InsertRow {
+	row := &InsertRow{Pos: p.peek().pos}
+	p.expectOp("(")
+	p.commaList(")", func() {
+		row.Values = append(row.Values, p.parseLiteral())
+	})
+	return row
+}
+
+func (p *parser) parseLiteral() *Literal {
+	tok := p.peek()
+	literal := &Literal{Pos: tok.pos}
+	switch {
+	case tok.typ == stringToken:
+		literal.String = &tok.text
+	case tok.typ == placeholderToken:
+		literal.Placeholder = &tok.text
+	case p.atSignedNumber():
+		number := p.signedNumber()
+		literal.Number = &number
+		return literal
+	case p.acceptKeyword("NULL"):
+		literal.Null = true
+		return literal
+	case p.atKeyword("TRUE", "FALSE"):
+		literal.Bool = p.parseBool()
+		return literal
+	case p.atTypedString():
+		literal.TypeName, literal.String = p.parseTypedString()
+		return literal
+	default:
+		p.fail("a value or placeholder")
+	}
+	p.advance()
+	return literal
+}
+
+func (p *parser) parseUpdate() *Update {
+	update := &Update{Pos: p.peek().pos}
+	p.expectKeyword("UPDATE")
+	update.Table = p.expectWord("a table name")
+	p.expectKeyword("SET")
+	update.Assignments = p.parseAssignments("WHERE")
+	p.expectKeyword("WHERE")
+	update.Where = p.parseExpr()
+	update.Returning = p.parseReturning(update.Table)
+	return update
+}
+
+// parseAssignments parses a comma-separated list of assignments, which
+// is followed by the given keyword, if anything.
+func (p *parser) parseAssignments(followedBy string) []*Assignment {
+	var assignments []*Assignment
+	for {
+		p.recoverTo([]string{",", followedBy}, func() {
+			assignments = append(assignments, p.parseAssignment())
+		})
+		if !p.acceptOp(",") {
+			return assignments
+		}
+	}
+}
+
+func (p *parser) parseAssignment() *Assignment {
+	assignment := &Assignment{Pos: p.peek().pos}
+	assignment.ColumnName = p.expectWord("a column name")
+	p.expectOp("=")
+	assignment.Value = p.parseValueExpr()
+	return assignment
+}
+
+func (p *parser) parseDelete() *Delete {
+	delete := &Delete{Pos: p.peek().pos}
+	p.expectKeyword("DELETE")
+	p.expectKeyword("FROM")
+	delete.Table = p.expectWord("a table name")
+	p.expectKeyword("WHERE")
+	delete.Where = p.parseExpr()
+	delete.Returning = p.parseReturning(delete.Table)
+	return delete
+}
+
+// parseReturning parses e.g. `RETURNING { id, author: ONE users { name } }`,
+// if it's there, as a selection of the written records.
+func (p *parser) parseReturning(table string) *Select {
+	if !p.atKeyword("RETURNING") {
+		return nil
+	}
+	query := &Select{Pos: p.peek().pos, Many: true, Table: table}
+	p.advance()
+	p.expectOp("{")
+	p.commaList("}", func() {
+		query.Selections = append(query.Selections, p.parseSelection())
+	})
+	return query
+}
+
+// selects
+
+func (p *parser) parseTopLevelSelect() *Select {
+	live := p.acceptKeyword("LIVE")
+	query := p.parseSelect()
+	query.Live = live || p.acceptKeyword("LIVE")
+	return query
+}
+
+func (p *parser) parseSelect() *Select {
+	query := &Select{Pos: p.peek().pos}
+	switch {
+	case p.acceptKeyword("MANY"):
+		query.Many = true
+	case p.acceptKeyword("ONE"):
+		query.One = true
+	default:
+		p.fail("MANY or ONE")
+	}
+	query.Table = p.expectWord("a table name")
+	if p.acceptKeyword("VIA") {
+		via := p.expectWord("a column name")
+		query.Via = &via
+	}
+	if p.acceptKeyword("RECURSIVE") {
+		query.Recursive = true
+		if p.acceptKeyword("MAXDEPTH") {
+			maxDepth := p.expectInt()
+			query.MaxDepth = &maxDepth
+		}
+	}
+	if p.acceptKeyword("WHERE") {
+		query.Where = p.parseExpr()
+	}
+	if p.atKeyword("ORDER") {
+		query.OrderBy = p.parseOrderBy()
+	}
+	if p.acceptKeyword("LIMIT") {
+		limit := p.expectInt()
+		query.Limit = &limit
+	}
+	if p.acceptKeyword("OFFSET") {
+		offset := p.expectInt()
+		query.Offset = &offset
+	}
+	p.expectOp("{")
+	p.commaList("}", func() {
+		query.Selections = append(query.Selections, p.parseSelection())
+	})
+	return query
+}
+
+func (p *parser) parseOrderBy() *OrderBy {
+	p.expectKeyword("ORDER")
+	p.expectKeyword("BY")
+	orderBy := &OrderBy{Pos: p.peek().pos}
+	orderBy.ColumnName = p.expectWord("a column name")
+	if !p.acceptKeyword("ASC") {
+		orderBy.Desc = p.acceptKeyword("DESC")
+	}
+	return orderBy
+}
+
+var aggregateFunctions = []string{"COUNT", "SUM", "MIN", "MAX", "AVG"}
+
+func (p *parser) parseSelection() *Selection {
+	selection := &Selection{Pos: p.peek().pos}
+	if p.acceptOp("*") {
+		selection.Star = true
+		return selection
+	}
+	selection.Name = p.expectWord("a column name or *")
+	if !p.acceptOp(":") {
+		return selection
+	}
+	// `ONE`, `COUNT` etc. are only keywords if followed by a table name;
+	// otherwise they're columns in an expression, e.g. `count + 1`.
+	followedByWord := p.peekAt(1).typ == wordToken
+	switch {
+	case (p.atKeyword("MANY") || p.atKeyword("ONE")) && followedByWord:
+		selection.SubSelect = p.parseSelect()
+	case p.atKeyword(aggregateFunctions...) && followedByWord:
+		selection.Aggregate = p.parseAggregate()
+	default:
+		selection.Expr = p.parseValueExpr()
+	}
+	return selection
+}
+
+func (p *parser) parseAggregate() *Aggregate {
+	aggregate := &Aggregate{Pos: p.peek().pos}
+	aggregate.Function = strings.ToUpper(p.advance().text)
+	aggregate.Table = p.expectWord("a table name")
+	if p.acceptOp(".") {
+		columnName := p.expectWord("a column name")
+		aggregate.ColumnName = &columnName
+	}
+	if p.acceptKeyword("VIA") {
+		via := p.expectWord("a column name")
+		aggregate.Via = &via
+	}
+	if p.acceptKeyword("WHERE") {
+		aggregate.Where = p.parseExpr()
+	}
+	return aggregate
+}
+
+// boolean expressions
+
+func (p *parser) parseExpr() *Expr {
+	expr := &Expr{Or: []*AndExpr{p.parseAndExpr()}}
+	for p.acceptKeyword("OR") {
+		expr.Or = append(expr.Or, p.parseAndExpr())
+	}
+	return expr
+}
+
+func (p *parser) parseAndExpr() *AndExpr {
+	and := &AndExpr{And: []*NotExpr{p.parseNotExpr()}}
+	for p.acceptKeyword("AND") {
+		and.And = append(and.And, p.parseNotExpr())
+	}
+	return and
+}
+
+func (p *parser) parseNotExpr() *NotExpr {
+	not := &NotExpr{Not: p.acceptKeyword("NOT")}
+	if p.acceptOp("(") {
+		not.Predicate = &Predicate{Parens: p.parseExpr()}
+		p.expectOp(")")
+		return not
+	}
+	not.Predicate = &Predicate{Comparison: p.parseComparison()}
+	return not
+}
+
+var comparisonOps = []string{"<>", "!=", "<=", ">=", "=", "<", ">"}
+
+func (p *parser) parseComparison() *Comparison {
+	comparison := &Comparison{Left: p.parseTerm()}
+	switch {
+	case p.acceptKeyword("IS"):
+		comparison.IsNull = &IsNull{Not: p.acceptKeyword("NOT")}
+		p.expectKeyword("NULL")
+	case p.acceptKeyword("BETWEEN"):
+		between := &Between{Low: p.parseTerm()}
+		p.expectKeyword("AND")
+		between.High = p.parseTerm()
+		comparison.Between = between
+	case p.atOp(comparisonOps...):
+		comparison.Op = p.advance().text
+		comparison.Right = p.parseTerm()
+	default:
+		p.fail("a comparison operator, IS or BETWEEN")
+	}
+	return comparison
+}
+
+// reservedInTerms are keywords which can follow an expression, so
+// they can't be used as column names in one.
+var reservedInTerms = []string{"AND", "OR", "NOT", "IS", "BETWEEN", "WHERE", "ORDER", "LIMIT", "OFFSET", "LIVE"}
+
+func (p *parser) parseTerm() *Term {
+	tok := p.peek()
+	term := &Term{Pos: tok.pos}
+	switch {
+	case p.atSignedNumber():
+		number := p.signedNumber()
+		term.Number = &number
+	case tok.typ == stringToken:
+		term.String = &tok.text
+		p.advance()
+	case tok.typ == placeholderToken:
+		term.Placeholder = &tok.text
+		p.advance()
+	case p.acceptKeyword("NULL"):
+		term.Null = true
+	case p.atKeyword("TRUE", "FALSE"):
+		term.Bool = p.parseBool()
+	case p.atTypedString():
+		term.TypeName, term.String = p.parseTypedString()
+	case p.atKeyword("EXCLUDED") && p.peekAt(1).typ == operatorToken && p.peekAt(1).text == ".":
+		// the proposed row, in ON CONFLICT DO UPDATE
+		p.advance()
+		p.advance()
+		column := excludedPrefix + p.expectWord("a column name")
+		term.Column = &column
+	case tok.typ == wordToken && !p.atKeyword(reservedInTerms...):
+		term.Column = &tok.text
+		p.advance()
+	default:
+		p.fail("a column, value or placeholder")
+	}
+	return term
+}
+
+func (p *parser) parseBool() *bool {
+	boolVal := strings.EqualFold(p.advance().text, "TRUE")
+	return &boolVal
+}
+
+// atTypedString returns whether the next tokens are a string written as
+// another type, e.g. `TIMESTAMP "2018-01-02T15:04:05Z"`. Otherwise the
+// type's name can be a column name.
+func (p *parser) atTypedString() bool {
+	return p.atKeyword("TIMESTAMP", "BYTES", "JSON") && p.peekAt(1).typ == stringToken
+}
+
+func (p *parser) parseTypedString() (*string, *string) {
+	typeName := strings.ToLower(p.advance().text)
+	str := p.advance().text
+	return &typeName, &str
+}
+
+// value expressions
+
+func (p *parser) parseValueExpr() *ValueExpr {
+	expr := &ValueExpr{Left: p.parseSum()}
+	for p.atOp("||") {
+		op := &ConcatOp{Pos: p.peek().pos, Op: p.advance().text}
+		op.Right = p.parseSum()
+		expr.Rest = append(expr.Rest, op)
+	}
+	return expr
+}
+
+func (p *parser) parseSum() *Sum {
+	sum := &Sum{Left: p.parseProduct()}
+	for p.atOp("+", "-") {
+		op := &SumOp{Pos: p.peek().pos, Op: p.advance().text}
+		op.Right = p.parseProduct()
+		sum.Rest = append(sum.Rest, op)
+	}
+	return sum
+}
+
+func (p *parser) parseProduct() *Product {
+	product := &Product{Left: p.parseFactor()}
+	for p.atOp("*", "/", "%") {
+		op := &ProductOp{Pos: p.peek().pos, Op: p.advance().text}
+		op.Right = p.parseFactor()
+		product.Rest = append(product.Rest, op)
+	}
+	return product
+}
+
+func (p *parser) parseFactor() *Factor {
+	factor := &Factor{Pos: p.peek().pos}
+	if p.acceptOp("(") {
+		factor.Parens = p.parseValueExpr()
+		p.expectOp(")")
+		return factor
+	}
+	factor.Term = p.parseTerm()
+	if p.acceptOp("(") {
+		factor.Call = &CallArgs{}
+		if !p.acceptOp(")") {
+			p.commaList(")", func() {
+				factor.Call.Args = append(factor.Call.Args, p.parseValueExpr())
+			})
+		}
+	}
+	return factor
+}
+
+// tokens
+
+func (p *parser) peek() token {
+	return p.peekAt(0)
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.idx+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1] // EOF
+	}
+	return p.tokens[p.idx+offset]
+}
+
+func (p *parser) advance() token {
+	tok := p.peek()
+	if tok.typ != eofToken {
+		p.idx++
+	}
+	return tok
+}
+
+func (p *parser) atKeyword(keywords ...string) bool {
+	tok := p.peek()
+	if tok.typ != wordToken {
+		return false
+	}
+	for _, keyword := range keywords {
+		if strings.EqualFold(tok.text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) acceptKeyword(keyword string) bool {
+	if p.atKeyword(keyword) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(keyword string) {
+	if !p.acceptKeyword(keyword) {
+		p.fail(keyword)
+	}
+}
+
+func (p *parser) atOp(ops ...string) bool {
+	tok := p.peek()
+	if tok.typ != operatorToken {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if p.atOp(op) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) {
+	if !p.acceptOp(op) {
+		p.fail(fmt.Sprintf("%q", op))
+	}
+}
+
+// expectWord returns the next word, which can be anything, keywords included.
+func (p *parser) expectWord(what string) string {
+	if p.peek().typ != wordToken {
+		p.fail(what)
+	}
+	return p.advance().text
+}
+
+// atSignedNumber is whether the next token is a number, or a sign
+// directly followed by one.
+func (p *parser) atSignedNumber() bool {
+	tok := p.peek()
+	if tok.typ == numberToken {
+		return true
+	}
+	next := p.peekAt(1)
+	return (tok.text == "-" || tok.text == "+") && tok.typ == operatorToken &&
+		next.typ == numberToken && next.pos.Offset == tok.pos.Offset+1
+}
+
+func (p *parser) expectInt() int {
+	start := p.idx
+	if p.atSignedNumber() {
+		if value, err := strconv.Atoi(p.signedNumber()); err == nil {
+			return value
+		}
+	}
+	p.idx = start
+	p.fail("a whole number")
+	return 0
+}
+
+func (p *parser) signedNumber() string {
+	number := p.advance()
+	if number.typ == operatorToken {
+		return number.text + p.advance().text
+	}
+	return number.text
+}
+
+// errors
+
+// fail records a syntax error at the next token and bails out.
+func (p *parser) fail(expected string) {
+	tok := p.peek()
+	message := fmt.Sprintf("expected %s; got %s", expected, describeToken(tok))
+	if tok.typ == invalidToken {
+		message = tok.text
+	}
+	// several failures at one spot are usually knock-on effects of the first
+	if len(p.errors) == 0 || p.errors[len(p.errors)-1].(*positionedError).pos != tok.pos {
+		p.errors = append(p.errors, errorAt(tok.pos, "", &SyntaxError{
+			Message: message,
+			Line:    tok.pos.Line,
+			Column:  tok.pos.Column,
+		}))
+	}
+	panic(bailout{})
+}
+
+func describeToken(tok token) string {
+	switch tok.typ {
+	case eofToken:
+		return "end of statement"
+	case stringToken:
+		return tok.raw
+	}
+	return fmt.Sprintf("%q", tok.text)
+}
+
+// commaList parses items separated by commas, up to and including close.
+func (p *parser) commaList(close string, item func()) {
+	for {
+		p.recoverTo([]string{",", close}, item)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	p.expectOp(close)
+}
+
+// recoverTo calls parse, and if it fails, skips ahead to the next of
+// stops which isn't nested in brackets, so that parsing can carry on.
+func (p *parser) recoverTo(stops []string, parse func()) {
+	defer p.recoverBailout(func() {
+		p.skipTo(stops)
+	})
+	parse()
+}
+
+func (p *parser) recoverBailout(then func()) {
+	if r := recover(); r != nil {
+		if _, ok := r.(bailout); !ok {
+			panic(r)
+		}
+		then()
+	}
+}
+
+func (p *parser) skipTo(stops []string) {
+	depth := 0
+	for tok := p.peek(); tok.typ != eofToken; tok = p.peek() {
+		if depth == 0 && (p.atOp(stops...) || p.atKeyword(stops...)) {
+			return
+		}
+		if tok.typ == operatorToken {
+			switch tok.text {
+			case "(", "{":
+				depth++
+			case ")", "}":
+				if depth == 0 {
+					return
+				}
+				depth--
+			}
+		}
+		p.advance()
+	}
 }
@@ -6,24 +6,145 @@ import (
 
 func TestParser(t *testing.T) {
 	testCases := []string{
-		`CREATETABLE blog_posts (id STRING PRIMARYKEY, title STRING, author_id STRING REFERENCESTABLE blog_posts)`,
+		`CREATE TABLE blog_posts (id STRING PRIMARY KEY, title STRING, author_id STRING REFERENCES blog_posts)`,
+
+		`CREATE TABLE users (id STRING PRIMARY KEY, name STRING NOT NULL, boss_id STRING NOT NULL REFERENCES users)`,
+		`CREATE TABLE comments (id STRING PRIMARY KEY, parent_id STRING REFERENCES comments, order STRING, references STRING)`,
+		`CREATE TABLE events (id SERIAL PRIMARY KEY, token STRING NOT NULL DEFAULT gen_uuid(), at TIMESTAMP DEFAULT now(), score INT DEFAULT 1 + 2 REFERENCES scores)`,
+		`CREATE TABLE blog_posts (id STRING PRIMARY KEY, author_id STRING REFERENCES users ON DELETE CASCADE ON UPDATE SET NULL)`,
+		`CREATE TABLE users (id SERIAL PRIMARY KEY, email STRING NOT NULL UNIQUE CHECK (email <> ""), first_name STRING, last_name STRING, UNIQUE (first_name, last_name), CHECK (first_name <> last_name OR last_name IS NULL))`,
+		`CREATE TABLE flags (id STRING PRIMARY KEY, unique BOOL, check STRING)`,
+
+		`CREATE INDEX ON comments (blog_post_id)`,
+
+		`DROP TABLE blog_posts`,
+		`DROP TABLE blog_posts CASCADE`,
+
+		`ALTER TABLE blog_posts ADD COLUMN author_id STRING REFERENCES users`,
+		`ALTER TABLE blog_posts ADD COLUMN body STRING NOT NULL DEFAULT ""`,
+		`ALTER TABLE blog_posts DROP COLUMN author_id`,
+		`ALTER TABLE blog_posts RENAME COLUMN body TO text`,
+		`ALTER TABLE blog_posts ADD REFERENCE author_id REFERENCES users`,
+		`ALTER TABLE blog_posts ADD REFERENCE author_id REFERENCES users ON DELETE RESTRICT`,
+		`ALTER TABLE blog_posts DROP REFERENCE author_id`,
 
 		`MANY blog_posts { id, body, comments: MANY comments { id, body } }`,
+		`LIVE MANY blog_posts { id, comments: MANY comments { id } }`,
+		`MANY __columns__ WHERE references IS NOT NULL { name, references }`,
+		`MANY blog_posts WHERE views > -1 { id, one: one, count: count + -1, comment_count: COUNT comments }`,
+		`MANY blog_posts { *, comments: MANY comments { * } }`,
+		`MANY users { id, name: first_name || " " || last_name, score: (upvotes - downvotes) * 2, title_text: title }`,
+		`MANY comments { id, replies: MANY comments VIA parent_id RECURSIVE MAXDEPTH 10 WHERE body <> "" { id } }`,
+		`MANY comments { id, parent: ONE comments RECURSIVE { id } }`,
+		`MANY users { id, sent: MANY messages VIA sender_id { id, recipient: ONE users VIA recipient_id { id } }, received: COUNT messages VIA recipient_id }`,
+		`MANY blog_posts { id, comment_count: COUNT comments, best: MAX comments.score WHERE body <> "" }`,
 		`ONE blog_posts WHERE id = "5" { id, title }`,
+		`MANY blog_posts WHERE (views > 5 OR title IS NOT NULL) AND NOT views BETWEEN 1 AND 3 { id }`,
+		`MANY blog_posts WHERE author_id <> "5" OR title IS NULL { id }`,
+		`MANY versions WHERE timestamp > TIMESTAMP "2018-01-02T15:04:05Z" AND public = FALSE { id, timestamp }`,
+		`MANY blog_posts WHERE id = $1 { id, comments: MANY comments WHERE body <> $2 { id } }`,
+		`MANY blog_posts ORDER BY title DESC LIMIT 20 OFFSET 40 { id, comments: MANY comments ORDER BY id LIMIT 5 { id } }`,
 
 		`UPDATE blog_posts SET title = "bloop" WHERE id = "5"`,
+		`UPDATE blog_posts SET title = $1 WHERE id = $2`,
+		`UPDATE blog_posts SET views = views + 1 WHERE id = "5" RETURNING { views, shouting: upper(title) }`,
+		`UPDATE blog_posts SET views = (views + 1) * 2, title = upper(title), body = lower(concat()) WHERE id = "5"`,
 
 		`INSERT INTO blog_posts VALUES ("5", "bloop_doop")`,
+		`INSERT INTO blog_posts VALUES ($1, "bloop_doop")`,
+		`INSERT INTO blog_posts VALUES ("6", -5, 10)`,
+		`INSERT INTO blog_posts VALUES ("7", NULL, $1)`,
+		`INSERT INTO events VALUES (0, TRUE, -1.5e3, TIMESTAMP "2018-01-02T15:04:05Z", BYTES "aGVsbG8=", JSON '{"a": 1}')`,
+		`INSERT INTO blog_posts (title, id) VALUES ("bloop", "5"), ("doop", "6")`,
+		`INSERT INTO blog_posts (title) VALUES ("bloop") RETURNING { id, author: ONE users { name } }`,
+		`INSERT INTO blog_posts VALUES ("5", "bloop") ON CONFLICT DO NOTHING`,
+		`INSERT INTO blog_posts VALUES ("5", "bloop", 1) ON CONFLICT DO UPDATE SET title = excluded.title, views = views + excluded.views RETURNING { views }`,
+
+		`DELETE FROM blog_posts WHERE id = "5"`,
+		`DELETE FROM blog_posts WHERE views < 1 RETURNING { *, comments: COUNT comments }`,
+		`DELETE FROM blog_posts WHERE views <= 10 AND title = "bloop"`,
 	}
 
 	for _, testCase := range testCases {
 		statement, err := Parse(testCase)
-		formatted := statement.Format()
 		if err != nil {
 			t.Fatal("expected it to parse; got error:", err)
 		}
+		formatted := statement.Format()
 		if formatted != testCase {
 			t.Fatalf(`parsed "%s" and it formatted back to "%s"`, testCase, formatted)
 		}
 	}
 }
+
+func TestParserErrors(t *testing.T) {
+	testCases := []struct {
+		stmt  string
+		error string
+	}{
+		{
+			`CREATETABLE blog_posts (id string PRIMARYKEY)`,
+			`1:1: expected MANY, ONE, LIVE, INSERT, UPDATE, DELETE, CREATE TABLE, CREATE INDEX, DROP TABLE or ALTER TABLE; got "CREATETABLE"`,
+		},
+		{
+			`CREATE INDEX comments (blog_post_id)`,
+			`1:14: expected ON; got "comments"`,
+		},
+		{
+			`ALTER TABLE blog_posts REMOVE COLUMN body`,
+			`1:24: expected ADD, DROP or RENAME; got "REMOVE"`,
+		},
+		{
+			`ALTER TABLE blog_posts ADD REFERENCE author_id REFERENCES users ON DELETE NOTHING`,
+			`1:75: expected CASCADE, SET NULL or RESTRICT; got "NOTHING"`,
+		},
+		{
+			`CREATE TABLE users (id STRING PRIMARY KEY, UNIQUE (id, ), name STRING)`,
+			`1:56: expected a column name; got ")"`,
+		},
+		{
+			`MANY blog_posts { id, title }`,
+			``,
+		},
+		{
+			`MANY blog_posts { id }  LIVE  x`,
+			`1:31: expected end of statement; got "x"`,
+		},
+		// Errors in list items are recovered from, to find more.
+		{
+			`MANY blog_posts { id, , title: ONE authors { 5 }, comments: MANY comments { # } }`,
+			`1:23: expected a column name or *; got ","; 1:46: expected a column name or *; got "5"; ` +
+				`1:77: invalid character '#'`,
+		},
+		{
+			`INSERT INTO blog_posts VALUES ("1", two), ("3", "unterminated)`,
+			`1:37: expected a value or placeholder; got "two"; 1:49: unterminated string; ` +
+				`1:63: expected ")"; got end of statement`,
+		},
+		{
+			`UPDATE blog_posts SET title = , views = views + WHERE id = "1"`,
+			`1:31: expected a column, value or placeholder; got ","; ` +
+				`1:49: expected a column, value or placeholder; got "WHERE"`,
+		},
+		{
+			`MANY blog_posts LIMIT 1.5 {`,
+			`1:23: expected a whole number; got "1.5"`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := Parse(testCase.stmt)
+		if testCase.error == "" {
+			if err != nil {
+				t.Fatalf("expected %s to parse; got error: %s", testCase.stmt, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Fatalf("expected %s not to parse", testCase.stmt)
+		}
+		if err.Error() != testCase.error {
+			t.Fatalf("expected %s to give error:\n%s\ngot:\n%s", testCase.stmt, testCase.error, err)
+		}
+	}
+}
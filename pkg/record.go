@@ -2,10 +2,15 @@ package treesql
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Record struct {
@@ -16,17 +21,162 @@ type Record struct {
 type Value struct {
 	// tagged union plz?
 	Type      ColumnType
-	StringVal string
+	StringVal string // also the text of JSON values
 	IntVal    int
+	BoolVal   bool
+	FloatVal  float64
+	TimeVal   time.Time // in UTC
+	BytesVal  []byte
+	Null      bool
 }
 
+// Compare returns -1, 0, or 1 depending on whether value is less than,
+// equal to, or greater than other. Numbers compare numerically, ints
+// with floats too; false comes before true; timestamps compare
+// chronologically; and strings, bytes and JSON compare by their text.
+// Comparing values of other different types is caught during validation.
+// Nulls come after everything else, so they're last in ascending order.
+func (value *Value) Compare(other *Value) int {
+	if value.Null || other.Null {
+		switch {
+		case value.Null && other.Null:
+			return 0
+		case value.Null:
+			return 1
+		default:
+			return -1
+		}
+	}
+	if value.Type == TypeFloat || other.Type == TypeFloat {
+		return compareFloats(value.float(), other.float())
+	}
+	switch value.Type {
+	case TypeInt:
+		if value.IntVal < other.IntVal {
+			return -1
+		}
+		if value.IntVal > other.IntVal {
+			return 1
+		}
+		return 0
+	case TypeBool:
+		if value.BoolVal == other.BoolVal {
+			return 0
+		}
+		if other.BoolVal {
+			return -1
+		}
+		return 1
+	case TypeTimestamp:
+		if value.TimeVal.Before(other.TimeVal) {
+			return -1
+		}
+		if value.TimeVal.After(other.TimeVal) {
+			return 1
+		}
+		return 0
+	case TypeBytes:
+		return bytes.Compare(value.BytesVal, other.BytesVal)
+	}
+	return strings.Compare(value.StringVal, other.StringVal)
+}
+
+// float returns a number value as a float.
+func (value *Value) float() float64 {
+	if value.Type == TypeInt {
+		return float64(value.IntVal)
+	}
+	return value.FloatVal
+}
+
+func compareFloats(left float64, right float64) int {
+	if left < right {
+		return -1
+	}
+	if left > right {
+		return 1
+	}
+	return 0
+}
+
+// jsonValue returns the value as it should appear in results.
+// A nil value is treated as NULL.
+func (value *Value) jsonValue() interface{} {
+	if value == nil || value.Null {
+		return nil
+	}
+	switch value.Type {
+	case TypeInt:
+		return value.IntVal
+	case TypeBool:
+		return value.BoolVal
+	case TypeFloat:
+		return value.FloatVal
+	case TypeTimestamp:
+		return value.TimeVal.Format(time.RFC3339Nano)
+	case TypeBytes:
+		return value.BytesVal // base64
+	case TypeJSON:
+		return json.RawMessage(value.StringVal)
+	}
+	return value.StringVal
+}
+
+// key returns the value as it identifies records, e.g. as a Bolt key,
+// in query paths, and in listener maps: strings, bytes and JSON as they
+// are, numbers and bools as they're written, and timestamps in RFC 3339.
+// Nulls don't identify anything, so callers check for them first.
+func (value *Value) key() string {
+	switch value.Type {
+	case TypeInt:
+		return strconv.Itoa(value.IntVal)
+	case TypeBool:
+		return strconv.FormatBool(value.BoolVal)
+	case TypeFloat:
+		return formatFloat(value.FloatVal)
+	case TypeTimestamp:
+		return value.TimeVal.Format(time.RFC3339Nano)
+	case TypeBytes:
+		return string(value.BytesVal)
+	}
+	return value.StringVal
+}
+
+// keyValue is the inverse of Value.key, for a value of the given type.
+func keyValue(columnType ColumnType, key string) *Value {
+	switch columnType {
+	case TypeInt:
+		intVal, _ := strconv.Atoi(key)
+		return &Value{Type: TypeInt, IntVal: intVal}
+	case TypeBool:
+		return &Value{Type: TypeBool, BoolVal: key == "true"}
+	case TypeFloat:
+		floatVal, _ := strconv.ParseFloat(key, 64)
+		return &Value{Type: TypeFloat, FloatVal: floatVal}
+	case TypeTimestamp:
+		timeVal, _ := time.Parse(time.RFC3339Nano, key)
+		return &Value{Type: TypeTimestamp, TimeVal: timeVal.UTC()}
+	case TypeBytes:
+		return &Value{Type: TypeBytes, BytesVal: []byte(key)}
+	}
+	return &Value{Type: columnType, StringVal: key}
+}
+
+// NewRecord returns a record for the table whose values are all null.
 func (table *TableDescriptor) NewRecord() *Record {
-	return &Record{
+	record := &Record{
 		Table:  table,
 		Values: make([]Value, len(table.Columns)),
 	}
+	for idx, column := range table.Columns {
+		record.Values[idx] = Value{Type: column.Type, Null: true}
+	}
+	return record
 }
 
+// nullFlag is set on a value's type byte when it's null; no payload follows.
+const nullFlag = 0x80
+
 func (table *TableDescriptor) RecordFromBytes(raw []byte) *Record {
 	record := &Record{
 		Table:  table,
@@ -34,21 +184,53 @@ func (table *TableDescriptor) RecordFromBytes(raw []byte) *Record {
 	}
 	buffer := bytes.NewBuffer(raw)
 	for valueIdx := 0; valueIdx < len(table.Columns); valueIdx++ {
-		typeCode, _ := buffer.ReadByte()
+		typeCode, err := buffer.ReadByte()
+		if err != nil || typeCode&nullFlag != 0 {
+			// records written before the column was added end early
+			record.Values[valueIdx] = Value{
+				Type: table.Columns[valueIdx].Type,
+				Null: true,
+			}
+			continue
+		}
 		switch ColumnType(typeCode) {
-		case TypeString:
+		case TypeString, TypeJSON:
 			length, _ := readInteger(buffer)
 			stringBytes := make([]byte, length)
 			buffer.Read(stringBytes)
 			record.Values[valueIdx] = Value{
-				Type:      TypeString,
+				Type:      ColumnType(typeCode),
 				StringVal: string(stringBytes),
 			}
 		case TypeInt:
 			val, _ := readInteger(buffer)
 			record.Values[valueIdx] = Value{
 				Type:   TypeInt,
-				IntVal: val,
+				IntVal: int(int32(val)),
+			}
+		case TypeBool:
+			boolByte, _ := buffer.ReadByte()
+			record.Values[valueIdx] = Value{
+				Type:    TypeBool,
+				BoolVal: boolByte != 0,
+			}
+		case TypeFloat:
+			record.Values[valueIdx] = Value{
+				Type:     TypeFloat,
+				FloatVal: math.Float64frombits(readUint64(buffer)),
+			}
+		case TypeTimestamp:
+			record.Values[valueIdx] = Value{
+				Type:    TypeTimestamp,
+				TimeVal: time.Unix(0, int64(readUint64(buffer))).UTC(),
+			}
+		case TypeBytes:
+			length, _ := readInteger(buffer)
+			bytesVal := make([]byte, length)
+			buffer.Read(bytesVal)
+			record.Values[valueIdx] = Value{
+				Type:     TypeBytes,
+				BytesVal: bytesVal,
 			}
 		}
 	}
@@ -74,8 +256,10 @@ func (record *Record) SetString(name string, value string) {
 	if idx == -1 {
 		log.Fatalln("field not found for table", record.Table.Name, ":", name)
 	}
-	record.Values[idx].Type = TypeString
-	record.Values[idx].StringVal = value
+	record.Values[idx] = Value{
+		Type:      TypeString,
+		StringVal: value,
+	}
 }
 
 func (record *Record) SetInt(name string, value int) {
@@ -83,8 +267,30 @@ func (record *Record) SetInt(name string, value int) {
 	if idx == -1 {
 		log.Fatalln("field not found for table", record.Table.Name, ":", name)
 	}
-	record.Values[idx].Type = TypeString
-	record.Values[idx].IntVal = value
+	record.Values[idx] = Value{
+		Type:   TypeInt,
+		IntVal: value,
+	}
+}
+
+func (record *Record) SetValue(name string, value *Value) {
+	idx := record.fieldIndex(name)
+	if idx == -1 {
+		log.Fatalln("field not found for table", record.Table.Name, ":", name)
+	}
+	record.Values[idx] = *value
+	columnType := record.Table.Columns[idx].Type
+	if value.Null {
+		// NULL terms don't have a type of their own
+		record.Values[idx].Type = columnType
+	} else if value.Type == TypeInt && columnType == TypeFloat {
+		record.Values[idx] = Value{Type: TypeFloat, FloatVal: float64(value.IntVal)}
+	}
+}
+
+// primaryKey returns the key of the record's primary key value.
+func (record *Record) primaryKey() string {
+	return record.GetField(record.Table.PrimaryKey).key()
 }
 
 func (record *Record) fieldIndex(name string) int {
@@ -101,14 +307,31 @@ func (record *Record) fieldIndex(name string) int {
 func (record *Record) ToBytes() []byte {
 	buf := new(bytes.Buffer)
 	for idx, column := range record.Table.Columns {
-		buf.Write([]byte{byte(column.Type)})
 		value := record.Values[idx]
+		if value.Null {
+			buf.Write([]byte{byte(column.Type) | nullFlag})
+			continue
+		}
+		buf.Write([]byte{byte(column.Type)})
 		switch column.Type {
 		case TypeInt:
 			WriteInteger(buf, value.IntVal)
-		case TypeString:
+		case TypeString, TypeJSON:
 			WriteInteger(buf, len(value.StringVal))
 			buf.WriteString(value.StringVal)
+		case TypeBool:
+			if value.BoolVal {
+				buf.WriteByte(1)
+			} else {
+				buf.WriteByte(0)
+			}
+		case TypeFloat:
+			writeUint64(buf, math.Float64bits(value.FloatVal))
+		case TypeTimestamp:
+			writeUint64(buf, uint64(value.TimeVal.UnixNano()))
+		case TypeBytes:
+			WriteInteger(buf, len(value.BytesVal))
+			buf.Write(value.BytesVal)
 		}
 	}
 	return buf.Bytes()
@@ -120,7 +343,11 @@ func (record *Record) MarshalJSON() ([]byte, error) {
 		if idx > 0 {
 			out += ","
 		}
-		out += fmt.Sprintf("%s:%s", strconv.Quote(column.Name), strconv.Quote(record.GetField(column.Name).StringVal))
+		value, err := json.Marshal(record.Values[idx].jsonValue())
+		if err != nil {
+			return nil, err
+		}
+		out += fmt.Sprintf("%s:%s", strconv.Quote(column.Name), value)
 	}
 	out += "}"
 	return []byte(out), nil
@@ -130,7 +357,82 @@ func (record *Record) Clone() *Record {
 	return record.Table.RecordFromBytes(record.ToBytes())
 }
 
-// these are only uints
+// Ints are stored in 32 bits.
+const (
+	MinInt = math.MinInt32
+	MaxInt = math.MaxInt32
+)
+
+// parseInt parses an int literal, returning false if it isn't a whole
+// number or doesn't fit in an int.
+func parseInt(number string) (int, bool) {
+	intVal, err := strconv.ParseInt(number, 10, 32)
+	return int(intVal), err == nil
+}
+
+// numberValue returns the value of a number literal: an int if it's
+// written as a whole number, e.g. `5`, and otherwise a float, e.g.
+// `5.0` or `5e3`.
+func numberValue(number string) (*Value, error) {
+	if !strings.ContainsAny(number, ".eE") {
+		intVal, ok := parseInt(number)
+		if !ok {
+			return nil, &InvalidInt{Number: number}
+		}
+		return &Value{Type: TypeInt, IntVal: intVal}, nil
+	}
+	floatVal, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return nil, &InvalidValue{Type: TypeFloat, Text: number}
+	}
+	return &Value{Type: TypeFloat, FloatVal: floatVal}, nil
+}
+
+// formatFloat writes a float so that it reads back as one, e.g. `5.0`
+// rather than `5`.
+func formatFloat(floatVal float64) string {
+	number := strconv.FormatFloat(floatVal, 'g', -1, 64)
+	if !strings.ContainsAny(number, ".e") {
+		number += ".0"
+	}
+	return number
+}
+
+// writtenAsString returns whether values of the type are written as
+// strings, e.g. `TIMESTAMP "2018-01-02T15:04:05Z"`. Plain strings are
+// read as the type where a value of it is expected.
+func writtenAsString(columnType ColumnType) bool {
+	return columnType == TypeTimestamp || columnType == TypeBytes || columnType == TypeJSON
+}
+
+// stringValue returns the value of a string literal of the given type:
+// timestamps are written in RFC 3339, bytes in base64, and JSON as itself.
+func stringValue(columnType ColumnType, str string) (*Value, error) {
+	switch columnType {
+	case TypeTimestamp:
+		timeVal, err := time.Parse(time.RFC3339Nano, str)
+		// timestamps are stored in nanoseconds, which only go from 1678 to 2262
+		if err != nil || !time.Unix(0, timeVal.UnixNano()).Equal(timeVal) {
+			return nil, &InvalidValue{Type: TypeTimestamp, Text: str}
+		}
+		return &Value{Type: TypeTimestamp, TimeVal: timeVal.UTC()}, nil
+	case TypeBytes:
+		bytesVal, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			return nil, &InvalidValue{Type: TypeBytes, Text: str}
+		}
+		return &Value{Type: TypeBytes, BytesVal: bytesVal}, nil
+	case TypeJSON:
+		compacted := &bytes.Buffer{}
+		if err := json.Compact(compacted, []byte(str)); err != nil {
+			return nil, &InvalidValue{Type: TypeJSON, Text: str}
+		}
+		return &Value{Type: TypeJSON, StringVal: compacted.String()}, nil
+	}
+	return &Value{Type: TypeString, StringVal: str}, nil
+}
+
+// these are only uints; ints are stored as their two's complement
 func readInteger(buffer *bytes.Buffer) (int, error) {
 	bytes := make([]byte, 4)
 	buffer.Read(bytes)
@@ -143,3 +445,16 @@ func WriteInteger(buf *bytes.Buffer, val int) {
 	binary.BigEndian.PutUint32(intBytes, uint32(val))
 	buf.Write(intBytes)
 }
+
+// floats and timestamps take 64 bits
+func readUint64(buffer *bytes.Buffer) uint64 {
+	longBytes := make([]byte, 8)
+	buffer.Read(longBytes)
+	return binary.BigEndian.Uint64(longBytes)
+}
+
+func writeUint64(buf *bytes.Buffer, val uint64) {
+	longBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(longBytes, val)
+	buf.Write(longBytes)
+}
@@ -0,0 +1,61 @@
+package treesql
+
+// withRecursion returns the selections for a record of a recursive
+// selection: its own selections, plus the recursive selection again one
+// level down, unless that would go past MAXDEPTH or around a cycle.
+func (ex *SelectExecution) withRecursion(query *Select, scope *Scope, record *Record) []*Selection {
+	next := query.nextLevel()
+	if next == nil {
+		return query.Selections
+	}
+	// The path of a recursive selection ends in its name; this is also
+	// true when re-fetching records for a table listener, where scope is nil.
+	path := ex.pathSoFar(scope)
+	name := *path.Selection
+	pk := record.primaryKey()
+	if recursionVisited(path, name, pk) {
+		return query.Selections
+	}
+	selections := make([]*Selection, len(query.Selections), len(query.Selections)+1)
+	copy(selections, query.Selections)
+	return append(selections, &Selection{
+		Name:      name,
+		SubSelect: next,
+	})
+}
+
+// nextLevel returns the selection to nest inside this one,
+// or nil if this is the last level.
+func (query *Select) nextLevel() *Select {
+	if query.MaxDepth == nil {
+		return query
+	}
+	if *query.MaxDepth <= 1 {
+		return nil
+	}
+	next := *query
+	maxDepth := *query.MaxDepth - 1
+	next.MaxDepth = &maxDepth
+	return &next
+}
+
+// recursionVisited returns whether the record with primary key pk is
+// already an ancestor of path in the chain of recursive selections named
+// name, i.e. whether nesting it again would loop forever.
+func recursionVisited(path *QueryPath, name string, pk string) bool {
+	for segment := path; segment != nil; {
+		// segment is a selection; the one before it is the record it's in
+		if segment.Selection == nil || *segment.Selection != name {
+			return false
+		}
+		record := segment.PreviousSegment
+		if record == nil || record.ID == nil {
+			return false
+		}
+		if *record.ID == pk {
+			return true
+		}
+		segment = record.PreviousSegment
+	}
+	return false
+}
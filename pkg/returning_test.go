@@ -0,0 +1,111 @@
+package treesql
+
+import (
+	"testing"
+)
+
+func TestReturning(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE users (id string PRIMARY KEY, name string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE blog_posts (id serial PRIMARY KEY, author_id string REFERENCES users, title string, views int DEFAULT 0)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO users VALUES ("0", "pete")`,
+			ack:  "INSERT 1",
+		},
+		// Verify that the selection is checked.
+		{
+			query: `INSERT INTO blog_posts (author_id, title) VALUES ("0", "hello world") RETURNING { id, body }`,
+			error: "validation error: no such column in table blog_posts: body",
+		},
+		{
+			query: `DELETE FROM blog_posts WHERE id = 1 RETURNING { id, comments: MANY comments { id } }`,
+			error: "validation error: no such table: comments",
+		},
+		// Happy path: writes answer with the records they wrote, selected
+		// like a query, including generated values and nested selections.
+		{
+			query: `INSERT INTO blog_posts (author_id, title) VALUES ("0", "hello world"), (NULL, "hello again world") RETURNING { id, views, author: ONE users { name } }`,
+			initialResult: `[
+  {
+    "author": [
+      {
+        "name": "pete"
+      }
+    ],
+    "id": 1,
+    "views": 0
+  },
+  {
+    "author": null,
+    "id": 2,
+    "views": 0
+  }
+]`,
+		},
+		{
+			query: `UPDATE blog_posts SET views = views + 5 WHERE views = 0 RETURNING { id, views, shouting: upper(title) }`,
+			initialResult: `[
+  {
+    "id": 1,
+    "shouting": "HELLO WORLD",
+    "views": 5
+  },
+  {
+    "id": 2,
+    "shouting": "HELLO AGAIN WORLD",
+    "views": 5
+  }
+]`,
+		},
+		// Placeholders can be used in the selection too.
+		{
+			query: `UPDATE users SET name = $1 WHERE id = $2 RETURNING { id, posts: MANY blog_posts WHERE title = $3 { id } }`,
+			args:  []interface{}{"pete", "0", "hello world"},
+			initialResult: `[
+  {
+    "id": "0",
+    "posts": [
+      {
+        "id": 1
+      }
+    ]
+  }
+]`,
+		},
+		{
+			query:         `UPDATE blog_posts SET views = 0 WHERE id = 3 RETURNING { id }`,
+			initialResult: `[]`,
+		},
+		{
+			query: `DELETE FROM blog_posts WHERE author_id = "0" RETURNING { * }`,
+			initialResult: `[
+  {
+    "author_id": "0",
+    "id": 1,
+    "title": "hello world",
+    "views": 5
+  }
+]`,
+		},
+		{
+			query: `MANY users { name, posts: COUNT blog_posts }`,
+			initialResult: `[
+  {
+    "name": "pete",
+    "posts": 0
+  }
+]`,
+		},
+		// Writes without RETURNING are still acked.
+		{
+			stmt: `DELETE FROM blog_posts WHERE id = 2`,
+			ack:  "DELETE 1",
+		},
+	})
+}
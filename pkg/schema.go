@@ -3,7 +3,10 @@ package treesql
 import (
 	"encoding/binary"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/boltdb/bolt"
 )
@@ -11,6 +14,8 @@ import (
 type Schema struct {
 	Tables       map[string]*TableDescriptor
 	NextColumnID int
+
+	tablesMu sync.Mutex // held while adding, replacing or removing tables
 }
 
 // TODO: better name, or refactor. not just a descriptor, since
@@ -19,6 +24,7 @@ type TableDescriptor struct {
 	Name          string
 	Columns       []*ColumnDescriptor
 	PrimaryKey    string
+	Constraints   []*Constraint
 	LiveQueryInfo *LiveQueryInfo
 }
 
@@ -27,27 +33,159 @@ type ColumnDescriptor struct {
 	ID               int
 	Name             string
 	Type             ColumnType
+	NotNull          bool
+	Default          *ValueExpr // evaluated for inserts which leave the column out
+	Serial           bool       // numbered 1, 2, 3... from a sequence, for inserts which leave it out
 	ReferencesColumn *ColumnReference
+	Indexed          bool // records are looked up by it in an index, rather than by scanning the table
 }
 
 type ColumnReference struct {
 	TableName string // we're gonna assume for now that you can only reference the primary key
+	OnDelete  ReferenceAction
+	OnUpdate  ReferenceAction // when the referenced record's primary key changes
+}
+
+// ReferenceAction is what happens to the records referencing a record
+// when it's deleted, or its primary key changes.
+type ReferenceAction string
+
+const (
+	Restrict ReferenceAction = "RESTRICT" // the delete or update fails
+	Cascade  ReferenceAction = "CASCADE"  // they're deleted too, or updated to the new key
+	SetNull  ReferenceAction = "SET NULL"
+)
+
+// newColumnReference describes a reference to the given table, with
+// RESTRICT for actions which weren't given.
+func newColumnReference(tableName string, onDelete ReferenceAction, onUpdate ReferenceAction) *ColumnReference {
+	if onDelete == "" {
+		onDelete = Restrict
+	}
+	if onUpdate == "" {
+		onUpdate = Restrict
+	}
+	return &ColumnReference{
+		TableName: tableName,
+		OnDelete:  onDelete,
+		OnUpdate:  onUpdate,
+	}
+}
+
+// Constraint is a UNIQUE or CHECK constraint on a table's records;
+// exactly one of Unique and Check is set.
+type Constraint struct {
+	Name   string   // unique among all tables' constraints, e.g. users_email_key
+	Unique []string // column names; no two records have the same values in them, unless one is null
+	Check  *Expr    // records satisfy it, or it involves a null
 }
 
 // maybe I should use that iota weirdness
 type ColumnType byte
 
+// These are stored in records, so they shouldn't change.
 const TypeString ColumnType = 0
 const TypeInt ColumnType = 1
+const TypeBool ColumnType = 2
+const TypeFloat ColumnType = 3
+const TypeTimestamp ColumnType = 4
+const TypeBytes ColumnType = 5
+const TypeJSON ColumnType = 6
 
 var TypeToName = map[ColumnType]string{
-	TypeString: "string",
-	TypeInt:    "int",
+	TypeString:    "string",
+	TypeInt:       "int",
+	TypeBool:      "bool",
+	TypeFloat:     "float",
+	TypeTimestamp: "timestamp",
+	TypeBytes:     "bytes",
+	TypeJSON:      "json",
 }
 
+// serialTypeName declares an int column numbered from a sequence.
+const serialTypeName = "serial"
+
 var NameToType = map[string]ColumnType{
-	"string": TypeString,
-	"int":    TypeInt,
+	"string":    TypeString,
+	"int":       TypeInt,
+	"bool":      TypeBool,
+	"float":     TypeFloat,
+	"timestamp": TypeTimestamp,
+	"bytes":     TypeBytes,
+	"json":      TypeJSON,
+}
+
+// columnTypeOf returns the type of a column declared with the given
+// type name, and whether there is one.
+func columnTypeOf(typeName string) (ColumnType, bool) {
+	if typeName == serialTypeName {
+		return TypeInt, true
+	}
+	columnType, ok := NameToType[typeName]
+	return columnType, ok
+}
+
+// assignable returns whether a value of the given type can be stored
+// in a column of another: ints can go in float columns.
+func assignable(valueType ColumnType, columnType ColumnType) bool {
+	return valueType == columnType || (valueType == TypeInt && columnType == TypeFloat)
+}
+
+// comparableTypes returns whether values of the two types can be compared:
+// ints can be compared with floats.
+func comparableTypes(left ColumnType, right ColumnType) bool {
+	return left == right || (isNumeric(left) && isNumeric(right))
+}
+
+func isNumeric(columnType ColumnType) bool {
+	return columnType == TypeInt || columnType == TypeFloat
+}
+
+// MarshalText writes types by name, e.g. in errors sent to clients.
+func (columnType ColumnType) MarshalText() ([]byte, error) {
+	return []byte(TypeToName[columnType]), nil
+}
+
+func (columnType *ColumnType) UnmarshalText(text []byte) error {
+	parsed, ok := NameToType[string(text)]
+	if !ok {
+		return &NonexistentType{TypeName: string(text)}
+	}
+	*columnType = parsed
+	return nil
+}
+
+func (table *TableDescriptor) getColumn(name string) *ColumnDescriptor {
+	for _, column := range table.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+// notNull returns whether the column can't be null: it's
+// declared NOT NULL, or it's the primary key.
+func (table *TableDescriptor) notNull(column *ColumnDescriptor) bool {
+	return column.NotNull || column.Name == table.PrimaryKey
+}
+
+// generated returns whether inserts which leave the column out get a
+// value for it anyway.
+func (column *ColumnDescriptor) generated() bool {
+	return column.Default != nil || column.Serial
+}
+
+// referencesTo returns the names of this table's columns
+// which reference the given table.
+func (table *TableDescriptor) referencesTo(tableName string) []string {
+	var columnNames []string
+	for _, column := range table.Columns {
+		if column.ReferencesColumn != nil && column.ReferencesColumn.TableName == tableName {
+			columnNames = append(columnNames, column.Name)
+		}
+	}
+	return columnNames
 }
 
 func (column *ColumnDescriptor) ToRecord(tableName string, db *Database) *Record {
@@ -57,27 +195,87 @@ func (column *ColumnDescriptor) ToRecord(tableName string, db *Database) *Record
 	record.SetString("name", column.Name)
 	record.SetString("table_name", tableName)
 	record.SetString("type", TypeToName[column.Type])
+	record.SetValue("not_null", &Value{Type: TypeBool, BoolVal: column.NotNull})
+	if column.Default != nil {
+		record.SetString("default", column.Default.Format())
+	}
+	record.SetValue("serial", &Value{Type: TypeBool, BoolVal: column.Serial})
 	if column.ReferencesColumn != nil {
 		record.SetString("references", column.ReferencesColumn.TableName)
+		// builtin tables' references have no actions, since they're never written to
+		if column.ReferencesColumn.OnDelete != "" {
+			record.SetString("on_delete", string(column.ReferencesColumn.OnDelete))
+			record.SetString("on_update", string(column.ReferencesColumn.OnUpdate))
+		}
 	}
+	record.SetValue("indexed", &Value{Type: TypeBool, BoolVal: column.Indexed})
 	return record
 }
 
 func ColumnFromRecord(record *Record) *ColumnDescriptor {
 	idInt, _ := strconv.Atoi(record.GetField("id").StringVal)
-	references := record.GetField("references").StringVal
+	references := record.GetField("references")
+	// written as "true" or "false" before there were bools
+	notNull := record.GetField("not_null")
 	var columnReference *ColumnReference
-	if len(references) > 0 { // should things be nullable? idk
-		columnReference = &ColumnReference{
-			TableName: references,
-		}
+	// columns written before there were nulls have "" for no reference, and
+	// those written before there were reference actions get RESTRICT
+	if !references.Null && references.StringVal != "" {
+		columnReference = newColumnReference(
+			references.StringVal,
+			ReferenceAction(record.GetField("on_delete").StringVal),
+			ReferenceAction(record.GetField("on_update").StringVal),
+		)
+	}
+	var defaultExpr *ValueExpr
+	if defaultText := record.GetField("default"); !defaultText.Null {
+		// it was formatted from a parsed expression, so it parses
+		defaultExpr, _ = ParseValueExpr(defaultText.StringVal)
 	}
 	return &ColumnDescriptor{
 		ID:               idInt,
 		Name:             record.GetField("name").StringVal,
 		Type:             NameToType[record.GetField("type").StringVal],
+		NotNull:          notNull.BoolVal || notNull.StringVal == "true",
+		Default:          defaultExpr,
+		Serial:           record.GetField("serial").BoolVal,
 		ReferencesColumn: columnReference,
+		Indexed:          record.GetField("indexed").BoolVal,
+	}
+}
+
+func (constraint *Constraint) ToRecord(tableName string, db *Database) *Record {
+	record := db.Schema.Tables["__constraints__"].NewRecord()
+	record.SetString("name", constraint.Name)
+	record.SetString("table_name", tableName)
+	if constraint.Check != nil {
+		record.SetString("type", "CHECK")
+		record.SetString("check", constraint.Check.Format())
+	} else {
+		record.SetString("type", "UNIQUE")
+		record.SetString("columns", strings.Join(constraint.Unique, ", "))
+	}
+	return record
+}
+
+func ConstraintFromRecord(record *Record) *Constraint {
+	constraint := &Constraint{Name: record.GetField("name").StringVal}
+	if check := record.GetField("check"); !check.Null {
+		// it was formatted from a parsed expression, so it parses
+		constraint.Check, _ = ParseExpr(check.StringVal)
+	} else {
+		constraint.Unique = strings.Split(record.GetField("columns").StringVal, ", ")
 	}
+	return constraint
+}
+
+// IndexToRecord describes an indexed column's index, as a row of __indexes__.
+func (column *ColumnDescriptor) IndexToRecord(tableName string, db *Database) *Record {
+	record := db.Schema.Tables["__indexes__"].NewRecord()
+	record.SetString("id", fmt.Sprintf("%d", column.ID))
+	record.SetString("table_name", tableName)
+	record.SetString("column_name", column.Name)
+	return record
 }
 
 func (table *TableDescriptor) ToRecord(db *Database) *Record {
@@ -99,6 +297,7 @@ func (db *Database) EnsureBuiltinSchema() {
 	db.BoltDB.Update(func(tx *bolt.Tx) error {
 		tx.CreateBucketIfNotExists([]byte("__tables__"))
 		tx.CreateBucketIfNotExists([]byte("__columns__"))
+		tx.CreateBucketIfNotExists([]byte("__constraints__"))
 		sequencesBucket, _ := tx.CreateBucketIfNotExists([]byte("__sequences__"))
 		// sync next column id
 		nextColumnIDBytes := sequencesBucket.Get([]byte("__next_column_id__"))
@@ -116,9 +315,71 @@ func (db *Database) EnsureBuiltinSchema() {
 	})
 }
 
+// saveNextColumnID writes the next column id sequence, after
+// columns have been added.
+func (db *Database) saveNextColumnID(tx *bolt.Tx) error {
+	nextColumnIDBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(nextColumnIDBytes, uint32(db.Schema.NextColumnID))
+	return tx.Bucket([]byte("__sequences__")).Put([]byte("__next_column_id__"), nextColumnIDBytes)
+}
+
+// serialKey is the key of a serial column's sequence in __sequences__.
+func serialKey(column *ColumnDescriptor) []byte {
+	return []byte(fmt.Sprintf("__serial_%d__", column.ID))
+}
+
+// nextSerial returns the next value of a serial column, starting at 1.
+func nextSerial(tx *bolt.Tx, column *ColumnDescriptor) (int, error) {
+	sequencesBucket := tx.Bucket([]byte("__sequences__"))
+	next := uint32(1)
+	if currentBytes := sequencesBucket.Get(serialKey(column)); currentBytes != nil {
+		next = binary.BigEndian.Uint32(currentBytes) + 1
+	}
+	if int64(next) > MaxInt {
+		return 0, &SequenceExhausted{ColumnName: column.Name}
+	}
+	nextBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(nextBytes, next)
+	if err := sequencesBucket.Put(serialKey(column), nextBytes); err != nil {
+		return 0, err
+	}
+	return int(next), nil
+}
+
+// dropSerial removes a serial column's sequence, when the column is dropped.
+func dropSerial(tx *bolt.Tx, column *ColumnDescriptor) error {
+	if !column.Serial {
+		return nil
+	}
+	return tx.Bucket([]byte("__sequences__")).Delete(serialKey(column))
+}
+
+// generateValue returns the value a record which doesn't give one gets for
+// the column: its default, or the next in its sequence.
+func generateValue(tx *bolt.Tx, table *TableDescriptor, column *ColumnDescriptor) (*Value, error) {
+	var value *Value
+	if column.Serial {
+		next, err := nextSerial(tx, column)
+		if err != nil {
+			return nil, err
+		}
+		value = &Value{Type: TypeInt, IntVal: next}
+	} else {
+		var err error
+		if value, err = column.Default.evaluate(nil); err != nil {
+			return nil, err
+		}
+	}
+	if value.Null && table.notNull(column) {
+		return nil, &NullViolation{TableName: table.Name, ColumnName: column.Name}
+	}
+	return value, nil
+}
+
 func (db *Database) LoadUserSchema() {
 	tablesTable := db.Schema.Tables["__tables__"]
 	columnsTable := db.Schema.Tables["__columns__"]
+	constraintsTable := db.Schema.Tables["__constraints__"]
 	db.BoltDB.View(func(tx *bolt.Tx) error {
 		tables := map[string]*TableDescriptor{}
 		tx.Bucket([]byte("__tables__")).ForEach(func(_ []byte, tableBytes []byte) error {
@@ -138,6 +399,20 @@ func (db *Database) LoadUserSchema() {
 			tableSpec.Columns = append(tableSpec.Columns, columnSpec)
 			return nil
 		})
+		tx.Bucket([]byte("__constraints__")).ForEach(func(_ []byte, constraintBytes []byte) error {
+			constraintRecord := constraintsTable.RecordFromBytes(constraintBytes)
+			tableSpec := tables[constraintRecord.GetField("table_name").StringVal]
+			tableSpec.Constraints = append(tableSpec.Constraints, ConstraintFromRecord(constraintRecord))
+			return nil
+		})
+		// Records are encoded in column order, which is the order columns
+		// were added in; the keys are IDs as strings, so "10" comes before "9".
+		for _, tableSpec := range tables {
+			columns := tableSpec.Columns
+			sort.Slice(columns, func(i, j int) bool {
+				return columns[i].ID < columns[j].ID
+			})
+		}
 		return nil
 	})
 }
@@ -149,7 +424,9 @@ func (db *Database) AddTable(name string, primaryKey string, columns []*ColumnDe
 		Columns:    columns,
 	}
 	table.LiveQueryInfo = table.NewLiveQueryInfo() // def something weird about this
+	db.Schema.tablesMu.Lock()
 	db.Schema.Tables[name] = table
+	db.Schema.tablesMu.Unlock()
 	go table.HandleEvents()
 	return table
 }
@@ -160,9 +437,21 @@ func EmptySchema() *Schema {
 	}
 }
 
+// isBuiltinTable returns whether a table is one of the builtin ones,
+// which describe the database and can't be written to directly.
+func isBuiltinTable(tableName string) bool {
+	switch tableName {
+	case "__tables__", "__columns__", "__constraints__", "__indexes__", "__record_listeners__":
+		return true
+	}
+	return false
+}
+
 func (db *Database) AddBuiltinSchema() {
 	// these never go in the on-disk __tables__ and __columns__ Bolt buckets
 	// doing ids like this is kind of precarious...
+	// Columns added to them after the first 13 have negative IDs, since
+	// user columns in existing data files have IDs from 13 up.
 	db.AddTable("__tables__", "name", []*ColumnDescriptor{
 		{
 			ID:   0,
@@ -204,6 +493,87 @@ func (db *Database) AddBuiltinSchema() {
 			Name: "references", // TODO: this is a keyword. rename to "references_table"
 			Type: TypeString,
 		},
+		{
+			ID:   -1,
+			Name: "not_null",
+			Type: TypeBool,
+		},
+		{
+			ID:   -2,
+			Name: "default",
+			Type: TypeString,
+		},
+		{
+			ID:   -3,
+			Name: "serial",
+			Type: TypeBool,
+		},
+		{
+			ID:   -4,
+			Name: "on_delete",
+			Type: TypeString,
+		},
+		{
+			ID:   -5,
+			Name: "on_update",
+			Type: TypeString,
+		},
+		{
+			ID:   -11,
+			Name: "indexed",
+			Type: TypeBool,
+		},
+	})
+	db.AddTable("__constraints__", "name", []*ColumnDescriptor{
+		{
+			ID:   -6,
+			Name: "name",
+			Type: TypeString,
+		},
+		{
+			ID:   -7,
+			Name: "table_name",
+			Type: TypeString,
+			ReferencesColumn: &ColumnReference{
+				TableName: "__tables__",
+			},
+		},
+		{
+			ID:   -8,
+			Name: "type",
+			Type: TypeString,
+		},
+		{
+			ID:   -9,
+			Name: "columns", // for UNIQUE, e.g. "first_name, last_name"
+			Type: TypeString,
+		},
+		{
+			ID:   -10,
+			Name: "check",
+			Type: TypeString,
+		},
+	})
+	// indexes are stored with their columns, in __columns__
+	db.AddTable("__indexes__", "id", []*ColumnDescriptor{
+		{
+			ID:   -12,
+			Name: "id", // the indexed column's
+			Type: TypeString,
+		},
+		{
+			ID:   -13,
+			Name: "table_name",
+			Type: TypeString,
+			ReferencesColumn: &ColumnReference{
+				TableName: "__tables__",
+			},
+		},
+		{
+			ID:   -14,
+			Name: "column_name",
+			Type: TypeString,
+		},
 	})
 	db.AddTable("__record_listeners__", "id", []*ColumnDescriptor{
 		{
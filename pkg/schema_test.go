@@ -0,0 +1,76 @@
+package treesql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+// TestOpenExistingSchema opens a data file written before builtin columns
+// were added, in which user columns have IDs from 13 up, and checks that
+// they don't collide with the builtin columns.
+func TestOpenExistingSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dataFile := dir + "/test.data"
+
+	db, err := NewDatabase(dataFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := &TableDescriptor{Name: "users", PrimaryKey: "id"}
+	columns := []*ColumnDescriptor{
+		{ID: 13, Name: "id", Type: TypeString},
+		{ID: 14, Name: "name", Type: TypeString},
+	}
+	if err := db.BoltDB.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucket([]byte("users")); err != nil {
+			return err
+		}
+		if err := tx.Bucket([]byte("__tables__")).Put([]byte("users"), users.ToRecord(db).ToBytes()); err != nil {
+			return err
+		}
+		for _, column := range columns {
+			key := []byte(fmt.Sprintf("%d", column.ID))
+			if err := tx.Bucket([]byte("__columns__")).Put(key, column.ToRecord("users", db).ToBytes()); err != nil {
+				return err
+			}
+		}
+		nextColumnID := make([]byte, 4)
+		binary.BigEndian.PutUint32(nextColumnID, 15)
+		return tx.Bucket([]byte("__sequences__")).Put([]byte("__next_column_id__"), nextColumnID)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = NewDatabase(dataFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.Schema.NextColumnID != 15 {
+		t.Fatalf("expected next column ID 15; got %d", db.Schema.NextColumnID)
+	}
+	tables := map[int]string{}
+	for _, table := range db.Schema.Tables {
+		for _, column := range table.Columns {
+			if other, ok := tables[column.ID]; ok {
+				t.Fatalf("%s.%s has the same ID as a column of %s: %d", table.Name, column.Name, other, column.ID)
+			}
+			tables[column.ID] = table.Name
+		}
+	}
+	if tables[13] != "users" || tables[14] != "users" {
+		t.Fatalf("expected columns 13 and 14 to be users'; got %v", tables)
+	}
+}
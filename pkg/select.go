@@ -15,7 +15,7 @@ func (db *Database) validateSelect(query *Select, tableAbove *string) error {
 	// does table exist?
 	_, ok := db.Schema.Tables[query.Table]
 	if !ok && query.Table != "__tables__" && query.Table != "__columns__" {
-		return &NoSuchTable{TableName: query.Table}
+		return errorAt(query.Pos, query.Table, &NoSuchTable{TableName: query.Table})
 	}
 	// is there a reference from this table to table above or vice versa?
 	if tableAbove != nil {
@@ -31,21 +31,70 @@ func (db *Database) validateSelect(query *Select, tableAbove *string) error {
 			fromTable = *tableAbove
 			toTable = query.Table
 		}
-		referenceFound := false
-		for _, column := range db.Schema.Tables[fromTable].Columns {
-			if column.ReferencesColumn != nil {
-				if column.ReferencesColumn.TableName == toTable {
-					referenceFound = true
+		candidates := db.Schema.Tables[fromTable].referencesTo(toTable)
+		if query.Via != nil {
+			// the chosen column exists and is a reference
+			if db.Schema.Tables[fromTable].getColumn(*query.Via) == nil {
+				return errorAt(query.Pos, *query.Via, &NoSuchColumn{TableName: fromTable, ColumnName: *query.Via})
+			}
+			chosen := false
+			for _, columnName := range candidates {
+				if columnName == *query.Via {
+					chosen = true
 				}
 			}
-		}
-		if !referenceFound {
-			return &NoReferenceForJoin{
+			if !chosen {
+				return errorAt(query.Pos, *query.Via, &ViaNotReference{
+					FromTable:  fromTable,
+					ColumnName: *query.Via,
+					ToTable:    toTable,
+				})
+			}
+		} else if len(candidates) == 0 {
+			return errorAt(query.Pos, query.Table, &NoReferenceForJoin{
 				FromTable: fromTable,
 				ToTable:   toTable,
-			}
+			})
+		} else if len(candidates) > 1 {
+			return errorAt(query.Pos, query.Table, &AmbiguousJoin{
+				FromTable: fromTable,
+				ToTable:   toTable,
+				Columns:   candidates,
+			})
+		}
+	}
+	// can this selection be repeated inside itself?
+	if query.Recursive {
+		if tableAbove == nil || *tableAbove != query.Table {
+			return errorAt(query.Pos, "RECURSIVE", &RecursiveNotSelfReference{
+				TableName:  query.Table,
+				TableAbove: tableAbove,
+			})
+		}
+		if query.MaxDepth != nil && *query.MaxDepth < 1 {
+			return errorAt(query.Pos, "MAXDEPTH", &InvalidMaxDepth{MaxDepth: *query.MaxDepth})
+		}
+	}
+	// is where clause valid?
+	if query.Where != nil {
+		if err := db.validateExpr(query.Where, db.Schema.Tables[query.Table]); err != nil {
+			return err
 		}
 	}
+	// are ordering and window valid?
+	if query.windowed() && query.One {
+		return errorAt(query.Pos, "", &WindowOnOne{TableName: query.Table})
+	}
+	if query.OrderBy != nil {
+		if db.Schema.Tables[query.Table].getColumn(query.OrderBy.ColumnName) == nil {
+			return errorAt(query.OrderBy.Pos, "", &NoSuchColumn{
+				TableName:  query.Table,
+				ColumnName: query.OrderBy.ColumnName,
+			})
+		}
+	}
+	// expand `*` into the table's columns
+	query.Selections = expandStar(query.Selections, db.Schema.Tables[query.Table])
 	// do columns exist / are subqueries valid?
 	// TODO: dedup
 	for _, selection := range query.Selections {
@@ -54,6 +103,14 @@ func (db *Database) validateSelect(query *Select, tableAbove *string) error {
 			if err != nil {
 				return err
 			}
+		} else if selection.Aggregate != nil {
+			if err := db.validateAggregate(selection.Aggregate, query.Table); err != nil {
+				return err
+			}
+		} else if selection.Expr != nil {
+			if _, err := selection.Expr.typeIn(db.Schema.Tables[query.Table]); err != nil {
+				return err
+			}
 		} else {
 			// hoo, I miss filter
 			hasColumn := false
@@ -63,16 +120,41 @@ func (db *Database) validateSelect(query *Select, tableAbove *string) error {
 				}
 			}
 			if !hasColumn {
-				return &NoSuchColumn{TableName: query.Table, ColumnName: selection.Name}
+				return errorAt(selection.Pos, "", &NoSuchColumn{TableName: query.Table, ColumnName: selection.Name})
 			}
 		}
 	}
 	return nil
 }
 
+// expandStar replaces `*` with a selection for each of the table's columns
+// which isn't already selected by name.
+func expandStar(selections []*Selection, table *TableDescriptor) []*Selection {
+	named := map[string]bool{}
+	for _, selection := range selections {
+		if !selection.Star {
+			named[selection.Name] = true
+		}
+	}
+	expanded := make([]*Selection, 0, len(selections))
+	for _, selection := range selections {
+		if !selection.Star {
+			expanded = append(expanded, selection)
+			continue
+		}
+		for _, column := range table.Columns {
+			if !named[column.Name] {
+				expanded = append(expanded, &Selection{Name: column.Name})
+				named[column.Name] = true
+			}
+		}
+	}
+	return expanded
+}
+
 // TODO: maybe these should be on Channel, not Connection
 func (conn *Connection) ExecuteTopLevelQuery(query *Select, channel *Channel) error {
-	result, _, selectErr := conn.executeQuery(query, channel)
+	result, _, selectErr := conn.executeQuery(query, channel, nil, false)
 	if selectErr != nil {
 		return errors.Wrap(selectErr, "query error")
 	}
@@ -83,8 +165,57 @@ func (conn *Connection) ExecuteTopLevelQuery(query *Select, channel *Channel) er
 	return nil
 }
 
-func (conn *Connection) ExecuteQueryForTableListener(query *Select, statementID int, channel *Channel) (SelectResult, error) {
-	result, _, selectErr := conn.executeQuery(query, channel)
+// validateReturning checks a write's RETURNING selection, if it has one.
+func (db *Database) validateReturning(returning *Select) error {
+	if returning == nil {
+		return nil
+	}
+	return db.validateSelect(returning, nil)
+}
+
+// executeReturning answers a write with the given RETURNING selection of the
+// records it wrote (or deleted), shaped like the result of a query. Nested
+// selections see the database as it is after the write.
+func (conn *Connection) executeReturning(query *Select, records []*Record, channel *Channel) error {
+	tx, err := conn.Database.BoltDB.Begin(false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	execution := &SelectExecution{
+		ID:          ChannelID(channel.ID),
+		Channel:     channel,
+		Query:       query,
+		Transaction: tx,
+		Context:     context.WithValue(conn.Context, clog.ChannelIDKey, channel.ID),
+	}
+
+	table := conn.Database.Schema.Tables[query.Table]
+	columnsMap := map[string]*ColumnDescriptor{}
+	for _, column := range table.Columns {
+		columnsMap[column.Name] = column
+	}
+	result := make(SelectResult, 0, len(records))
+	for _, record := range records {
+		recordResults, err := getRecordResults(query, nil, table, record, execution, columnsMap)
+		if err != nil {
+			return err
+		}
+		result = append(result, recordResults)
+	}
+	channel.WriteInitialResult(&InitialResult{
+		Data:   result,
+		Schema: schemaOfQuery(query),
+	})
+	return nil
+}
+
+// ExecuteQueryForTableListener fetches records which have been added to
+// the selection at queryPath, subscribing to them and their subselections.
+func (conn *Connection) ExecuteQueryForTableListener(
+	query *Select, statementID int, channel *Channel, queryPath *QueryPath,
+) (SelectResult, error) {
+	result, _, selectErr := conn.executeQuery(query, channel, queryPath, true)
 	//clog.Println(
 	//	channel, "executed table listener query for statement", statementID, "in", duration,
 	//)
@@ -94,20 +225,32 @@ func (conn *Connection) ExecuteQueryForTableListener(query *Select, statementID
 func schemaOfQuery(query *Select) map[string]interface{} {
 	result := map[string]interface{}{}
 	result["table"] = query.Table
+	if query.Recursive {
+		// the selections nest inside themselves, under the same name
+		result["recursive"] = true
+	}
+	columns := make([]string, 0)
 	selectionSchemas := map[string]interface{}{}
 	for _, selection := range query.Selections {
 		if selection.SubSelect != nil {
 			selectionSchemas[selection.Name] = schemaOfQuery(selection.SubSelect)
+		} else {
+			columns = append(columns, selection.Name)
 		}
 	}
+	result["columns"] = columns
 	result["selections"] = selectionSchemas
 	return result
 }
 
-// can be from a live query or a top-level query
+// can be from a live query or a top-level query.
+// queryPath is the path of the selection the results will go in;
+// nil for top-level queries.
 func (conn *Connection) executeQuery(
 	query *Select,
 	channel *Channel,
+	queryPath *QueryPath,
+	forTableListener bool,
 ) (SelectResult, *time.Duration, error) {
 	startTime := time.Now()
 	tx, _ := conn.Database.BoltDB.Begin(false)
@@ -119,6 +262,9 @@ func (conn *Connection) executeQuery(
 		Query:       query,
 		Transaction: tx,
 		Context:     ctx,
+		QueryPath:   queryPath,
+
+		forTableListener: forTableListener,
 	}
 
 	result, selectErr := execution.executeSelect(query, nil)
@@ -146,12 +292,25 @@ type SelectExecution struct {
 	Query       *Select
 	Transaction *bolt.Tx
 	Context     context.Context
+	QueryPath   *QueryPath // where the results of Query go
+
+	// re-fetching records for an existing table listener, which
+	// shouldn't be subscribed to again.
+	forTableListener bool
 }
 
 func (ex *SelectExecution) Ctx() context.Context {
 	return ex.Context
 }
 
+// pathSoFar returns the query path of the selection being executed in scope.
+func (ex *SelectExecution) pathSoFar(scope *Scope) *QueryPath {
+	if scope != nil {
+		return scope.pathSoFar
+	}
+	return ex.QueryPath
+}
+
 type Scope struct {
 	table         *TableDescriptor
 	document      *Record
@@ -175,54 +334,32 @@ func (ex *SelectExecution) executeSelect(query *Select, scope *Scope) (SelectRes
 	if scope != nil {
 		filterCondition = getFilterCondition(query, table, scope)
 	}
-	if ex.Query.Live {
-		// add table subscription
-		innerTable := database.Schema.Tables[query.Table]
-		channel := database.Schema.Tables[innerTable.Name].LiveQueryInfo.TableSubscriptionEvents
-		var colNameForSub *string
-		var valueForSub *Value
-		if filterCondition != nil {
-			colNameForSub = &filterCondition.InnerColumnName
-			valueForSub = scope.document.GetField(filterCondition.OuterColumnName)
-		}
-		if query.Where != nil {
-			// TODO: unify these conditions and support ANDs in filtered table listeners
-			// so don't need to worry about this
-			if colNameForSub != nil {
-				clog.Println(ex, "warn:", "overriding filter cond with where cond for subscription")
-			}
-			colNameForSub = &query.Where.ColumnName
-			valueForSub = &Value{
-				Type:      TypeString,
-				StringVal: query.Where.Value,
-			}
-		}
-		var queryPath *QueryPath
-		if scope != nil {
-			queryPath = scope.pathSoFar
-		}
-		channel <- &TableSubscriptionEvent{
-			ColumnName:     colNameForSub,
-			Value:          valueForSub,
-			SubQuery:       query,
-			QueryExecution: ex,
-			QueryPath:      queryPath,
+	// A null reference joins to nothing, so there's nothing to listen for either.
+	if filterCondition != nil && scope.document.GetField(filterCondition.OuterColumnName).Null {
+		if query.One {
+			return nil, nil // null in results
 		}
+		return SelectResult{}, nil
+	}
+	// Windowed selections subscribe once they know which records are in the window.
+	if ex.Query.Live && !query.windowed() && !(scope == nil && ex.forTableListener) {
+		ex.subscribeToTable(query, scope, filterCondition, nil)
 	}
 	//clog.Println(ex, "==================")
 	if query.Where != nil {
-		if query.Where.ColumnName == table.PrimaryKey {
-			//clog.Println(ex, "WHERE ON PK", table.Name, query.Where.ColumnName)
-			return ex.lookupRecord(query, query.Where.Value, scope, table)
+		columnName, value, ok := query.Where.equalityCondition()
+		if ok && columnName == table.PrimaryKey && filterCondition == nil && !query.windowed() {
+			//clog.Println(ex, "WHERE ON PK", table.Name, columnName)
+			return ex.lookupRecord(query, value.key(), scope, table)
 		} else {
-			//clog.Println(ex, "WHERE ON NOT PK", table.Name, query.Where.ColumnName)
+			//clog.Println(ex, "WHERE ON NOT PK", table.Name)
 			return ex.scanTable(query, filterCondition, scope, table)
 		}
 	}
 	if filterCondition != nil {
 		if filterCondition.InnerColumnName == table.PrimaryKey {
 			//clog.Println(ex, "FILTER ON PK", table.Name, filterCondition.InnerColumnName, filterCondition.OuterColumnName)
-			pkVal := scope.document.GetField(filterCondition.OuterColumnName).StringVal
+			pkVal := scope.document.GetField(filterCondition.OuterColumnName).key()
 			return ex.lookupRecord(query, pkVal, scope, table)
 		} else {
 			//clog.Println(ex, "FILTER ON NOT PK", table.Name, filterCondition.InnerColumnName, filterCondition.OuterColumnName)
@@ -253,10 +390,16 @@ func (ex *SelectExecution) lookupRecord(
 	if err != nil {
 		return nil, err
 	}
+	if record == nil {
+		if query.One {
+			return nil, errors.New("error: requested one row, but none found")
+		}
+		return SelectResult{}, nil
+	}
 
 	// This query is in the result set; subscribe to it.
 	if ex.Query.Live {
-		ex.subscribeToRecord(scope, record, table)
+		ex.subscribeToRecord(scope, record, table, query.Selections)
 	}
 
 	// Extract needed columns.
@@ -290,9 +433,15 @@ func (ex *SelectExecution) scanTable(
 		columnsMap[column.Name] = column
 	}
 
-	// start iterating
-	iterator, _ := ex.getTableIterator(query.Table)
-	rowsRead := 0
+	// start iterating, over just the records an index says might match
+	// if there's one to use, e.g. on the join column
+	condition := query.Where
+	if filterCondition != nil {
+		outerValue := scope.document.GetField(filterCondition.OuterColumnName)
+		condition = NewEqualsExpr(filterCondition.InnerColumnName, outerValue).And(condition)
+	}
+	iterator, _ := ex.getScanIterator(table, condition)
+	var records []*Record
 	for {
 		// get next doc
 		record := iterator.Next()
@@ -306,31 +455,38 @@ func (ex *SelectExecution) scanTable(
 			}
 		}
 		if query.Where != nil {
-			// again ignoring int vals for now...
-			if record.GetField(query.Where.ColumnName).StringVal != query.Where.Value {
+			if !query.Where.Evaluate(record) {
 				continue
 			}
 		}
-		if rowsRead == 1 && query.One {
+		if len(records) == 1 && query.One {
 			return nil, fmt.Errorf("one row requested, but found > 1")
 		}
+		records = append(records, record)
+	}
+	iterator.Close()
+	if query.One && len(records) == 0 {
+		return nil, errors.New("error: requested one row, but none found")
+		// TODO: this could be in the middle of a result set, lol
+	}
+	if query.windowed() {
+		records = query.applyWindow(records)
+		if ex.Query.Live && !(scope == nil && ex.forTableListener) {
+			ex.subscribeToTable(query, scope, filterCondition, recordKeys(records))
+		}
+	}
+	for _, record := range records {
 		// this record is in the result set... let's subscribe to it
 		if ex.Query.Live {
-			ex.subscribeToRecord(scope, record, table)
+			ex.subscribeToRecord(scope, record, table, query.Selections)
 		}
 		// get all fields for selection
 		recordResults, subSelectErr := getRecordResults(query, scope, table, record, ex, columnsMap)
 		if subSelectErr != nil {
 			return nil, subSelectErr
 		}
-		rowsRead++
 		result = append(result, recordResults)
 	}
-	iterator.Close()
-	if query.One && rowsRead == 0 {
-		return nil, errors.New("error: requested one row, but none found")
-		// TODO: this could be in the middle of a result set, lol
-	}
 	// Record duration.
 	end := time.Now()
 	duration := end.Sub(start)
@@ -349,17 +505,19 @@ func getRecordResults(
 
 	recordResults := map[string]interface{}{}
 	// extract & write fields
-	for _, selection := range query.Selections {
+	selections := query.Selections
+	if query.Recursive {
+		selections = ex.withRecursion(query, scope, record)
+	}
+	for _, selection := range selections {
 		if selection.SubSelect != nil {
 			// execute subquery
-			var queryPathSoFar *QueryPath
-			if scope != nil {
-				queryPathSoFar = scope.pathSoFar
-			}
+			queryPathSoFar := ex.pathSoFar(scope)
 			// TODO: refactor: we've already made this in `executeSelect` above
 			// maybe fold scope chain & query path together for fewer parameters
+			primaryKey := record.primaryKey()
 			queryPathWithPkVal := &QueryPath{
-				ID:              &record.GetField(tableSchema.PrimaryKey).StringVal,
+				ID:              &primaryKey,
 				PreviousSegment: queryPathSoFar,
 			}
 			queryPathWithSelection := &QueryPath{
@@ -378,74 +536,150 @@ func getRecordResults(
 				return nil, subselectErr
 			}
 			recordResults[selection.Name] = subselectResult
+		} else if selection.Aggregate != nil {
+			aggregateResult, aggregateErr := ex.executeAggregate(selection, tableSchema, record, scope)
+			if aggregateErr != nil {
+				return nil, aggregateErr
+			}
+			recordResults[selection.Name] = aggregateResult
+		} else if selection.Expr != nil {
+			exprResult, exprErr := fieldValue(selection, record)
+			if exprErr != nil {
+				return nil, exprErr
+			}
+			recordResults[selection.Name] = exprResult
 		} else {
 			// save field value
 			columnSpec := columnsMap[selection.Name]
-			switch columnSpec.Type {
-			case TypeInt:
-				val := record.GetField(columnSpec.Name).StringVal
-				recordResults[columnSpec.Name] = val
-
-			case TypeString:
-				val := record.GetField(columnSpec.Name).StringVal
-				recordResults[columnSpec.Name] = val
-			}
+			recordResults[columnSpec.Name] = record.GetField(columnSpec.Name).jsonValue()
 		}
 	}
 	return recordResults, nil
 }
 
+// fieldValue returns the value of a column or expression selection for a record.
+func fieldValue(selection *Selection, record *Record) (interface{}, error) {
+	if selection.Expr != nil {
+		value, err := selection.Expr.evaluate(record)
+		if err != nil {
+			return nil, err
+		}
+		return value.jsonValue(), nil
+	}
+	return record.GetField(selection.Name).jsonValue(), nil
+}
+
+// recordFields returns the values of the column and expression selections
+// for a record, as they'd appear in its result.
+func recordFields(selections []*Selection, record *Record) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	for _, selection := range selections {
+		if selection.SubSelect != nil || selection.Aggregate != nil {
+			continue
+		}
+		value, err := fieldValue(selection, record)
+		if err != nil {
+			return nil, err
+		}
+		fields[selection.Name] = value
+	}
+	return fields, nil
+}
+
 func recordMatchesFilter(condition *FilterCondition, innerRec *Record, outerRec *Record) bool {
 	innerField := innerRec.GetField(condition.InnerColumnName)
 	outerField := outerRec.GetField(condition.OuterColumnName)
-	return innerField.StringVal == outerField.StringVal // TODO: more than strings someday
+	if innerField.Null || outerField.Null {
+		return false
+	}
+	return innerField.key() == outerField.key()
 }
 
 func getFilterCondition(query *Select, tableSchema *TableDescriptor, scope *Scope) *FilterCondition {
-	var filterCondition *FilterCondition
+	// TODO: this is the kind of thing that should be done in a query planner,
+	// not in every nested loop
 	if query.Many {
 		// find reference from inner table to outer table
-		// TODO: this is the kind of thing that should be done in a query planner,
-		// not in every nested loop
-		for _, columnSpec := range tableSchema.Columns {
-			if columnSpec.ReferencesColumn != nil &&
-				columnSpec.ReferencesColumn.TableName == scope.table.Name {
-				filterCondition = &FilterCondition{
-					InnerColumnName: columnSpec.Name,
-					OuterColumnName: scope.table.PrimaryKey,
-				}
-			}
+		joinColumn := joinColumn(query, tableSchema, scope.table.Name)
+		if joinColumn == "" {
+			return nil
 		}
-	} else {
-		// find reference from outer table to inner table
-		// e.g. one comment { blog_post: one blog_posts }
-		// => inner: id, outer: post_id
-		for _, columnSpec := range scope.table.Columns {
-			if columnSpec.ReferencesColumn != nil &&
-				columnSpec.ReferencesColumn.TableName == tableSchema.Name {
-				filterCondition = &FilterCondition{
-					InnerColumnName: tableSchema.PrimaryKey,
-					OuterColumnName: columnSpec.Name,
-				}
-			}
+		return &FilterCondition{
+			InnerColumnName: joinColumn,
+			OuterColumnName: scope.table.PrimaryKey,
 		}
 	}
-	return filterCondition
+	// find reference from outer table to inner table
+	// e.g. one comment { blog_post: one blog_posts }
+	// => inner: id, outer: post_id
+	joinColumn := joinColumn(query, scope.table, tableSchema.Name)
+	if joinColumn == "" {
+		return nil
+	}
+	return &FilterCondition{
+		InnerColumnName: tableSchema.PrimaryKey,
+		OuterColumnName: joinColumn,
+	}
 }
 
-func (ex *SelectExecution) subscribeToRecord(scope *Scope, record *Record, table *TableDescriptor) {
-	var previousQueryPath *QueryPath
-	if scope != nil {
-		previousQueryPath = scope.pathSoFar
+// joinColumn returns the column in fromTable to join on: the one given
+// with VIA, or else the only one referencing toTable.
+func joinColumn(query *Select, fromTable *TableDescriptor, toTable string) string {
+	if query.Via != nil {
+		return *query.Via
+	}
+	candidates := fromTable.referencesTo(toTable)
+	if len(candidates) == 0 {
+		return ""
 	}
+	return candidates[0]
+}
+
+// subscribeToTable adds a table listener for this selection, so that live
+// queries hear about new records. Windowed selections also pass the primary
+// keys of the records currently in their window.
+func (ex *SelectExecution) subscribeToTable(
+	query *Select, scope *Scope, filterCondition *FilterCondition, window []string,
+) {
+	database := ex.Channel.Connection.Database
+	table := database.Schema.Tables[query.Table]
+	var colNameForSub *string
+	var valueForSub *Value
+	// Listen on the join condition if there is one, otherwise on the where
+	// clause if it's a simple equality. Either way, table listeners re-check
+	// the where clause when they run.
+	if filterCondition != nil {
+		colNameForSub = &filterCondition.InnerColumnName
+		valueForSub = scope.document.GetField(filterCondition.OuterColumnName)
+	} else if query.Where != nil {
+		if columnName, value, ok := query.Where.equalityCondition(); ok {
+			colNameForSub = &columnName
+			valueForSub = value
+		}
+	}
+	table.LiveQueryInfo.TableSubscriptionEvents <- &TableSubscriptionEvent{
+		ColumnName:     colNameForSub,
+		Value:          valueForSub,
+		SubQuery:       query,
+		QueryExecution: ex,
+		QueryPath:      ex.pathSoFar(scope),
+		Window:         window,
+	}
+}
+
+func (ex *SelectExecution) subscribeToRecord(
+	scope *Scope, record *Record, table *TableDescriptor, selections []*Selection,
+) {
+	primaryKey := record.primaryKey()
 	queryPathWithPkVal := &QueryPath{
-		ID:              &record.GetField(table.PrimaryKey).StringVal,
-		PreviousSegment: previousQueryPath,
+		ID:              &primaryKey,
+		PreviousSegment: ex.pathSoFar(scope),
 	}
 	tableEventsChannel := table.LiveQueryInfo.RecordSubscriptionEvents
 	tableEventsChannel <- &RecordSubscriptionEvent{
 		Value:          record.GetField(table.PrimaryKey),
 		QueryExecution: ex,
 		QueryPath:      queryPathWithPkVal,
+		Selections:     selections,
 	}
 }
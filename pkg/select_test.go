@@ -11,8 +11,8 @@ func TestSelect(t *testing.T) {
 		// Create blog post schema.
 		{
 			stmt: `
-				CREATETABLE blog_posts (
-					id string PRIMARYKEY,
+				CREATE TABLE blog_posts (
+					id string PRIMARY KEY,
 					title string
 				)
 			`,
@@ -20,9 +20,9 @@ func TestSelect(t *testing.T) {
 		},
 		{
 			stmt: `
-				CREATETABLE comments (
-					id string PRIMARYKEY,
-					blog_post_id string REFERENCESTABLE blog_posts,
+				CREATE TABLE comments (
+					id string PRIMARY KEY,
+					blog_post_id string REFERENCES blog_posts,
 					body string
 				)
 			`,
@@ -100,6 +100,728 @@ func TestSelect(t *testing.T) {
 	})
 }
 
+func TestSelectWhere(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, author string, views int)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("a", "pete", 5)`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("b", "pete", 20)`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("c", "bob", 100)`,
+			ack:  "INSERT 1",
+		},
+		// Verify that columns and types are checked.
+		{
+			query: `MANY blog_posts WHERE title = "foo" { id }`,
+			error: "validation error: no such column in table blog_posts: title",
+		},
+		{
+			query: `MANY blog_posts WHERE views > "5" { id }`,
+			error: "validation error: can't compare int to string",
+		},
+		{
+			query: `MANY blog_posts WHERE views > 2147483648 { id }`,
+			error: "validation error: 2147483648 isn't a valid int; ints are whole numbers from -2147483648 to 2147483647",
+		},
+		{
+			query: `MANY blog_posts WHERE id BETWEEN "a" AND 5 { id }`,
+			error: "validation error: can't compare string to int",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("d", "bob", "5")`,
+			error: "validation error: can't assign string value to int column views",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("d", "bob", 2.5)`,
+			error: "validation error: can't assign float value to int column views",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES ("d", "bob", 3000000000)`,
+			error: "validation error: 3000000000 isn't a valid int; ints are whole numbers from -2147483648 to 2147483647",
+		},
+		// Happy path. Ints compare numerically.
+		{
+			query: `MANY blog_posts WHERE views > 10 { id, views }`,
+			initialResult: `[
+  {
+    "id": "b",
+    "views": 20
+  },
+  {
+    "id": "c",
+    "views": 100
+  }
+]`,
+		},
+		{
+			query: `MANY blog_posts WHERE id >= "b" { id }`,
+			initialResult: `[
+  {
+    "id": "b"
+  },
+  {
+    "id": "c"
+  }
+]`,
+		},
+		{
+			query: `MANY blog_posts WHERE author = "pete" AND NOT id BETWEEN "b" AND "c" { id }`,
+			initialResult: `[
+  {
+    "id": "a"
+  }
+]`,
+		},
+		{
+			query: `MANY blog_posts WHERE (author <> "pete" OR id < "b") AND id IS NOT NULL { id }`,
+			initialResult: `[
+  {
+    "id": "a"
+  },
+  {
+    "id": "c"
+  }
+]`,
+		},
+		{
+			query:         `MANY blog_posts WHERE author IS NULL { id }`,
+			initialResult: `[]`,
+		},
+		// Where clauses also work in updates and deletes.
+		{
+			stmt: `UPDATE blog_posts SET author = "pete" WHERE author = "bob" OR id < "b"`,
+			ack:  "UPDATE 2",
+		},
+		{
+			stmt: `DELETE FROM blog_posts WHERE author = "pete" AND id > "a"`,
+			ack:  "DELETE 2",
+		},
+		{
+			query: `MANY blog_posts { id, author }`,
+			initialResult: `[
+  {
+    "author": "pete",
+    "id": "a"
+  }
+]`,
+		},
+	})
+}
+
+func TestSelectOrderLimit(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE comments (id string PRIMARY KEY, blog_post_id string REFERENCES blog_posts, body string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "b")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("1", "c")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("2", "a")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO comments VALUES ("0", "0", "first")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO comments VALUES ("1", "0", "second")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO comments VALUES ("2", "0", "third")`,
+			ack:  "INSERT 1",
+		},
+		// Verify that ordering and windows are validated.
+		{
+			query: `MANY blog_posts ORDER BY author { id }`,
+			error: "validation error: no such column in table blog_posts: author",
+		},
+		{
+			query: `MANY comments { post: ONE blog_posts LIMIT 1 { id } }`,
+			error: "validation error: ORDER BY, LIMIT and OFFSET only apply to MANY selections; got ONE blog_posts",
+		},
+		// Happy path.
+		{
+			query: `MANY blog_posts ORDER BY title { title }`,
+			initialResult: `[
+  {
+    "title": "a"
+  },
+  {
+    "title": "b"
+  },
+  {
+    "title": "c"
+  }
+]`,
+		},
+		{
+			query: `MANY blog_posts ORDER BY title DESC LIMIT 2 OFFSET 1 { title }`,
+			initialResult: `[
+  {
+    "title": "b"
+  },
+  {
+    "title": "a"
+  }
+]`,
+		},
+		{
+			query: `MANY blog_posts WHERE id = "0" { id, comments: MANY comments ORDER BY id DESC LIMIT 2 { body } }`,
+			initialResult: `[
+  {
+    "comments": [
+      {
+        "body": "third"
+      },
+      {
+        "body": "second"
+      }
+    ],
+    "id": "0"
+  }
+]`,
+		},
+		{
+			query:         `MANY blog_posts OFFSET 5 { id }`,
+			initialResult: `[]`,
+		},
+	})
+}
+
+func TestSelectStar(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE comments (id string PRIMARY KEY, blog_post_id string REFERENCES blog_posts, body string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "hello world")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO comments VALUES ("0", "0", "hello yourself!")`,
+			ack:  "INSERT 1",
+		},
+		{
+			query: `MANY blog_posts { *, comments: MANY comments { * } }`,
+			initialResult: `[
+  {
+    "comments": [
+      {
+        "blog_post_id": "0",
+        "body": "hello yourself!",
+        "id": "0"
+      }
+    ],
+    "id": "0",
+    "title": "hello world"
+  }
+]`,
+			schema: `{
+  "columns": [
+    "id",
+    "title"
+  ],
+  "selections": {
+    "comments": {
+      "columns": [
+        "id",
+        "blog_post_id",
+        "body"
+      ],
+      "selections": {},
+      "table": "comments"
+    }
+  },
+  "table": "blog_posts"
+}`,
+		},
+		// Named columns aren't selected twice.
+		{
+			query: `MANY comments { body, * }`,
+			schema: `{
+  "columns": [
+    "body",
+    "id",
+    "blog_post_id"
+  ],
+  "selections": {},
+  "table": "comments"
+}`,
+			initialResult: `[
+  {
+    "blog_post_id": "0",
+    "body": "hello yourself!",
+    "id": "0"
+  }
+]`,
+		},
+	})
+}
+
+func TestSelectAggregates(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE comments (id string PRIMARY KEY, blog_post_id string REFERENCES blog_posts, body string, score int, rating float)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "hello world")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("1", "hello again world")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO comments VALUES ("0", "0", "bbb", 1, 0.5)`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO comments VALUES ("1", "0", "aaa", 2, 2.25)`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO comments VALUES ("2", "0", "ccc", 4, 4)`,
+			ack:  "INSERT 1",
+		},
+		// Verify that aggregates are validated.
+		{
+			query: `MANY blog_posts { n: COUNT authors }`,
+			error: "validation error: no such table: authors",
+		},
+		{
+			query: `MANY comments { n: COUNT blog_posts }`,
+			error: "validation error: query requires a column in table `blog_posts` referencing table `comments`; none found",
+		},
+		{
+			query: `MANY blog_posts { n: MAX comments }`,
+			error: "validation error: MAX needs a column, e.g. MAX table.column",
+		},
+		{
+			query: `MANY blog_posts { n: SUM comments.body }`,
+			error: "validation error: SUM only applies to int and float columns; got string",
+		},
+		{
+			query: `MANY blog_posts { n: MIN comments.author }`,
+			error: "validation error: no such column in table comments: author",
+		},
+		// Happy path.
+		{
+			query: `
+				MANY blog_posts {
+					id,
+					comment_count: COUNT comments,
+					recent_count: COUNT comments WHERE id > "0",
+					first_body: MIN comments.body,
+					last_body: MAX comments.body,
+					total_score: SUM comments.score,
+					total_rating: SUM comments.rating,
+					average_rating: AVG comments.rating
+				}
+			`,
+			initialResult: `[
+  {
+    "average_rating": 2.25,
+    "comment_count": 3,
+    "first_body": "aaa",
+    "id": "0",
+    "last_body": "ccc",
+    "recent_count": 2,
+    "total_rating": 6.75,
+    "total_score": 7
+  },
+  {
+    "average_rating": null,
+    "comment_count": 0,
+    "first_body": null,
+    "id": "1",
+    "last_body": null,
+    "recent_count": 0,
+    "total_rating": 0,
+    "total_score": 0
+  }
+]`,
+		},
+	})
+}
+
+func TestSelectVia(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE users (id string PRIMARY KEY, name string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE messages (id string PRIMARY KEY, sender_id string REFERENCES users, recipient_id string REFERENCES users, body string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO users VALUES ("pete", "Pete"), ("vil", "Vil")`,
+			ack:  "INSERT 2",
+		},
+		{
+			stmt: `INSERT INTO messages VALUES ("0", "pete", "vil", "hi"), ("1", "vil", "pete", "hey"), ("2", "vil", "pete", "what's up")`,
+			ack:  "INSERT 3",
+		},
+		// Verify that the join column is checked.
+		{
+			query: `MANY users { id, messages: MANY messages { id } }`,
+			error: "validation error: table `messages` has several columns referencing table `users` (sender_id, recipient_id); choose one with VIA",
+		},
+		{
+			query: `MANY messages { id, sender: ONE users { id } }`,
+			error: "validation error: table `messages` has several columns referencing table `users` (sender_id, recipient_id); choose one with VIA",
+		},
+		{
+			query: `MANY users { id, messages: MANY messages VIA author_id { id } }`,
+			error: "validation error: no such column in table messages: author_id",
+		},
+		{
+			query: `MANY users { id, messages: MANY messages VIA body { id } }`,
+			error: "validation error: column `messages.body` doesn't reference table `users`",
+		},
+		{
+			query: `MANY users { id, num_messages: COUNT messages }`,
+			error: "validation error: table `messages` has several columns referencing table `users` (sender_id, recipient_id); choose one with VIA",
+		},
+		// Happy path.
+		{
+			query: `
+				MANY users {
+					id,
+					sent: MANY messages VIA sender_id { id },
+					num_received: COUNT messages VIA recipient_id
+				}
+			`,
+			initialResult: `[
+  {
+    "id": "pete",
+    "num_received": 2,
+    "sent": [
+      {
+        "id": "0"
+      }
+    ]
+  },
+  {
+    "id": "vil",
+    "num_received": 1,
+    "sent": [
+      {
+        "id": "1"
+      },
+      {
+        "id": "2"
+      }
+    ]
+  }
+]`,
+		},
+		{
+			query: `MANY messages WHERE id = "0" { id, sender: ONE users VIA sender_id { name }, recipient: ONE users VIA recipient_id { name } }`,
+			initialResult: `[
+  {
+    "id": "0",
+    "recipient": [
+      {
+        "name": "Vil"
+      }
+    ],
+    "sender": [
+      {
+        "name": "Pete"
+      }
+    ]
+  }
+]`,
+		},
+	})
+}
+
+func TestSelectRecursive(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE blog_posts (id string PRIMARY KEY, title string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE comments (id string PRIMARY KEY, blog_post_id string REFERENCES blog_posts, parent_id string REFERENCES comments)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES ("0", "hello world")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO comments (id, blog_post_id) VALUES ("0", "0")`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO comments (id, parent_id) VALUES ("1", "0"), ("2", "1"), ("3", "0")`,
+			ack:  "INSERT 3",
+		},
+		// Verify that recursion is checked.
+		{
+			query: `MANY comments RECURSIVE { id }`,
+			error: "validation error: RECURSIVE only applies to nested selections; got top-level comments",
+		},
+		{
+			query: `MANY blog_posts { comments: MANY comments RECURSIVE { id } }`,
+			error: "validation error: RECURSIVE selections must be of the same table as the one above; got comments inside blog_posts",
+		},
+		{
+			query: `MANY comments { replies: MANY comments RECURSIVE MAXDEPTH 0 { id } }`,
+			error: "validation error: MAXDEPTH must be at least 1; got 0",
+		},
+		// Happy path.
+		{
+			query: `
+				MANY blog_posts {
+					comments: MANY comments {
+						id,
+						replies: MANY comments RECURSIVE MAXDEPTH 2 { id }
+					}
+				}
+			`,
+			initialResult: `[
+  {
+    "comments": [
+      {
+        "id": "0",
+        "replies": [
+          {
+            "id": "1",
+            "replies": [
+              {
+                "id": "2"
+              }
+            ]
+          },
+          {
+            "id": "3",
+            "replies": []
+          }
+        ]
+      }
+    ]
+  }
+]`,
+		},
+		{
+			query: `MANY comments WHERE id = "0" { id, replies: MANY comments VIA parent_id RECURSIVE { id } }`,
+			initialResult: `[
+  {
+    "id": "0",
+    "replies": [
+      {
+        "id": "1",
+        "replies": [
+          {
+            "id": "2",
+            "replies": []
+          }
+        ]
+      },
+      {
+        "id": "3",
+        "replies": []
+      }
+    ]
+  }
+]`,
+		},
+		// Records already in the chain aren't nested again.
+		{
+			stmt: `UPDATE comments SET parent_id = "2" WHERE id = "0"`,
+			ack:  "UPDATE 1",
+		},
+		{
+			query: `MANY comments WHERE id = "0" { id, replies: MANY comments RECURSIVE { id } }`,
+			initialResult: `[
+  {
+    "id": "0",
+    "replies": [
+      {
+        "id": "1",
+        "replies": [
+          {
+            "id": "2",
+            "replies": [
+              {
+                "id": "0"
+              }
+            ]
+          }
+        ]
+      },
+      {
+        "id": "3",
+        "replies": []
+      }
+    ]
+  }
+]`,
+		},
+	})
+}
+
+func TestSelectIntKeys(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE authors (id int PRIMARY KEY, name string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `CREATE TABLE blog_posts (id int PRIMARY KEY, author_id int REFERENCES authors, title string)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO authors VALUES (1, "pete"), (-2, "vil")`,
+			ack:  "INSERT 2",
+		},
+		{
+			stmt: `INSERT INTO blog_posts VALUES (9, 1, "hello world"), (10, 1, "hello again world"), (11, -2, "sup")`,
+			ack:  "INSERT 3",
+		},
+		{
+			stmt:  `INSERT INTO blog_posts VALUES (10, 1, "hello world")`,
+			error: "executing insert: record already exists with primary key id=10",
+		},
+		// Happy path.
+		{
+			query: `ONE blog_posts WHERE id = 10 { id, title, author: ONE authors { id, name } }`,
+			initialResult: `[
+  {
+    "author": [
+      {
+        "id": 1,
+        "name": "pete"
+      }
+    ],
+    "id": 10,
+    "title": "hello again world"
+  }
+]`,
+		},
+		{
+			query: `MANY authors WHERE id < 0 { name, posts: MANY blog_posts ORDER BY id DESC { id } }`,
+			initialResult: `[
+  {
+    "name": "vil",
+    "posts": [
+      {
+        "id": 11
+      }
+    ]
+  }
+]`,
+		},
+		{
+			query: `MANY blog_posts ORDER BY id LIMIT 2 { id, rank: id - 8 }`,
+			initialResult: `[
+  {
+    "id": 9,
+    "rank": 1
+  },
+  {
+    "id": 10,
+    "rank": 2
+  }
+]`,
+		},
+		{
+			stmt: `DELETE FROM blog_posts WHERE id = 9`,
+			ack:  "DELETE 1",
+		},
+		{
+			query: `MANY authors WHERE id = 1 { id, post_count: COUNT blog_posts }`,
+			initialResult: `[
+  {
+    "id": 1,
+    "post_count": 1
+  }
+]`,
+		},
+	})
+}
+
+func TestSelectExpressions(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE users (id string PRIMARY KEY, first_name string, last_name string, karma int)`,
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO users (id, first_name, last_name) VALUES ("0", "Ada", "Lovelace"), ("1", "Alan", "Turing")`,
+			ack:  "INSERT 2",
+		},
+		// Verify that expressions are checked.
+		{
+			query: `MANY users { name: name }`,
+			error: "validation error: no such column in table users: name",
+		},
+		{
+			query: `MANY users { name: first_name || karma }`,
+			error: "validation error: operator || only applies to string values; got int",
+		},
+		{
+			query: `MANY users { score: karma - first_name }`,
+			error: "validation error: operator - only applies to int values; got string",
+		},
+		// Happy path.
+		{
+			query: `
+				MANY users WHERE id = "0" {
+					id,
+					given_name: first_name,
+					display_name: first_name || ' ' || upper(last_name),
+					name_length: length(first_name || last_name) * 2 - 1,
+					greeting: $1 || first_name
+				}
+			`,
+			args: []interface{}{"Hello, "},
+			initialResult: `[
+  {
+    "display_name": "Ada LOVELACE",
+    "given_name": "Ada",
+    "greeting": "Hello, Ada",
+    "id": "0",
+    "name_length": 21
+  }
+]`,
+		},
+	})
+}
+
 func BenchmarkSelect(t *testing.B) {
 	numAuthors := 5
 	numPosts := 100
@@ -113,19 +835,19 @@ func BenchmarkSelect(t *testing.B) {
 
 	// Create schema.
 	schemaStmts := []string{
-		`CREATETABLE authors (
-			id string primarykey,
+		`CREATE TABLE authors (
+			id string primary key,
 			name string
 		)`,
-		`CREATETABLE blog_posts (
-			id string primarykey,
-			author_id string referencestable authors,
+		`CREATE TABLE blog_posts (
+			id string primary key,
+			author_id string references authors,
 			title string
 		)`,
-		`CREATETABLE comments (
-			id string primarykey,
-			author_id string referencestable authors,
-			post_id string referencestable blog_posts,
+		`CREATE TABLE comments (
+			id string primary key,
+			author_id string references authors,
+			post_id string references blog_posts,
 			body string
 		)`,
 	}
@@ -11,6 +11,7 @@ import (
 
 type TableIterator interface {
 	Next() *Record
+	// Get returns the record with the given primary key, or nil if there isn't one.
 	Get(key string) (*Record, error)
 	Close()
 }
@@ -22,12 +23,28 @@ func (ex *SelectExecution) getTableIterator(tableName string) (TableIterator, er
 	if tableName == "__columns__" {
 		return newColumnsIterator(ex.Channel.Connection.Database)
 	}
+	if tableName == "__constraints__" {
+		return newConstraintsIterator(ex.Channel.Connection.Database)
+	}
+	if tableName == "__indexes__" {
+		return newIndexesIterator(ex.Channel.Connection.Database)
+	}
 	if tableName == "__record_listeners__" {
 		return newRecordListenersIterator(ex.Channel.Connection.Database)
 	}
 	return newBoltIterator(ex, tableName)
 }
 
+// getScanIterator returns an iterator over the table's records which might
+// satisfy the condition: those in the range of an indexed column it
+// constrains, if there is one, or else all of them.
+func (ex *SelectExecution) getScanIterator(table *TableDescriptor, condition *Expr) (TableIterator, error) {
+	if scan := condition.indexScan(table); scan != nil {
+		return newIndexIterator(ex, table, scan)
+	}
+	return ex.getTableIterator(table.Name)
+}
+
 // bolt iterator
 
 type BoltIterator struct {
@@ -63,7 +80,11 @@ func (it *BoltIterator) Next() *Record {
 }
 
 func (it *BoltIterator) Get(key string) (*Record, error) {
-	_, rawRecord := it.cursor.Seek([]byte(key))
+	// Seek finds the first key at or after the given one
+	foundKey, rawRecord := it.cursor.Seek([]byte(key))
+	if foundKey == nil || string(foundKey) != key {
+		return nil, nil
+	}
 	return it.table.RecordFromBytes(rawRecord), nil
 }
 
@@ -105,7 +126,10 @@ func (it *SchemaTablesIterator) Next() *Record {
 }
 
 func (it *SchemaTablesIterator) Get(key string) (*Record, error) {
-	table := it.db.Schema.Tables[key]
+	table, ok := it.db.Schema.Tables[key]
+	if !ok {
+		return nil, nil
+	}
 	return table.ToRecord(it.db), nil
 }
 
@@ -144,18 +168,102 @@ func (it *SchemaColumnsIterator) Next() *Record {
 }
 
 func (it *SchemaColumnsIterator) Get(key string) (*Record, error) {
-	// BUG: this is not stable if columns are dropped
-	// need real OIDs
-	// need sequences as a first-class DB object O_o
-	idx, err := strconv.Atoi(key)
-	if err != nil {
-		return nil, nil
+	for _, columnDoc := range it.columns {
+		if columnDoc.GetField("id").StringVal == key {
+			return columnDoc, nil
+		}
 	}
-	return it.columns[idx], nil
+	return nil, nil
 }
 
 func (it *SchemaColumnsIterator) Close() {}
 
+// schema constraints iterator
+
+type SchemaConstraintsIterator struct {
+	db          *Database
+	constraints []*Record
+	idx         int
+}
+
+func newConstraintsIterator(db *Database) (*SchemaConstraintsIterator, error) {
+	constraints := make([]*Record, 0)
+	for _, table := range db.Schema.Tables {
+		for _, constraint := range table.Constraints {
+			constraints = append(constraints, constraint.ToRecord(table.Name, db))
+		}
+	}
+	return &SchemaConstraintsIterator{
+		db:          db,
+		constraints: constraints,
+		idx:         0,
+	}, nil
+}
+
+func (it *SchemaConstraintsIterator) Next() *Record {
+	if it.idx == len(it.constraints) {
+		return nil
+	}
+	constraintDoc := it.constraints[it.idx]
+	it.idx++
+	return constraintDoc
+}
+
+func (it *SchemaConstraintsIterator) Get(key string) (*Record, error) {
+	for _, constraintDoc := range it.constraints {
+		if constraintDoc.GetField("name").StringVal == key {
+			return constraintDoc, nil
+		}
+	}
+	return nil, nil
+}
+
+func (it *SchemaConstraintsIterator) Close() {}
+
+// schema indexes iterator
+
+type SchemaIndexesIterator struct {
+	db      *Database
+	indexes []*Record
+	idx     int
+}
+
+func newIndexesIterator(db *Database) (*SchemaIndexesIterator, error) {
+	indexes := make([]*Record, 0)
+	for _, table := range db.Schema.Tables {
+		for _, column := range table.Columns {
+			if column.Indexed {
+				indexes = append(indexes, column.IndexToRecord(table.Name, db))
+			}
+		}
+	}
+	return &SchemaIndexesIterator{
+		db:      db,
+		indexes: indexes,
+		idx:     0,
+	}, nil
+}
+
+func (it *SchemaIndexesIterator) Next() *Record {
+	if it.idx == len(it.indexes) {
+		return nil
+	}
+	indexDoc := it.indexes[it.idx]
+	it.idx++
+	return indexDoc
+}
+
+func (it *SchemaIndexesIterator) Get(key string) (*Record, error) {
+	for _, indexDoc := range it.indexes {
+		if indexDoc.GetField("id").StringVal == key {
+			return indexDoc, nil
+		}
+	}
+	return nil, nil
+}
+
+func (it *SchemaIndexesIterator) Close() {}
+
 // record listeners iterator
 
 type RecordListenersIterator struct {
@@ -211,7 +319,7 @@ func (it *RecordListenersIterator) Get(key string) (*Record, error) {
 	// need real OIDs
 	// need sequences as a first-class DB object O_o
 	idx, err := strconv.Atoi(key)
-	if err != nil {
+	if err != nil || idx < 0 || idx >= len(it.listeners) {
 		return nil, nil
 	}
 	return it.listeners[idx], nil
@@ -51,10 +51,12 @@ func NewTestServer() (*testServer, *Client, error) {
 type simpleTestStmt struct {
 	stmt  string
 	query string
+	args  []interface{} // bound to placeholders in stmt or query
 
 	ack           string
 	error         string
 	initialResult string
+	schema        string // only checked if set
 }
 
 // runSimpleTestScript spins up a test server and runs statements on it,
@@ -71,7 +73,7 @@ func runSimpleTestScript(t *testing.T, cases []simpleTestStmt) {
 	for idx, testCase := range cases {
 		// Run a statement.
 		if testCase.stmt != "" {
-			result, err := client.Exec(testCase.stmt)
+			result, err := client.Exec(testCase.stmt, testCase.args...)
 			assertError(t, idx, testCase.error, err)
 			if result != testCase.ack {
 				t.Fatalf(`case %d: expected ack "%s"; got "%s"`, idx, testCase.ack, result)
@@ -80,12 +82,21 @@ func runSimpleTestScript(t *testing.T, cases []simpleTestStmt) {
 		}
 		// Run a query.
 		if testCase.query != "" {
-			res, err := client.Query(testCase.query)
+			res, err := client.Query(testCase.query, testCase.args...)
 			assertError(t, idx, testCase.error, err)
+			if err != nil {
+				continue
+			}
 			indented, _ := json.MarshalIndent(res.Data, "", "  ")
 			if string(indented) != testCase.initialResult {
 				t.Fatalf("expected:\n%sgot:\n%s", testCase.initialResult, indented)
 			}
+			if testCase.schema != "" {
+				indentedSchema, _ := json.MarshalIndent(res.Schema, "", "  ")
+				if string(indentedSchema) != testCase.schema {
+					t.Fatalf("expected schema:\n%s\ngot:\n%s", testCase.schema, indentedSchema)
+				}
+			}
 		}
 	}
 }
@@ -103,3 +114,15 @@ func assertError(t *testing.T, caseIdx int, expected string, err error) {
 		t.Fatalf(`case %d: expected error "%s"; got success`, caseIdx, expected)
 	}
 }
+
+// bufferUpdates reads a live query's updates as they arrive, so that the
+// client isn't blocked delivering one while a test is waiting for an ack.
+func bufferUpdates(channel *ClientChannel) chan *MessageToClient {
+	buffered := make(chan *MessageToClient, 100)
+	go func() {
+		for msg := range channel.Updates {
+			buffered <- msg
+		}
+	}()
+	return buffered
+}
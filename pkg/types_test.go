@@ -0,0 +1,122 @@
+package treesql
+
+import (
+	"testing"
+)
+
+func TestColumnTypes(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: `CREATE TABLE events (id int PRIMARY KEY, public bool, score float, at timestamp, payload bytes, data json)`,
+			ack:  "CREATE TABLE",
+		},
+		// Verify that values are checked.
+		{
+			stmt:  `INSERT INTO events VALUES (0, "yes", 1.5, NULL, NULL, NULL)`,
+			error: "validation error: can't assign string value to bool column public",
+		},
+		{
+			stmt:  `INSERT INTO events VALUES (0, TRUE, 1e999, NULL, NULL, NULL)`,
+			error: `validation error: "1e999" isn't a valid float`,
+		},
+		{
+			stmt:  `INSERT INTO events VALUES (0, TRUE, 1.5, "yesterday", NULL, NULL)`,
+			error: `validation error: "yesterday" isn't a valid timestamp; timestamp values are written in RFC 3339, e.g. "2006-01-02T15:04:05Z", between 1678 and 2262`,
+		},
+		{
+			stmt:  `INSERT INTO events VALUES (0, TRUE, 1.5, NULL, "not base64!", NULL)`,
+			error: `validation error: "not base64!" isn't a valid bytes; bytes values are written in base64`,
+		},
+		{
+			stmt:  `INSERT INTO events VALUES (0, TRUE, 1.5, NULL, NULL, '{"a":')`,
+			error: `validation error: "{\"a\":" isn't a valid json`,
+		},
+		{
+			stmt:  `INSERT INTO events VALUES (JSON "0", TRUE, 1.5, NULL, NULL, NULL)`,
+			error: "validation error: can't assign json value to int column id",
+		},
+		// Happy path: values can be written with their types, or as plain
+		// strings where a value of the type is expected. Ints go in floats.
+		{
+			stmt: `INSERT INTO events VALUES (0, TRUE, 1.5, TIMESTAMP "2018-01-02T15:04:05Z", BYTES "aGVsbG8=", JSON '{"a": [1, 2]}')`,
+			ack:  "INSERT 1",
+		},
+		{
+			stmt: `INSERT INTO events VALUES (1, $1, $2, $3, "d29ybGQ=", '"hi"'), (2, FALSE, 2, "2018-01-02T10:04:05.5-05:00", NULL, NULL)`,
+			args: []interface{}{false, -0.25, "2017-12-31T23:59:59Z"},
+			ack:  "INSERT 2",
+		},
+		{
+			query: `MANY events ORDER BY score { * }`,
+			initialResult: `[
+  {
+    "at": "2017-12-31T23:59:59Z",
+    "data": "hi",
+    "id": 1,
+    "payload": "d29ybGQ=",
+    "public": false,
+    "score": -0.25
+  },
+  {
+    "at": "2018-01-02T15:04:05Z",
+    "data": {
+      "a": [
+        1,
+        2
+      ]
+    },
+    "id": 0,
+    "payload": "aGVsbG8=",
+    "public": true,
+    "score": 1.5
+  },
+  {
+    "at": "2018-01-02T15:04:05.5Z",
+    "data": null,
+    "id": 2,
+    "payload": null,
+    "public": false,
+    "score": 2
+  }
+]`,
+		},
+		// Comparisons.
+		{
+			query: `MANY events WHERE public = "yes" { id }`,
+			error: "validation error: can't compare bool to string",
+		},
+		{
+			query: `MANY events WHERE at >= "2018-01-01T00:00:00Z" AND score > 1 AND public = FALSE { id }`,
+			initialResult: `[
+  {
+    "id": 2
+  }
+]`,
+		},
+		{
+			query: `MANY events WHERE payload = BYTES "aGVsbG8=" OR data = '"hi"' { id }`,
+			initialResult: `[
+  {
+    "id": 0
+  },
+  {
+    "id": 1
+  }
+]`,
+		},
+		{
+			stmt: `UPDATE events SET at = "2019-01-01T00:00:00Z", public = TRUE, score = 3 WHERE id = 2`,
+			ack:  "UPDATE 1",
+		},
+		{
+			query: `ONE events WHERE id = 2 { at, public, score }`,
+			initialResult: `[
+  {
+    "at": "2019-01-01T00:00:00Z",
+    "public": true,
+    "score": 3
+  }
+]`,
+		},
+	})
+}
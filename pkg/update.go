@@ -1,6 +1,7 @@
 package treesql
 
 import (
+	"bytes"
 	"fmt"
 	"time"
 
@@ -13,77 +14,151 @@ func (db *Database) validateUpdate(update *Update) error {
 	table, ok := db.Schema.Tables[update.Table]
 	// table exists
 	if !ok {
-		return &NoSuchTable{
+		return errorAt(update.Pos, update.Table, &NoSuchTable{
 			TableName: update.Table,
-		}
+		})
 	}
 	// table isn't a builtin
-	if update.Table == "__tables__" || update.Table == "__columns__" {
-		return &BuiltinWriteAttempt{
+	if isBuiltinTable(update.Table) {
+		return errorAt(update.Pos, update.Table, &BuiltinWriteAttempt{
 			TableName: update.Table,
-		}
+		})
 	}
-	// column to update exists
-	updateColExists := false
-	for _, column := range table.Columns {
-		if column.Name == update.ColumnName {
-			updateColExists = true
-		}
+	if err := validateAssignments(update.Assignments, table, table); err != nil {
+		return err
 	}
-	if !updateColExists {
-		return &NoSuchColumn{
-			TableName:  update.Table,
-			ColumnName: update.ColumnName,
-		}
+	// where clause is valid
+	if err := db.validateExpr(update.Where, table); err != nil {
+		return err
 	}
-	// column in where clause exists
-	whereColExists := false
-	for _, column := range table.Columns {
-		if column.Name == update.WhereColumnName {
-			whereColExists = true
+	return db.validateReturning(update.Returning)
+}
+
+// validateAssignments checks assignments to the table's columns, whose
+// values are computed from records of scope: the table itself for
+// updates, or its columns and excluded ones for ON CONFLICT DO UPDATE.
+func validateAssignments(assignments []*Assignment, table *TableDescriptor, scope *TableDescriptor) error {
+	assigned := map[string]bool{}
+	for _, assignment := range assignments {
+		// column to update exists, and is only assigned once
+		column := table.getColumn(assignment.ColumnName)
+		if column == nil {
+			return errorAt(assignment.Pos, "", &NoSuchColumn{
+				TableName:  table.Name,
+				ColumnName: assignment.ColumnName,
+			})
 		}
-	}
-	if !whereColExists {
-		return &NoSuchColumn{
-			TableName:  update.Table,
-			ColumnName: update.ColumnName,
+		if assigned[assignment.ColumnName] {
+			return errorAt(assignment.Pos, "", &DuplicateColumn{ColumnName: assignment.ColumnName})
+		}
+		assigned[assignment.ColumnName] = true
+		// value is valid, and fits in the column
+		if term := assignment.Value.term(); term != nil {
+			coercePlainStrings([]*Term{term}, column.Type)
+		}
+		valueType, err := assignment.Value.typeIn(scope)
+		if err != nil {
+			return err
+		}
+		if valueType == nil && table.notNull(column) {
+			return errorAt(assignment.Pos, "", &NullViolation{
+				TableName:  table.Name,
+				ColumnName: assignment.ColumnName,
+			})
+		}
+		if valueType != nil && !assignable(*valueType, column.Type) {
+			return errorAt(assignment.Pos, "", &AssignmentTypeMismatch{
+				ColumnName: assignment.ColumnName,
+				ColumnType: column.Type,
+				ValueType:  *valueType,
+			})
 		}
 	}
 	return nil
 }
 
+// assign returns a copy of oldRecord with the assignments applied. All the
+// values are computed from scope, e.g. oldRecord itself, before any are set.
+func assign(assignments []*Assignment, oldRecord *Record, scope *Record) (*Record, error) {
+	table := oldRecord.Table
+	values := make([]*Value, len(assignments))
+	for idx, assignment := range assignments {
+		value, err := assignment.Value.evaluate(scope)
+		if err != nil {
+			return nil, err
+		}
+		// e.g. an expression over a null column
+		if value.Null && table.notNull(table.getColumn(assignment.ColumnName)) {
+			return nil, &NullViolation{TableName: table.Name, ColumnName: assignment.ColumnName}
+		}
+		values[idx] = value
+	}
+	newRecord := oldRecord.Clone()
+	for idx, assignment := range assignments {
+		newRecord.SetValue(assignment.ColumnName, values[idx])
+	}
+	return newRecord, nil
+}
+
 func (conn *Connection) ExecuteUpdate(update *Update, channel *Channel) error {
 	startTime := time.Now()
 
 	// Write to table.
 	table := conn.Database.Schema.Tables[update.Table]
-	rowsUpdated := 0
+	var updatedRecords []*Record
+	var events []*TableEvent
 	updateErr := conn.Database.BoltDB.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(update.Table))
-		bucket.ForEach(func(key []byte, value []byte) error {
+		// Find matching records first; Bolt doesn't allow modifying
+		// a bucket from inside ForEach.
+		var matching []*Record
+		if err := bucket.ForEach(func(key []byte, value []byte) error {
 			record := table.RecordFromBytes(value)
-			if record.GetField(update.WhereColumnName).StringVal == update.EqualsValue {
-				clonedOldRecord := record.Clone()
-				record.SetString(update.ColumnName, update.Value)
-				clonedNewRecord := record.Clone()
-				rowUpdateErr := bucket.Put(key, record.ToBytes())
-				if rowUpdateErr != nil {
-					return rowUpdateErr
-				}
-				// Send live query updates.
-				conn.Database.PushTableEvent(channel, update.Table, clonedOldRecord, clonedNewRecord)
-				rowsUpdated++
+			if update.Where.Evaluate(record) {
+				matching = append(matching, record)
 			}
 			return nil
-		})
-		return nil
+		}); err != nil {
+			return err
+		}
+		for _, oldRecord := range matching {
+			newRecord, err := assign(update.Assignments, oldRecord, oldRecord)
+			if err != nil {
+				return err
+			}
+			updatedRecords = append(updatedRecords, newRecord)
+			if bytes.Equal(newRecord.ToBytes(), oldRecord.ToBytes()) {
+				continue
+			}
+			changes, err := conn.Database.updateRecord(tx, oldRecord, newRecord)
+			if err != nil {
+				return err
+			}
+			events = append(events, changes...)
+		}
+		for _, record := range updatedRecords {
+			if err := checkReferences(tx, record); err != nil {
+				return err
+			}
+		}
+		return checkConstraints(tx, events)
 	})
 	if updateErr != nil {
 		return errors.Wrap(updateErr, "executing update")
 	}
 
-	// Return ack message.
-	channel.WriteAckMessage(fmt.Sprintf("UPDATE %d", rowsUpdated))
+	// Send live query updates for the rows which changed, including
+	// records which reference them, if their primary keys changed.
+	conn.Database.pushTableEvents(channel, events)
+
+	// Return the updated records if they were asked for, or else an ack message.
+	if update.Returning != nil {
+		if err := conn.executeReturning(update.Returning, updatedRecords, channel); err != nil {
+			return errors.Wrap(err, "returning updated records")
+		}
+	} else {
+		channel.WriteAckMessage(fmt.Sprintf("UPDATE %d", len(updatedRecords)))
+	}
 
 	// Record latency.
 	endTime := time.Now()
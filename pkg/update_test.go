@@ -0,0 +1,138 @@
+package treesql
+
+import "testing"
+
+func TestUpdate(t *testing.T) {
+	runSimpleTestScript(t, []simpleTestStmt{
+		{
+			stmt: "CREATE TABLE blog_posts (id string PRIMARY KEY, title string, body string, views int)",
+			ack:  "CREATE TABLE",
+		},
+		{
+			stmt: `INSERT INTO blog_posts (id, title, body, views) VALUES ("0", "Hello World", "bla", 0), ("1", "Goodbye", "bla", 0)`,
+			ack:  "INSERT 2",
+		},
+		{
+			stmt:  `UPDATE __record_listeners__ SET pk_value = "0" WHERE id = "1"`,
+			error: "validation error: attemtped to write to __record_listeners__, but builtin tables are read-only",
+		},
+		// Verify that assignments are checked.
+		{
+			stmt:  `UPDATE blog_posts SET author = "pete" WHERE id = "0"`,
+			error: "validation error: no such column in table blog_posts: author",
+		},
+		{
+			stmt:  `UPDATE blog_posts SET title = "a", title = "b" WHERE id = "0"`,
+			error: "validation error: column listed more than once: title",
+		},
+		{
+			stmt:  `UPDATE blog_posts SET views = "5" WHERE id = "0"`,
+			error: "validation error: can't assign string value to int column views",
+		},
+		{
+			stmt:  `UPDATE blog_posts SET title = title + 1 WHERE id = "0"`,
+			error: "validation error: operator + only applies to int values; got string",
+		},
+		{
+			stmt:  `UPDATE blog_posts SET title = reverse(title) WHERE id = "0"`,
+			error: "validation error: no such function: reverse",
+		},
+		{
+			stmt:  `UPDATE blog_posts SET title = upper(title, body) WHERE id = "0"`,
+			error: "validation error: upper takes 1 arguments; given 2",
+		},
+		{
+			stmt:  `UPDATE blog_posts SET views = length(views) WHERE id = "0"`,
+			error: "validation error: length takes string arguments; given int",
+		},
+		{
+			stmt:  `UPDATE blog_posts SET views = views / (views - views) WHERE id = "0"`,
+			error: "executing update: division by zero",
+		},
+		{
+			stmt:  `UPDATE blog_posts SET views = views + 2147483647 + 1 WHERE id = "0"`,
+			error: "executing update: int out of range; ints are whole numbers from -2147483648 to 2147483647",
+		},
+		{
+			stmt:  `UPDATE blog_posts SET views = (views - 2147483647) * 2 WHERE id = "0"`,
+			error: "executing update: int out of range; ints are whole numbers from -2147483648 to 2147483647",
+		},
+		// Happy path. Every assignment sees the row as it was before the update.
+		{
+			stmt: `UPDATE blog_posts SET title = upper(title), body = lower(title), views = views + 2 * 3 WHERE id = "0" OR title = "Goodbye"`,
+			ack:  "UPDATE 2",
+		},
+		{
+			stmt: `UPDATE blog_posts SET views = (views + 1) % 4 WHERE views >= 6 AND id = "1"`,
+			ack:  "UPDATE 1",
+		},
+		{
+			query: `MANY blog_posts WHERE views = 6 { id, title, body }`,
+			initialResult: `[
+  {
+    "body": "hello world",
+    "id": "0",
+    "title": "HELLO WORLD"
+  }
+]`,
+		},
+		{
+			query: `MANY blog_posts WHERE views = 3 { id, title, body }`,
+			initialResult: `[
+  {
+    "body": "goodbye",
+    "id": "1",
+    "title": "GOODBYE"
+  }
+]`,
+		},
+	})
+}
+
+func TestLiveUpdate(t *testing.T) {
+	server, client, err := NewTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.close()
+
+	stmts := []string{
+		`CREATE TABLE blog_posts (id string PRIMARY KEY, title string, body string)`,
+		`INSERT INTO blog_posts VALUES ("0", "hello world", "bla")`,
+		`INSERT INTO blog_posts VALUES ("1", "HELLO AGAIN", "bla")`,
+	}
+	for _, stmt := range stmts {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, lqChan, err := client.LiveQuery(`MANY blog_posts { id, title, body } live`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := bufferUpdates(lqChan)
+
+	// Post 1 is already upper case and its body lower case, so only post 0 changes;
+	// the next message is for the delete.
+	if _, err := client.Exec(`UPDATE blog_posts SET title = upper(title), body = lower(body) WHERE id <> "2"`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Exec(`DELETE FROM blog_posts WHERE id = "1"`); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-updates
+	if msg.Type != RecordUpdateMessage {
+		t.Fatalf("expected %v but got %v", RecordUpdateMessage, msg.Type)
+	}
+	path := msg.RecordUpdateMessage.QueryPath
+	if len(path) != 1 || path[0]["id"] != "0" {
+		t.Fatalf("unexpected query path %v", path)
+	}
+	msg = <-updates
+	if msg.Type != RecordDeleteMessage {
+		t.Fatalf("expected %v but got %v", RecordDeleteMessage, msg.Type)
+	}
+}
@@ -0,0 +1,305 @@
+package treesql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+type function struct {
+	params  []ColumnType
+	returns ColumnType
+	apply   func(args []*Value) *Value
+}
+
+// functions which can be called in value expressions, e.g. `upper(title)`.
+var functions = map[string]*function{
+	"upper": {
+		params:  []ColumnType{TypeString},
+		returns: TypeString,
+		apply: func(args []*Value) *Value {
+			return &Value{Type: TypeString, StringVal: strings.ToUpper(args[0].StringVal)}
+		},
+	},
+	"lower": {
+		params:  []ColumnType{TypeString},
+		returns: TypeString,
+		apply: func(args []*Value) *Value {
+			return &Value{Type: TypeString, StringVal: strings.ToLower(args[0].StringVal)}
+		},
+	},
+	"length": {
+		params:  []ColumnType{TypeString},
+		returns: TypeInt,
+		apply: func(args []*Value) *Value {
+			return &Value{Type: TypeInt, IntVal: utf8.RuneCountInString(args[0].StringVal)}
+		},
+	},
+	// now and gen_uuid are mostly for column defaults, e.g.
+	// `created_at timestamp DEFAULT now()`.
+	"now": {
+		returns: TypeTimestamp,
+		apply: func(args []*Value) *Value {
+			return &Value{Type: TypeTimestamp, TimeVal: time.Now().UTC()}
+		},
+	},
+	"gen_uuid": {
+		returns: TypeString,
+		apply: func(args []*Value) *Value {
+			return &Value{Type: TypeString, StringVal: uuid.New().String()}
+		},
+	},
+}
+
+var errDivisionByZero = errors.New("division by zero")
+
+var errIntOverflow = fmt.Errorf("int out of range; ints are whole numbers from %d to %d", MinInt, MaxInt)
+
+// validation
+
+// typeIn returns the type this expression evaluates to in the given
+// table, or nil if it's just NULL.
+func (expr *ValueExpr) typeIn(table *TableDescriptor) (*ColumnType, error) {
+	leftType, err := expr.Left.typeIn(table)
+	if err != nil {
+		return nil, err
+	}
+	if len(expr.Rest) == 0 {
+		return leftType, nil
+	}
+	if err := checkOperand(expr.Rest[0].Pos, "||", TypeString, leftType); err != nil {
+		return nil, err
+	}
+	for _, op := range expr.Rest {
+		rightType, err := op.Right.typeIn(table)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkOperand(op.Pos, "||", TypeString, rightType); err != nil {
+			return nil, err
+		}
+	}
+	stringType := TypeString
+	return &stringType, nil
+}
+
+func (sum *Sum) typeIn(table *TableDescriptor) (*ColumnType, error) {
+	leftType, err := sum.Left.typeIn(table)
+	if err != nil {
+		return nil, err
+	}
+	if len(sum.Rest) == 0 {
+		return leftType, nil
+	}
+	if err := checkOperand(sum.Rest[0].Pos, sum.Rest[0].Op, TypeInt, leftType); err != nil {
+		return nil, err
+	}
+	for _, op := range sum.Rest {
+		rightType, err := op.Right.typeIn(table)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkOperand(op.Pos, op.Op, TypeInt, rightType); err != nil {
+			return nil, err
+		}
+	}
+	intType := TypeInt
+	return &intType, nil
+}
+
+func (product *Product) typeIn(table *TableDescriptor) (*ColumnType, error) {
+	leftType, err := product.Left.typeIn(table)
+	if err != nil {
+		return nil, err
+	}
+	if len(product.Rest) == 0 {
+		return leftType, nil
+	}
+	if err := checkOperand(product.Rest[0].Pos, product.Rest[0].Op, TypeInt, leftType); err != nil {
+		return nil, err
+	}
+	for _, op := range product.Rest {
+		rightType, err := op.Right.typeIn(table)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkOperand(op.Pos, op.Op, TypeInt, rightType); err != nil {
+			return nil, err
+		}
+	}
+	intType := TypeInt
+	return &intType, nil
+}
+
+func checkOperand(pos Position, op string, wanted ColumnType, operandType *ColumnType) error {
+	if operandType != nil && *operandType != wanted {
+		return errorAt(pos, "", &OperatorWrongType{Op: op, Wanted: wanted, Got: *operandType})
+	}
+	return nil
+}
+
+func (factor *Factor) typeIn(table *TableDescriptor) (*ColumnType, error) {
+	if factor.Parens != nil {
+		return factor.Parens.typeIn(table)
+	}
+	if factor.Call == nil {
+		return factor.Term.typeIn(table)
+	}
+	// function call
+	if factor.Term.Column == nil {
+		return nil, errorAt(factor.Pos, "", &NoSuchFunction{Name: factor.Term.Format()})
+	}
+	name := strings.ToLower(*factor.Term.Column)
+	fn, ok := functions[name]
+	if !ok {
+		return nil, errorAt(factor.Pos, "", &NoSuchFunction{Name: name})
+	}
+	if len(factor.Call.Args) != len(fn.params) {
+		return nil, errorAt(factor.Pos, "", &FunctionWrongNumArgs{
+			Name:   name,
+			Wanted: len(fn.params),
+			Got:    len(factor.Call.Args),
+		})
+	}
+	for idx, arg := range factor.Call.Args {
+		argType, err := arg.typeIn(table)
+		if err != nil {
+			return nil, err
+		}
+		if argType != nil && *argType != fn.params[idx] {
+			return nil, errorAt(factor.Pos, "", &FunctionWrongType{Name: name, Wanted: fn.params[idx], Got: *argType})
+		}
+	}
+	returns := fn.returns
+	return &returns, nil
+}
+
+// term returns the expression's term if that's all it is, e.g. for
+// `"hello"` but not for `"hello" || " world"` or `upper("hello")`.
+func (expr *ValueExpr) term() *Term {
+	if len(expr.Rest) > 0 || len(expr.Left.Rest) > 0 || len(expr.Left.Left.Rest) > 0 {
+		return nil
+	}
+	factor := expr.Left.Left.Left
+	if factor.Parens != nil || factor.Call != nil {
+		return nil
+	}
+	return factor.Term
+}
+
+// evaluation
+
+// evaluate computes the expression's value for the given record.
+// Operators and function calls on NULL produce NULL.
+func (expr *ValueExpr) evaluate(record *Record) (*Value, error) {
+	result, err := expr.Left.evaluate(record)
+	if err != nil {
+		return nil, err
+	}
+	if len(expr.Rest) == 0 {
+		return result, nil
+	}
+	concatenated := &strings.Builder{}
+	null := result.Null
+	concatenated.WriteString(result.StringVal)
+	for _, op := range expr.Rest {
+		right, err := op.Right.evaluate(record)
+		if err != nil {
+			return nil, err
+		}
+		null = null || right.Null
+		concatenated.WriteString(right.StringVal)
+	}
+	if null {
+		return &Value{Type: TypeString, Null: true}, nil
+	}
+	return &Value{Type: TypeString, StringVal: concatenated.String()}, nil
+}
+
+func (sum *Sum) evaluate(record *Record) (*Value, error) {
+	result, err := sum.Left.evaluate(record)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range sum.Rest {
+		right, err := op.Right.evaluate(record)
+		if err != nil {
+			return nil, err
+		}
+		if result, err = arithmetic(op.Op, result, right); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (product *Product) evaluate(record *Record) (*Value, error) {
+	result, err := product.Left.evaluate(record)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range product.Rest {
+		right, err := op.Right.evaluate(record)
+		if err != nil {
+			return nil, err
+		}
+		if result, err = arithmetic(op.Op, result, right); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (factor *Factor) evaluate(record *Record) (*Value, error) {
+	if factor.Parens != nil {
+		return factor.Parens.evaluate(record)
+	}
+	if factor.Call == nil {
+		return factor.Term.evaluate(record), nil
+	}
+	fn := functions[strings.ToLower(*factor.Term.Column)]
+	args := make([]*Value, len(factor.Call.Args))
+	for idx, argExpr := range factor.Call.Args {
+		arg, err := argExpr.evaluate(record)
+		if err != nil {
+			return nil, err
+		}
+		if arg.Null {
+			return &Value{Type: fn.returns, Null: true}, nil
+		}
+		args[idx] = arg
+	}
+	return fn.apply(args), nil
+}
+
+func arithmetic(op string, left *Value, right *Value) (*Value, error) {
+	if left.Null || right.Null {
+		return &Value{Type: TypeInt, Null: true}, nil
+	}
+	result := &Value{Type: TypeInt}
+	switch op {
+	case "+":
+		result.IntVal = left.IntVal + right.IntVal
+	case "-":
+		result.IntVal = left.IntVal - right.IntVal
+	case "*":
+		result.IntVal = left.IntVal * right.IntVal
+	case "/", "%":
+		if right.IntVal == 0 {
+			return nil, errDivisionByZero
+		}
+		if op == "/" {
+			result.IntVal = left.IntVal / right.IntVal
+		} else {
+			result.IntVal = left.IntVal % right.IntVal
+		}
+	}
+	if result.IntVal < MinInt || result.IntVal > MaxInt {
+		return nil, errIntOverflow
+	}
+	return result, nil
+}
@@ -0,0 +1,180 @@
+package treesql
+
+import (
+	"log"
+	"sort"
+	"sync"
+)
+
+// windowed returns whether this selection has an ORDER BY, LIMIT or OFFSET,
+// i.e. whether changes to other records can move records into or out of it.
+func (query *Select) windowed() bool {
+	return query.OrderBy != nil || query.Limit != nil || query.Offset != nil
+}
+
+// applyWindow sorts records by the ORDER BY column, and returns
+// the ones in the window given by OFFSET and LIMIT.
+func (query *Select) applyWindow(records []*Record) []*Record {
+	if query.OrderBy != nil {
+		columnName := query.OrderBy.ColumnName
+		sort.SliceStable(records, func(i, j int) bool {
+			cmp := records[i].GetField(columnName).Compare(records[j].GetField(columnName))
+			if query.OrderBy.Desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+	}
+	if query.Offset != nil && *query.Offset > 0 {
+		if *query.Offset >= len(records) {
+			return nil
+		}
+		records = records[*query.Offset:]
+	}
+	if query.Limit != nil && *query.Limit < len(records) {
+		if *query.Limit < 0 {
+			return nil
+		}
+		records = records[:*query.Limit]
+	}
+	return records
+}
+
+func recordKeys(records []*Record) []string {
+	keys := make([]string, len(records))
+	for idx, record := range records {
+		keys[idx] = record.primaryKey()
+	}
+	return keys
+}
+
+// windowKeys returns the primary keys of the records in a selection's
+// window which satisfy the condition, in order, as they are now.
+func (ex *SelectExecution) windowKeys(query *Select, table *TableDescriptor, where *Expr) ([]string, error) {
+	tx, err := ex.Channel.Connection.Database.BoltDB.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	keysExecution := &SelectExecution{
+		ID:          ex.ID,
+		Channel:     ex.Channel,
+		Query:       query,
+		Transaction: tx,
+		Context:     ex.Context,
+	}
+	iterator, _ := keysExecution.getScanIterator(table, where)
+	defer iterator.Close()
+	var records []*Record
+	for record := iterator.Next(); record != nil; record = iterator.Next() {
+		if where == nil || where.Evaluate(record) {
+			records = append(records, record)
+		}
+	}
+	return recordKeys(query.applyWindow(records)), nil
+}
+
+// liveWindow is the state of a windowed table listener:
+// the primary keys of the records the client has, in order.
+type liveWindow struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+// refreshWindow recomputes a windowed listener's window after a write
+// to its table, and tells the client which records left it and which
+// entered it. Records entering the window are subscribed to along
+// with their subselections; records leaving it are unsubscribed from.
+func (listener *Listener) refreshWindow() {
+	window := listener.window
+	window.mu.Lock()
+	defer window.mu.Unlock()
+
+	channel := listener.QueryExecution.Channel
+	conn := channel.Connection
+	query := listener.Query
+	table := conn.Database.Schema.Tables[query.Table]
+	where := listener.Filter.And(query.Where)
+
+	// Find out which records are in the window now.
+	newKeys, err := listener.QueryExecution.windowKeys(query, table, where)
+	if err != nil {
+		log.Println("failed to refresh window for statement id", listener.QueryExecution.ID, ":", err)
+		return
+	}
+
+	// Diff against what the client has.
+	inOld := map[string]bool{}
+	for _, key := range window.keys {
+		inOld[key] = true
+	}
+	inNew := map[string]bool{}
+	for _, key := range newKeys {
+		inNew[key] = true
+	}
+	removed := make([]string, 0)
+	for _, key := range window.keys {
+		if !inNew[key] {
+			removed = append(removed, key)
+		}
+	}
+	added := make([]string, 0)
+	for _, key := range newKeys {
+		if !inOld[key] {
+			added = append(added, key)
+		}
+	}
+	if len(removed) == 0 && len(added) == 0 && sameKeys(window.keys, newKeys) {
+		return
+	}
+
+	// Fetch and subscribe to records which entered the window.
+	addedResults := make(SelectResult, 0)
+	for _, key := range added {
+		recordQuery := &Select{
+			Live:       true,
+			Many:       true,
+			Table:      query.Table,
+			Where:      NewEqualsExpr(table.PrimaryKey, keyValue(table.getColumn(table.PrimaryKey).Type, key)),
+			Selections: query.Selections,
+			Recursive:  query.Recursive,
+			MaxDepth:   query.MaxDepth,
+		}
+		result, err := conn.ExecuteQueryForTableListener(
+			recordQuery, int(listener.QueryExecution.ID), channel, listener.QueryPath,
+		)
+		if err != nil {
+			log.Println("failed to fetch record for window for statement id", listener.QueryExecution.ID, ":", err)
+			return
+		}
+		addedResults = append(addedResults, result...)
+	}
+
+	// Stop listening to records which left the window.
+	for _, key := range removed {
+		table.removeRecordListener(key, channel, &QueryPath{
+			ID:              &key,
+			PreviousSegment: listener.QueryPath,
+		})
+	}
+
+	window.keys = newKeys
+	channel.WriteWindowUpdate(&WindowUpdate{
+		QueryPath: listener.QueryPath.Flatten(),
+		Removed:   removed,
+		Added:     addedResults,
+		Order:     newKeys,
+	})
+}
+
+func sameKeys(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
@@ -13,11 +13,34 @@ export const tableUpdate = (queryPath, selection) => ({
 });
 
 export const RECORD_UPDATE = 'RECORD_UPDATE';
-export const recordUpdate = (queryPath, oldRecord, newRecord) => ({
+export const recordUpdate = (queryPath, oldRecord, newRecord, fields) => ({
   type: RECORD_UPDATE,
   queryPath,
   oldRecord,
-  newRecord
+  newRecord,
+  fields
+});
+
+export const RECORD_DELETE = 'RECORD_DELETE';
+export const recordDelete = (queryPath) => ({
+  type: RECORD_DELETE,
+  queryPath
+});
+
+export const WINDOW_UPDATE = 'WINDOW_UPDATE';
+export const windowUpdate = (queryPath, removed, added, order) => ({
+  type: WINDOW_UPDATE,
+  queryPath,
+  removed,
+  added,
+  order
+});
+
+export const AGGREGATE_UPDATE = 'AGGREGATE_UPDATE';
+export const aggregateUpdate = (queryPath, value) => ({
+  type: AGGREGATE_UPDATE,
+  queryPath,
+  value
 });
 
 // idk, maybe this should be in TreeSQLClient.js
@@ -28,11 +51,24 @@ export function updateToAction(update) {
       return initialResult(payload.Schema, payload.Data);
 
     case 'record_update':
-      return recordUpdate(payload.QueryPath, payload.TableEvent.OldRecord, payload.TableEvent.NewRecord);
+      return recordUpdate(
+        payload.QueryPath, payload.TableEvent.OldRecord, payload.TableEvent.NewRecord, payload.Fields
+      );
 
     case 'table_update':
       // TODO: this should come through as an empty list
       return tableUpdate(payload.QueryPath || [], payload.Selection);
+
+    case 'record_delete':
+      return recordDelete(payload.QueryPath);
+
+    case 'window_update':
+      return windowUpdate(
+        payload.QueryPath || [], payload.Removed || [], payload.Added || [], payload.Order || []
+      );
+
+    case 'aggregate_update':
+      return aggregateUpdate(payload.QueryPath, payload.Value);
     
     default:
       console.warn('unhandled message from live query:', update);